@@ -8,123 +8,133 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"crypto/x509"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"os"
 	"path"
-	"time"
+	"strconv"
+	"sync"
 
 	"github.com/hyperledger/fabric-gateway/pkg/client"
 	"github.com/hyperledger/fabric-gateway/pkg/identity"
-	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/status"
 )
 
-const (
-	mspID="brandMSP"														//所屬組織的MSPID
-	cryptoPath= "/root/MyLab_IVS/organizations/brand.ivsorg.net"			// 中間變量
-	certPath= cryptoPath + "/registers_users/user1/msp/signcerts/cert.pem"	// client數位簽章
-	keyPath= cryptoPath + "/registers_users/user1/msp/keystore/"			// client私鑰路徑
-	tlsCertPath= cryptoPath + "/alliance/tls-ca-cert.pem"					// client tls證書
-	peerEndpoint= "peer1.brand.ivsorg.net:7151"								// peer節點地址
-	gatewayPeer= "peer1.brand.ivsorg.net"									// peer節點名稱
-)
+func main() {
+	os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-var now = time.Now()
-var assetId = fmt.Sprintf("asset%d", now.Unix()*1e3+int64(now.Nanosecond())/1e6)
-var partId = fmt.Sprintf("part%d", now.Unix()*1e3+int64(now.Nanosecond())/1e6)
+// session is the Gateway connection of one client identity to the channel and chaincode of a
+// connection profile
+type session struct {
+	profile     *connectionProfile
+	connections *connections
+	gateway     *client.Gateway
+	network     *client.Network
+	contract    *client.Contract
+}
 
-func main() {
-	// The gRPC client connection should be shared by all Gateway connections to this endpoint
-	clientConnection := newGrpcConnection()
-	defer clientConnection.Close()
+// connections holds the Gateway connections of the client identities used by this process. They
+// all share one gRPC connection to the gateway peer.
+type connections struct {
+	profile          *connectionProfile
+	clientConnection *grpc.ClientConn
+	unsigned         bool // connect without private keys, requests are signed offline
+	mu               sync.Mutex
+	sessions         map[string]*session
+}
 
-	id := newIdentity()
-	sign := newSign()
+func newConnections(profile *connectionProfile) *connections {
+	return &connections{
+		profile: profile,
+		// The gRPC client connection should be shared by all Gateway connections to this endpoint
+		clientConnection: newGrpcConnection(profile),
+		sessions:         make(map[string]*session),
+	}
+}
 
-	// Create a Gateway connection for a specific client identity
-	gw, err := client.Connect(
-		id,
-		client.WithSign(sign),
-		client.WithClientConnection(clientConnection),
+// session returns the Gateway connection of a wallet identity, connecting on first use. An empty
+// label selects the identity of the connection profile.
+func (c *connections) session(label string) (*session, error) {
+	if label == "" {
+		label = c.profile.Identity
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.sessions[label]; ok {
+		return s, nil
+	}
+
+	options := []client.ConnectOption{
+		client.WithClientConnection(c.clientConnection),
 		// Default timeouts for different gRPC calls
-		client.WithEvaluateTimeout(5*time.Second),
-		client.WithEndorseTimeout(15*time.Second),
-		client.WithSubmitTimeout(5*time.Second),
-		client.WithCommitStatusTimeout(1*time.Minute),
-	)
+		client.WithEvaluateTimeout(c.profile.Timeouts.Evaluate.Duration),
+		client.WithEndorseTimeout(c.profile.Timeouts.Endorse.Duration),
+		client.WithSubmitTimeout(c.profile.Timeouts.Submit.Duration),
+		client.WithCommitStatusTimeout(c.profile.Timeouts.CommitStatus.Duration),
+	}
+	var id identity.Identity
+	if c.unsigned {
+		x509Identity, err := loadIdentity(c.profile, label)
+		if err != nil {
+			return nil, err
+		}
+		id = x509Identity
+	} else {
+		signer, err := loadSigner(c.profile, label)
+		if err != nil {
+			return nil, err
+		}
+		id = signer.id
+		options = append(options, client.WithSign(signer.sign), client.WithHash(signer.hash))
+	}
+	// Create a Gateway connection for a specific client identity
+	gw, err := client.Connect(id, options...)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	defer gw.Close()
 
-	// Override default values for chaincode and channel name as they may differ in testing contexts.
-	chaincodeName := "ivs_basic"
-	if ccname := os.Getenv("CHAINCODE_NAME"); ccname != "" {
-		chaincodeName = ccname
+	network := gw.GetNetwork(c.profile.Channel)
+	s := &session{
+		profile:     c.profile,
+		connections: c,
+		gateway:     gw,
+		network:     network,
+		contract:    network.GetContract(c.profile.Chaincode),
 	}
+	c.sessions[label] = s
+	return s, nil
+}
 
-	channelName := "ivschannel"
-	if cname := os.Getenv("CHANNEL_NAME"); cname != "" {
-		channelName = cname
+// Close closes every Gateway connection and the shared gRPC connection
+func (c *connections) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.sessions {
+		s.gateway.Close()
 	}
-
-	network := gw.GetNetwork(channelName)
-	contract := network.GetContract(chaincodeName)
-
-	initLedger(contract)
-//	transferPartAsync(contract)
-//	transferPartsByOrganizationAsync(contract)
-//	createPart(contract)
-	getAllParts(contract)
-//	createAsset(contract)
-//	updateAsset(contract)
-//	readAssetByID(contract)
-//	readPartByID(contract)
-//	queryAssets(contract)
-//	queryAssetsBySerialNumber(contract)
-	getAssetSerialNumberHistory(contract)
-//	getAssetHistory(contract)	
-//	exampleErrorHandling(contract)
+	c.clientConnection.Close()
 }
 
 // newGrpcConnection creates a gRPC connection to the Gateway server.
-func newGrpcConnection() *grpc.ClientConn {
-	certificate, err := loadCertificate(tlsCertPath)
+func newGrpcConnection(profile *connectionProfile) *grpc.ClientConn {
+	certificate, err := loadCertificate(profile.TLSCertPath)
 	if err != nil {
 		panic(err)
 	}
 
 	certPool := x509.NewCertPool()
 	certPool.AddCert(certificate)
-	transportCredentials := credentials.NewClientTLSFromCert(certPool, gatewayPeer)
-	connection, err := grpc.Dial(peerEndpoint, grpc.WithTransportCredentials(transportCredentials))
+	transportCredentials := credentials.NewClientTLSFromCert(certPool, profile.GatewayPeer)
+	connection, err := grpc.Dial(profile.PeerEndpoint, grpc.WithTransportCredentials(transportCredentials))
 	if err != nil {
 		panic(fmt.Errorf("failed to create gRPC connection: %w", err))
 	}
 	return connection
 }
 
-// newIdentity creates a client identity for this Gateway connection using an X.509 certificate.
-func newIdentity() *identity.X509Identity {
-	certificate, err := loadCertificate(certPath)
-	if err != nil {
-		panic(err)
-	}
-
-	id, err := identity.NewX509Identity(mspID, certificate)
-	if err != nil {
-		panic(err)
-	}
-
-	return id
-}
-
 func loadCertificate(filename string) (*x509.Certificate, error) {
 	certificatePEM, err := os.ReadFile(filename)
 	if err != nil {
@@ -133,258 +143,304 @@ func loadCertificate(filename string) (*x509.Certificate, error) {
 	return identity.CertificateFromPEM(certificatePEM)
 }
 
-// newSign creates a function that generates a digital signature from a message digest using a private key.
-func newSign() identity.Sign {
-	files, err := os.ReadDir(keyPath)
+// readPrivateKey reads a private key file, or the only file of a keystore directory
+func readPrivateKey(keyPath string) ([]byte, error) {
+	info, err := os.Stat(keyPath)
 	if err != nil {
-		panic(fmt.Errorf("failed to read private key directory: %w", err))
+		return nil, fmt.Errorf("failed to read private key: %w", err)
 	}
-	privateKeyPEM, err := os.ReadFile(path.Join(keyPath, files[0].Name()))
-
-	if err != nil {
-		panic(fmt.Errorf("failed to read private key file: %w", err))
+	if info.IsDir() {
+		files, err := os.ReadDir(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key directory: %w", err)
+		}
+		if len(files) != 1 {
+			return nil, fmt.Errorf("private key directory %s must hold exactly one file, found %d", keyPath, len(files))
+		}
+		keyPath = path.Join(keyPath, files[0].Name())
 	}
-
-	privateKey, err := identity.PrivateKeyFromPEM(privateKeyPEM)
+	privateKeyPEM, err := os.ReadFile(keyPath)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to read private key file: %w", err)
 	}
+	return privateKeyPEM, nil
+}
 
-	sign, err := identity.NewPrivateKeySign(privateKey)
-	if err != nil {
-		panic(err)
+// transactor submits and evaluates transactions. *client.Contract submits synchronously, waiting
+// for the transaction to commit.
+type transactor interface {
+	SubmitTransaction(name string, args ...string) ([]byte, error)
+	EvaluateTransaction(name string, args ...string) ([]byte, error)
+}
+
+// The functions below wrap one chaincode transaction each and return its JSON result, so that the
+// command line interface and the REST API only have to parse arguments and render results.
+
+// initLedger creates the initial set of parts and the default product model. It would typically
+// only be run once, the first time the network is started after its initial deployment.
+func initLedger(contract transactor) ([]byte, error) {
+	return contract.SubmitTransaction("InitLedger")
+}
+
+// migrateLedgerKeys submits MigrateLedgerKeys repeatedly until every part and asset written by an
+// earlier chaincode version has been moved to its prefixed key, and returns the number moved.
+func migrateLedgerKeys(contract transactor, limit int) ([]byte, error) {
+	total := 0
+	for {
+		submitResult, err := contract.SubmitTransaction("MigrateLedgerKeys", strconv.Itoa(limit))
+		if err != nil {
+			return nil, err
+		}
+		var migrated int
+		if err := json.Unmarshal(submitResult, &migrated); err != nil {
+			return nil, fmt.Errorf("failed to parse result: %w", err)
+		}
+		if migrated == 0 {
+			break
+		}
+		total += migrated
 	}
-	return sign
+	return json.Marshal(map[string]int{"migrated": total})
 }
 
-// This type of transaction would typically only be run once by an application the first time it was started after its
-// initial deployment. A new version of the chaincode deployed later would likely not need to run an "init" function.
-func initLedger(contract *client.Contract) {
-	fmt.Printf("\n--> Submit Transaction: InitLedger, function creates the initial set of assets on the ledger \n")
-	_, err := contract.SubmitTransaction("InitLedger")
-	if err != nil {
-		fmt.Printf("failed to submit transaction: %s\n", err)
-		return
+// rebuildIndexes reconciles the composite key indexes with the ledger. Requires an identity with ivs.admin=true.
+func rebuildIndexes(contract transactor) ([]byte, error) {
+	return contract.SubmitTransaction("RebuildIndexes")
+}
+
+// createPart registers a single part of the calling chip maker. CreatePart cannot assign a lot, so a
+// part of a production lot is registered as a batch of one.
+func createPart(contract transactor, part partDefinition) ([]byte, error) {
+	if part.LotID != "" {
+		partsBytes, err := json.Marshal([]partDefinition{part})
+		if err != nil {
+			return nil, err
+		}
+		return contract.SubmitTransaction("CreatePartsBatch", string(partsBytes))
 	}
-	fmt.Printf("*** Transaction committed successfully\n")
+	return contract.SubmitTransaction("CreatePart", part.PID, part.Manufacturer, part.ManufactureLocation, part.PartName, part.PartNumber, part.Organization)
 }
-// Evaluate a transaction to query ledger state.
-func getAllParts(contract *client.Contract) {
-	fmt.Println("\n--> Evaluate Transaction: GetAllParts, function returns all the current parts on the ledger")
-	evaluateResult, err := contract.EvaluateTransaction("GetAllParts")
-	if err != nil {
-		fmt.Printf("failed to submit transaction: %s\n", err)
-		return
+
+func readPart(contract transactor, partID string) ([]byte, error) {
+	return contract.EvaluateTransaction("ReadPart", partID)
+}
+
+func getAllParts(contract transactor) ([]byte, error) {
+	return contract.EvaluateTransaction("GetAllParts")
+}
+
+// paginatedParts mirrors the chaincode's PaginatedPartQueryResult
+type paginatedParts struct {
+	Records             []json.RawMessage `json:"records"`
+	FetchedRecordsCount int32             `json:"fetchedRecordsCount"`
+	Bookmark            string            `json:"bookmark"`
+}
+
+// getPartsByOrganization evaluates a paginated part query page by page, following the bookmark
+// until the last page, and returns the parts of every page
+func getPartsByOrganization(contract transactor, organization string, pageSize int) ([]byte, error) {
+	parts := []json.RawMessage{}
+	bookmark := ""
+	for page := 1; ; page++ {
+		evaluateResult, err := contract.EvaluateTransaction("GetPartsByOrganizationWithPagination", organization, strconv.Itoa(pageSize), bookmark)
+		if err != nil {
+			return nil, err
+		}
+		var result paginatedParts
+		if err := json.Unmarshal(evaluateResult, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse page %d: %w", page, err)
+		}
+		parts = append(parts, result.Records...)
+		// an empty bookmark or a short page marks the end of the result set
+		if result.Bookmark == "" || result.Bookmark == bookmark || int(result.FetchedRecordsCount) < pageSize {
+			return json.Marshal(parts)
+		}
+		bookmark = result.Bookmark
 	}
-	result := formatJSON(evaluateResult)
-	fmt.Printf("*** Result:%s\n", result)
-}
-
-func createPart(contract *client.Contract) {
-    partID := "IVSLAB-N23FA0004"
-    fmt.Printf("\n--> Submit Transaction: CreatePart, creates new part with PID, Manufacturer, ManufactureLocation, PartName, PartNumber, Organization\n")
-    _, err := contract.SubmitTransaction("CreatePart", partID, "Network.Co", "Taiwan", "NetworkChip-v1", "NPN3R1C00AA4", "Network-Org")
-    if err != nil {
-        fmt.Printf("failed to submit transaction: %s\n", err)
-        return
-    }
-    fmt.Printf("*** Transaction committed Part %s created successfully\n", partID)
-}
-
-//func createPart(contract *client.Contract) {
-//	fmt.Printf("\n--> Submit Transaction: CreatePart, creates new part with PID, Manufacturer, ManufactureLocation, PartName, PartNumber, Organization\n")
-//	_, err := contract.SubmitTransaction("CreatePart", "IVSLAB-N23FA0004", "Network.Co", "Taiwan", "NetworkChip-v1", "NPN3R1C00AA4", "Network-Org")
-//	if err != nil {
-//		fmt.Printf("failed to submit transaction: %s\n", err)
-//		return
-//	}
-//	fmt.Printf("*** Transaction committed Part part%d created successfully\n")
-//}
-
-// Submit transaction asynchronously, blocking until the transaction has been sent to the orderer, and allowing
-// this thread to process the chaincode response (e.g. update a UI) without waiting for the commit notification
-func transferPartAsync(contract *client.Contract) {
-    partID := "IVSLAB-N23FA0001"
-    fmt.Printf("\n--> Async Submit Transaction: TransferPart, changes existing part Organization and TransferDate")
-    submitResult, commit, err := contract.SubmitAsync("TransferPart", client.WithArguments(partID, "Brand-Org"))
-    if err != nil {
-        panic(fmt.Errorf("failed to submit transaction asynchronously: %w", err))
-    }
-
-    fmt.Printf("\n*** Successfully submitted transaction to transfer %s ownership from %s to Brand.Co. \n", partID, string(submitResult))
-    fmt.Println("*** Waiting for transaction commit.")
-
-    if commitStatus, err := commit.Status(); err != nil {
-        panic(fmt.Errorf("failed to get commit status: %w", err))
-    } else if !commitStatus.Successful {
-        panic(fmt.Errorf("transaction %s failed to commit with status: Brand.Co", commitStatus.TransactionID, int32(commitStatus.Code)))
-    }
-    fmt.Printf("*** Transaction committed successfully\n")
-}
-
-//func transferPartAsync(contract *client.Contract) {
-//	fmt.Printf("\n--> Async Submit Transaction: TransferPart, updates existing part Organization and TransferDate")
-//	submitResult, commit, err := contract.SubmitAsync("TransferPart", client.WithArguments("IVSLAB-N23FA0001", "Brand-Org"))
-//	if err != nil {
-//		panic(fmt.Errorf("failed to submit transaction asynchronously: %w", err))
-//	}
-//	fmt.Printf("\n*** Successfully submitted transaction to transfer ownership from %s to Brand.Co. \n", string(submitResult))
-//	fmt.Println("*** Waiting for transaction commit.")
-//	if commitStatus, err := commit.Status(); err != nil {
-//		panic(fmt.Errorf("failed to get commit status: %w", err))
-//	} else if !commitStatus.Successful {
-//		panic(fmt.Errorf("transaction %s failed to commit with status: Brand.Co", commitStatus.TransactionID, int32(commitStatus.Code)))
-//	}
-//	fmt.Printf("*** Transaction committed successfully\n")
-//}
-
-func transferPartsByOrganizationAsync(contract *client.Contract) {
-    organization := "CMOS-Org"
-    newOrganization := "Brand-Org"
-
-	fmt.Printf("\n--> Async Submit Transaction: TransferPartsByOrganization, transfers all parts from %s to %s\n", organization, newOrganization)
-    // Call TransferPartsByOrganization function asynchronously
-    _, commit, err := contract.SubmitAsync("TransferPartsByOrganization", client.WithArguments(organization, newOrganization))
-    if err != nil {
-        panic(fmt.Errorf("failed to submit transaction asynchronously: %w", err))
-    }
-
-    fmt.Printf("\n*** Successfully submitted transaction to transfer all parts from %s to %s. \n", organization, newOrganization)
-    fmt.Println("*** Waiting for transaction commit.")
-    commitStatus, err := commit.Status()
-    if err != nil {
-        panic(fmt.Errorf("failed to get commit status: %w", err))
-    } else if !commitStatus.Successful {
-        panic(fmt.Errorf("transaction %s failed to commit with status: %d", commitStatus.TransactionID, int32(commitStatus.Code)))
-    }
-
-    fmt.Printf("*** Transaction committed successfully\n")
-}
-
-// Submit a transaction synchronously, blocking until it has been committed to the ledger.
-func createAsset(contract *client.Contract) {
-	assetID := "IVSLAB-PVC23FG0002"
-	fmt.Printf("\n--> Submit Transaction: CreateAsset, creates new asset with ID, MadeBy, MadeIn, SerialNumber, SecurityChip, NetworkChip, CMOSChip, VideoCodecChip\n")
-	_, err := contract.SubmitTransaction("CreateAsset", assetID, "Brand.Co", "Taiwan", "IVSPN902300AACDC02", "IVSLAB-S23FA0002", "IVSLAB-N23FA0002", "IVSLAB-C23FA0002", "IVSLAB-V23FA0002")
+}
+
+// transferPart offers a part to another organization and returns the ID of the transfer offer.
+// The part moves when the receiving organization accepts the offer.
+func transferPart(contract transactor, partID string, newOrganization string) ([]byte, error) {
+	offerID, err := contract.SubmitTransaction("TransferPart", partID, newOrganization)
 	if err != nil {
-		fmt.Printf("failed to submit transaction: %s\n", err)
-		return
+		return nil, err
 	}
-	fmt.Printf("*** Transaction committed Asset %s created successfully\n", assetID)
+	return json.Marshal(map[string]string{"offerId": string(offerID)})
 }
 
-// Submit a transaction synchronously, blocking until it has been committed to the ledger.
-func updateAsset(contract *client.Contract) {
-	assetID := "IVSLAB-PVC23FG0002"
-	fmt.Printf("\n--> Submit Transaction: UpdateAsset, update asset with ID, MadeBy, MadeIn, SerialNumber, SecurityChip, NetworkChip, CMOSChip, VideoCodecChip\n")
-	_, err := contract.SubmitTransaction("UpdateAsset", assetID, "Brand.Co", "Taiwan", "IVSPN902300AACDC01", "IVSLAB-S23FA0003", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001")
+// transferPartsByOrganization offers every part of an organization to another one in a single offer
+func transferPartsByOrganization(contract transactor, organization string, newOrganization string) ([]byte, error) {
+	offerID, err := contract.SubmitTransaction("TransferPartsByOrganization", organization, newOrganization)
 	if err != nil {
-		fmt.Printf("failed to submit transaction: %s\n", err)
-		return
+		return nil, err
 	}
-	fmt.Printf("*** Transaction committed Asset %s updated successfully\n", assetID)
+	return json.Marshal(map[string]string{"offerId": string(offerID)})
+}
+
+// acceptPartTransfer moves the parts of an offer made to this client's organization
+func acceptPartTransfer(contract transactor, offerID string) ([]byte, error) {
+	return contract.SubmitTransaction("AcceptPartTransfer", offerID)
+}
+
+func getPendingTransferOffers(contract transactor, organization string) ([]byte, error) {
+	return contract.EvaluateTransaction("GetPendingTransferOffers", organization)
+}
+
+// getPartHistory returns every change of a part, most recent first
+func getPartHistory(contract transactor, partID string) ([]byte, error) {
+	return contract.EvaluateTransaction("GetPartHistory", partID)
+}
+
+// bomItem assigns a part to a slot of the asset's product model
+type bomItem struct {
+	Slot   string `json:"Slot"`
+	PartID string `json:"PartID"`
 }
 
-// Evaluate a transaction by partID to query ledger state.
-func readPartByID(contract *client.Contract) {
-	fmt.Printf("\n--> Evaluate Transaction: ReadPart, function returns part attributes\n")
-	evaluateResult, err := contract.EvaluateTransaction("ReadPart", "IVSLAB-S23FA0002")
+// createAsset assembles an asset of a product model from the parts listed in its bill of materials
+func createAsset(contract transactor, assetID string, modelID string, madeBy string, madeIn string, serialNumber string, components []bomItem) ([]byte, error) {
+	componentsBytes, err := json.Marshal(components)
 	if err != nil {
-		fmt.Printf("failed to submit transaction: %s\n", err)
-		return
+		return nil, err
 	}
-	result := formatJSON(evaluateResult)
-	fmt.Printf("*** Result:%s\n", result)
+	return contract.SubmitTransaction("CreateAsset", assetID, modelID, madeBy, madeIn, serialNumber, string(componentsBytes))
 }
 
-// Evaluate a transaction by assetID to query ledger state.
-func readAssetByID(contract *client.Contract) {
-	fmt.Printf("\n--> Evaluate Transaction: ReadAsset, function returns asset attributes\n")
-	evaluateResult, err := contract.EvaluateTransaction("ReadAsset", "IVSLAB-PVC23FG0001")
+// updateAsset replaces the brand, location, serial number and components of an asset
+func updateAsset(contract transactor, assetID string, madeBy string, madeIn string, serialNumber string, components []bomItem) ([]byte, error) {
+	componentsBytes, err := json.Marshal(components)
 	if err != nil {
-		fmt.Printf("failed to submit transaction: %s\n", err)
-		return
+		return nil, err
 	}
-	result := formatJSON(evaluateResult)
-	fmt.Printf("*** Result:%s\n", result)
+	return contract.SubmitTransaction("UpdateAsset", assetID, madeBy, madeIn, serialNumber, string(componentsBytes))
+}
+
+// updateAssetLocation moves an asset. The update fails with CONFLICT when the asset is no longer
+// at expectedRevision; a negative expectedRevision skips the check.
+func updateAssetLocation(contract transactor, assetID string, madeIn string, reason string, expectedRevision int) ([]byte, error) {
+	return contract.SubmitTransaction("UpdateAssetLocation", assetID, madeIn, reason, strconv.Itoa(expectedRevision))
+}
+
+// replaceAssetPart swaps a single part of an asset
+func replaceAssetPart(contract transactor, assetID string, oldPartID string, newPartID string, reason string, expectedRevision int) ([]byte, error) {
+	return contract.SubmitTransaction("ReplaceAssetPart", assetID, oldPartID, newPartID, reason, strconv.Itoa(expectedRevision))
+}
+
+// repairOrder mirrors the chaincode's RepairOrder input
+type repairOrder struct {
+	OrderID                string `json:"OrderID"`
+	AssetID                string `json:"AssetID"`
+	RemovedPartID          string `json:"RemovedPartID"`
+	ReplacementPartID      string `json:"ReplacementPartID"`
+	Disposition            string `json:"Disposition"`
+	TechnicianOrganization string `json:"TechnicianOrganization"`
+	Reason                 string `json:"Reason"`
 }
 
-func queryAssetsBySerialNumber(contract *client.Contract) {
-	fmt.Println("\n--> Evaluate Transaction: QueryAssetsBySerialNumber, function returns the current assets By SerialNumber on the ledger")
-	evaluateResult, err := contract.EvaluateTransaction("QueryAssetsBySerialNumber", "IVSPN902300AACDC01", "IVSPN902300AACDC02")
+// repairAsset swaps one part of a deployed asset and records the repair order
+func repairAsset(contract transactor, order repairOrder, expectedRevision int) ([]byte, error) {
+	orderBytes, err := json.Marshal(order)
 	if err != nil {
-		panic(fmt.Errorf("failed to evaluate transaction: %w", err))
+		return nil, err
 	}
-	// Add a check here for empty result
-	if len(evaluateResult) == 0 {
-		fmt.Println("*** No assets found for the specified Brand.Co")
-		return
+	return contract.SubmitTransaction("RepairAsset", string(orderBytes), strconv.Itoa(expectedRevision))
+}
+
+// decommissionAsset decommissions an asset. The asset stays on the ledger and its parts are
+// released, or scrapped when scrapParts is set.
+func decommissionAsset(contract transactor, assetID string, scrapParts bool, reason string) ([]byte, error) {
+	return contract.SubmitTransaction("DeleteAsset", assetID, strconv.FormatBool(scrapParts), reason)
+}
+
+func readAsset(contract transactor, assetID string) ([]byte, error) {
+	return contract.EvaluateTransaction("ReadAsset", assetID)
+}
+
+// readAssetBySerialNumber looks up an asset by the serial number of its brand
+func readAssetBySerialNumber(contract transactor, madeBy string, serialNumber string) ([]byte, error) {
+	return contract.EvaluateTransaction("ReadAssetBySerialNumber", madeBy, serialNumber)
+}
+
+// getAssets returns every asset, or the assets of one brand
+func getAssets(contract transactor, madeBy string) ([]byte, error) {
+	if madeBy == "" {
+		return contract.EvaluateTransaction("GetAllAssets")
 	}
-	result := formatJSON(evaluateResult)
-	fmt.Printf("*** Result:%s\n", result)
+	return contract.EvaluateTransaction("GetAssetsByBrand", madeBy)
+}
+
+// assetQuery mirrors the chaincode's typed asset filter, empty fields do not restrict the result
+type assetQuery struct {
+	MadeBy           string `json:"MadeBy,omitempty"`
+	MadeIn           string `json:"MadeIn,omitempty"`
+	SerialNumberFrom string `json:"SerialNumberFrom,omitempty"`
+	SerialNumberTo   string `json:"SerialNumberTo,omitempty"`
+	ProducedFrom     string `json:"ProducedFrom,omitempty"`
+	ProducedTo       string `json:"ProducedTo,omitempty"`
+	PartID           string `json:"PartID,omitempty"`
+	PartManufacturer string `json:"PartManufacturer,omitempty"`
+	SortBy           string `json:"SortBy,omitempty"`
+	SortDescending   bool   `json:"SortDescending,omitempty"`
 }
 
-func queryAssets(contract *client.Contract) {
-	fmt.Println("\n--> Evaluate Transaction: QueryAssets, function returns the current assets made by Brand.Co on the ledger")
-	queryString := "{\"selector\":{\"MadeBy\":\"Brand.Co\"}}"
-	evaluateResult, err := contract.EvaluateTransaction("QueryAssets", queryString)
+// searchAssets evaluates a typed asset query. Raw CouchDB selectors (QueryAssets) are restricted
+// to identities with ivs.admin=true.
+func searchAssets(contract transactor, query assetQuery) ([]byte, error) {
+	queryBytes, err := json.Marshal(query)
 	if err != nil {
-		fmt.Printf("failed to submit transaction: %s\n", err)
-		return
+		return nil, err
 	}
-	result := formatJSON(evaluateResult)
-	fmt.Printf("*** Result:%s\n", result)
+	return contract.EvaluateTransaction("SearchAssets", string(queryBytes))
+}
+
+// getAssetHistory returns every change of an asset, most recent first
+func getAssetHistory(contract transactor, assetID string) ([]byte, error) {
+	return contract.EvaluateTransaction("GetAssetHistory", assetID)
+}
+
+// getAssetProvenance returns the custody timeline of an asset and of every part it has contained, oldest change first
+func getAssetProvenance(contract transactor, assetID string) ([]byte, error) {
+	return contract.EvaluateTransaction("GetAssetProvenance", assetID)
+}
+
+// countPartsByOrganization reads composite keys and also works on LevelDB-backed peers
+func countPartsByOrganization(contract transactor) ([]byte, error) {
+	return contract.EvaluateTransaction("CountPartsByOrganization")
 }
 
-func getAssetHistory(contract *client.Contract) {
-	fmt.Println("\n--> Evaluate Transaction: GetAssetHistory, function returns all the current assets on the ledger")
-	evaluateResult, err := contract.EvaluateTransaction("GetAssetHistory", "IVSLAB-PVC23FG0001")
+// lot mirrors the chaincode's Lot input
+type lot struct {
+	LotID           string `json:"LotID"`
+	Manufacturer    string `json:"Manufacturer"`
+	Organization    string `json:"Organization"`
+	FabLocation     string `json:"FabLocation"`
+	Quantity        int    `json:"Quantity"`
+	ProductionStart string `json:"ProductionStart"`
+	ProductionEnd   string `json:"ProductionEnd"`
+	CertificateHash string `json:"CertificateHash,omitempty"`
+}
+
+// createLot registers a production lot. Parts join the lot through the LotID column of a manifest.
+func createLot(contract transactor, newLot lot) ([]byte, error) {
+	lotBytes, err := json.Marshal(newLot)
 	if err != nil {
-		fmt.Printf("failed to submit transaction: %s\n", err)
-		return
+		return nil, err
 	}
-	result := formatJSON(evaluateResult)
-	fmt.Printf("*** Result:%s\n", result)
+	return contract.SubmitTransaction("CreateLot", string(lotBytes))
 }
 
-// Submit transaction, passing in the wrong number of arguments ,expected to throw an error containing details of any error responses from the smart contract.
-func exampleErrorHandling(contract *client.Contract) {
-	fmt.Println("\n--> Submit Transaction: UpdateAsset IVSLAB-N23FA04, IVSLAB-N23FA04 does not exist and should return an error")
-	_, err := contract.SubmitTransaction("UpdateAsset", "IVSLAB-N23FA04", "Network.co", "Taiwan", "NetworkChip-v1", "NPN304AA", "SNN30A14AA", "Network-Org", "2023-05-15")
-	if err == nil {
-		panic("******** FAILED to return an error")
-	}
-	fmt.Println("*** Successfully caught the error:")
-	switch err := err.(type) {
-	case *client.EndorseError:
-		fmt.Printf("Endorse error for transaction %s with gRPC status %v: %s\n", err.TransactionID, status.Code(err), err)
-	case *client.SubmitError:
-		fmt.Printf("Submit error for transaction %s with gRPC status %v: %s\n", err.TransactionID, status.Code(err), err)
-	case *client.CommitStatusError:
-		if errors.Is(err, context.DeadlineExceeded) {
-			fmt.Printf("Timeout waiting for transaction %s commit status: %s", err.TransactionID, err)
-		} else {
-			fmt.Printf("Error obtaining commit status for transaction %s with gRPC status %v: %s\n", err.TransactionID, status.Code(err), err)
-		}
-	case *client.CommitError:
-		fmt.Printf("Transaction %s failed to commit with status %d: %s\n", err.TransactionID, int32(err.Code), err)
-	default:
-		panic(fmt.Errorf("unexpected error type %T: %w", err, err))
-	}
+func readLot(contract transactor, lotID string) ([]byte, error) {
+	return contract.EvaluateTransaction("ReadLot", lotID)
+}
 
-	// Any error that originates from a peer or orderer node external to the gateway will have its details
-	// embedded within the gRPC status error. The following code shows how to extract that.
-	statusErr := status.Convert(err)
-	details := statusErr.Details()
-	if len(details) > 0 {
-		fmt.Println("Error Details:")
-		for _, detail := range details {
-			switch detail := detail.(type) {
-			case *gateway.ErrorDetail:
-				fmt.Printf("- address: %s, mspId: %s, message: %s\n", detail.Address, detail.MspId, detail.Message)
-			}
-		}
-	}
+func getPartsByLot(contract transactor, lotID string) ([]byte, error) {
+	return contract.EvaluateTransaction("GetPartsByLot", lotID)
+}
+
+// getAssetsByLot traces a defective lot to every camera that contains one of its parts, e.g. for a recall
+func getAssetsByLot(contract transactor, lotID string) ([]byte, error) {
+	return contract.EvaluateTransaction("GetAssetsByLot", lotID)
 }
 
 // Format JSON data
@@ -0,0 +1,142 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// minTokenLength is the shortest accepted bearer token, e.g. 32 hex digits of "openssl rand -hex 16"
+const minTokenLength = 32
+
+// apiToken grants the bearer of a token the use of wallet identities on the REST API
+type apiToken struct {
+	Name       string   `yaml:"name" json:"name"`             // 用戶端名稱, 記錄於日誌
+	Token      string   `yaml:"token" json:"token"`           // 持有者權杖
+	Identities []string `yaml:"identities" json:"identities"` // 可使用之錢包身分, 第一個為預設身分
+}
+
+// apiTokenFile is the file of the bearer tokens of the REST API clients
+type apiTokenFile struct {
+	Tokens []apiToken `yaml:"tokens" json:"tokens"`
+}
+
+// authenticator authenticates the requests of the REST API with bearer tokens. Tokens are looked up
+// by their SHA-256 hash, so that the lookup time does not reveal how much of a token matched.
+type authenticator struct {
+	tokens map[[sha256.Size]byte]*apiToken
+}
+
+// readTokenFile parses a .yaml, .yml or .json file of bearer tokens
+func readTokenFile(filename string) (*authenticator, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	var file apiTokenFile
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".json":
+		err = json.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("token file %s must be a .yaml, .yml or .json file", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token file %s: %w", filename, err)
+	}
+	if len(file.Tokens) == 0 {
+		return nil, fmt.Errorf("token file %s defines no tokens", filename)
+	}
+
+	auth := &authenticator{tokens: make(map[[sha256.Size]byte]*apiToken)}
+	for i := range file.Tokens {
+		token := &file.Tokens[i]
+		if token.Name == "" {
+			token.Name = fmt.Sprintf("token %d", i+1)
+		}
+		if len(token.Token) < minTokenLength {
+			return nil, fmt.Errorf("%s of token file %s is shorter than %d characters", token.Name, filename, minTokenLength)
+		}
+		for _, label := range token.Identities {
+			if err := checkLabel(label); err != nil {
+				return nil, fmt.Errorf("%s of token file %s: %w", token.Name, filename, err)
+			}
+		}
+		hash := sha256.Sum256([]byte(token.Token))
+		if _, ok := auth.tokens[hash]; ok {
+			return nil, fmt.Errorf("%s of token file %s repeats an earlier token", token.Name, filename)
+		}
+		auth.tokens[hash] = token
+	}
+	return auth, nil
+}
+
+// caller is the client of an authorized request
+type caller struct {
+	client string // name of the bearer token, empty on a server without tokens
+	label  string // wallet identity the request is made with, empty for the connection profile identity
+}
+
+// authorize returns the caller of a request, or the HTTP status and error that reject the request.
+// A bearer token may use the identities listed for it and defaults to the first one; a token
+// without identities uses the identity of the connection profile. Without tokens every request
+// uses the identity of the connection profile.
+func (a *authenticator) authorize(r *http.Request) (caller, int, *apiError) {
+	label := r.Header.Get(identityHeader)
+	if a == nil {
+		if label != "" {
+			return caller{}, http.StatusForbidden, &apiError{Code: errCodeForbidden, Field: identityHeader,
+				Message: "the server has no bearer tokens and makes every request with the identity of its connection profile"}
+		}
+		return caller{}, 0, nil
+	}
+
+	scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || credentials == "" {
+		return caller{}, http.StatusUnauthorized, &apiError{Code: apiCodeUnauthenticated, Message: "a bearer token is required"}
+	}
+	token, ok := a.tokens[sha256.Sum256([]byte(strings.TrimSpace(credentials)))]
+	if !ok {
+		return caller{}, http.StatusUnauthorized, &apiError{Code: apiCodeUnauthenticated, Message: "the bearer token is not valid"}
+	}
+
+	if label == "" {
+		if len(token.Identities) == 0 {
+			return caller{client: token.Name}, 0, nil
+		}
+		return caller{client: token.Name, label: token.Identities[0]}, 0, nil
+	}
+	for _, allowed := range token.Identities {
+		if label == allowed {
+			return caller{client: token.Name, label: label}, 0, nil
+		}
+	}
+	return caller{}, http.StatusForbidden, &apiError{Code: errCodeForbidden, Field: identityHeader,
+		Message: fmt.Sprintf("%s may not use the identity %q", token.Name, label)}
+}
+
+// isLoopback reports whether address only accepts connections from the local host
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
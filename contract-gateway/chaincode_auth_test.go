@@ -0,0 +1,188 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	erpToken     = "erp-0123456789abcdef0123456789abcdef"
+	serviceToken = "service-0123456789abcdef0123456789abcdef"
+	profileToken = "profile-0123456789abcdef0123456789abcdef"
+)
+
+// writeTokenFile writes a token file to a temporary directory
+func writeTokenFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// testAuthenticator returns an authenticator with the tokens of an ERP system, a service desk and
+// a client of the profile identity
+func testAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+	auth, err := readTokenFile(writeTokenFile(t, "tokens.yaml", `
+tokens:
+  - name: erp
+    token: `+erpToken+`
+    identities: [user1]
+  - name: service-desk
+    token: `+serviceToken+`
+    identities: [technician1, user1]
+  - token: `+profileToken+`
+`))
+	if err != nil {
+		t.Fatalf("readTokenFile failed: %v", err)
+	}
+	return auth
+}
+
+func TestReadTokenFile(t *testing.T) {
+	auth, err := readTokenFile("api-tokens.example.yaml")
+	if err != nil || len(auth.tokens) != 2 {
+		t.Fatalf("failed to read the example token file: %v", err)
+	}
+	jsonFile := writeTokenFile(t, "tokens.json", `{"tokens":[{"name":"erp","token":"`+erpToken+`","identities":["user1"]}]}`)
+	if _, err := readTokenFile(jsonFile); err != nil {
+		t.Fatalf("failed to read a JSON token file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"no tokens", "tokens.yaml", "tokens: []", "defines no tokens"},
+		{"short token", "tokens.yaml", "tokens: [{name: erp, token: secret}]", "erp of token file"},
+		{"repeated token", "tokens.yaml", "tokens: [{token: " + erpToken + "}, {token: " + erpToken + "}]", "token 2 of token file"},
+		{"invalid identity", "tokens.yaml", "tokens: [{name: erp, token: " + erpToken + ", identities: [../user1]}]", `invalid identity label "../user1"`},
+		{"unsupported file", "tokens.txt", erpToken, "must be a .yaml, .yml or .json file"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := readTokenFile(writeTokenFile(t, test.file, test.content))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	auth := testAuthenticator(t)
+	tests := []struct {
+		name          string
+		auth          *authenticator
+		authorization string
+		identity      string
+		caller        caller
+		status        int
+		code          string
+	}{
+		{name: "default identity of the token", auth: auth, authorization: "Bearer " + erpToken, caller: caller{client: "erp", label: "user1"}},
+		{name: "listed identity", auth: auth, authorization: "Bearer " + serviceToken, identity: "technician1", caller: caller{client: "service-desk", label: "technician1"}},
+		{name: "scheme in lower case", auth: auth, authorization: "bearer " + serviceToken, identity: "user1", caller: caller{client: "service-desk", label: "user1"}},
+		{name: "token without identities", auth: auth, authorization: "Bearer " + profileToken, caller: caller{client: "token 3"}},
+		{name: "identity not listed", auth: auth, authorization: "Bearer " + erpToken, identity: "technician1", status: http.StatusForbidden, code: errCodeForbidden},
+		{name: "identity with a token without identities", auth: auth, authorization: "Bearer " + profileToken, identity: "user1", status: http.StatusForbidden, code: errCodeForbidden},
+		{name: "missing token", auth: auth, identity: "user1", status: http.StatusUnauthorized, code: apiCodeUnauthenticated},
+		{name: "unknown token", auth: auth, authorization: "Bearer " + strings.ToUpper(erpToken), status: http.StatusUnauthorized, code: apiCodeUnauthenticated},
+		{name: "basic authentication", auth: auth, authorization: "Basic dXNlcjE6c2VjcmV0", status: http.StatusUnauthorized, code: apiCodeUnauthenticated},
+		{name: "no tokens"},
+		{name: "identity without tokens", identity: "user1", status: http.StatusForbidden, code: errCodeForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/parts", nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			if test.identity != "" {
+				r.Header.Set(identityHeader, test.identity)
+			}
+			got, httpStatus, apiErr := test.auth.authorize(r)
+			if test.code == "" {
+				if apiErr != nil || got != test.caller {
+					t.Fatalf("got caller %+v and error %+v, want %+v", got, apiErr, test.caller)
+				}
+				return
+			}
+			if apiErr == nil || httpStatus != test.status || apiErr.Code != test.code {
+				t.Fatalf("got %d %+v, want %d with code %s", httpStatus, apiErr, test.status, test.code)
+			}
+		})
+	}
+}
+
+func TestServeHTTPAuthenticates(t *testing.T) {
+	// the requests are rejected before a Gateway connection is needed
+	server := newAPIServer(nil, testAuthenticator(t))
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		identity      string
+		status        int
+		code          string
+	}{
+		{name: "missing token", path: "/parts/IVSLAB-S23FA0001", status: http.StatusUnauthorized, code: apiCodeUnauthenticated},
+		{name: "identity not listed", path: "/parts/IVSLAB-S23FA0001", authorization: "Bearer " + erpToken, identity: "admin", status: http.StatusForbidden, code: errCodeForbidden},
+		{name: "unknown route first", path: "/cameras", status: http.StatusNotFound, code: errCodeNotFound},
+		{name: "OpenAPI document", path: "/openapi.yaml", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			if test.identity != "" {
+				r.Header.Set(identityHeader, test.identity)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, r)
+			if w.Code != test.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, test.status, w.Body.String())
+			}
+			if test.code == "" {
+				return
+			}
+			var body apiError
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != test.code {
+				t.Fatalf("got body %s, want code %s", w.Body.String(), test.code)
+			}
+			if authenticate := w.Header().Get("WWW-Authenticate"); (test.status == http.StatusUnauthorized) != (authenticate != "") {
+				t.Errorf("unexpected WWW-Authenticate header %q", authenticate)
+			}
+		})
+	}
+}
+
+func TestServeAPIRequiresTokensOffLoopback(t *testing.T) {
+	for _, address := range []string{":8080", "0.0.0.0:8080", "192.0.2.10:8080"} {
+		err := serveAPI(context.Background(), nil, nil, address)
+		if err == nil || !strings.Contains(err.Error(), "only with bearer tokens") {
+			t.Errorf("expected serving on %s without tokens to be refused, got %v", address, err)
+		}
+	}
+	for _, address := range []string{"127.0.0.1:8080", "localhost:8080", "[::1]:8080"} {
+		if !isLoopback(address) {
+			t.Errorf("expected %s to be a loopback address", address)
+		}
+	}
+}
@@ -0,0 +1,632 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+)
+
+// runner runs a command with its positional arguments and returns the JSON result to print. A
+// result returned together with an error reports what the command did before it failed.
+type runner func(s *session, args []string) ([]byte, error)
+
+// command is a subcommand of the contract gateway, e.g. "part get"
+type command struct {
+	group    string
+	name     string
+	args     string // synopsis of the positional arguments
+	summary  string
+	minArgs  int
+	maxArgs  int      // -1 for any number of arguments
+	columns  []string // table columns of the result
+	local    bool     // runs without a Gateway connection
+	unsigned bool     // connects without the private key, the requests are signed offline
+	// setup registers the flags of the command and returns its runner
+	setup func(flags *flag.FlagSet) runner
+}
+
+func (c *command) String() string {
+	return c.group + " " + c.name
+}
+
+// usageError is returned by a runner when its arguments are invalid
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
+
+// commandAliases keeps the commands of earlier versions working
+var commandAliases = map[string][]string{
+	"listen":         {"ledger", "listen"},
+	"register-parts": {"part", "register"},
+	"serve":          {"api", "serve"},
+	"sign":           {"offline", "sign"},
+}
+
+// commands lists every command of the contract gateway, in the order of the usage message
+var commands = []*command{
+	{group: "part", name: "create", args: "<PID> <Manufacturer> <ManufactureLocation> <PartName> <PartNumber> <Organization>", summary: "register a part of this chip maker", minArgs: 6, maxArgs: 6, columns: partColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			lotID := flags.String("lot", "", "production lot of the part")
+			return func(s *session, args []string) ([]byte, error) {
+				part := partDefinition{PID: args[0], Manufacturer: args[1], ManufactureLocation: args[2], PartName: args[3], PartNumber: args[4], Organization: args[5], LotID: *lotID}
+				if _, err := createPart(s.contract, part); err != nil {
+					return nil, err
+				}
+				return readPart(s.contract, part.PID)
+			}
+		}},
+	{group: "part", name: "get", args: "<PartID>", summary: "show a part", minArgs: 1, maxArgs: 1, columns: partColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return readPart(s.contract, args[0])
+			}
+		}},
+	{group: "part", name: "list", summary: "list every part, or the parts of an organization", columns: partColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			organization := flags.String("org", "", "list the parts of this organization only")
+			pageSize := flags.Int("page-size", 100, "parts fetched per query when listing an organization")
+			return func(s *session, args []string) ([]byte, error) {
+				if *organization == "" {
+					return getAllParts(s.contract)
+				}
+				if *pageSize < 1 {
+					return nil, usageErrorf("-page-size must be positive, got %d", *pageSize)
+				}
+				return getPartsByOrganization(s.contract, *organization, *pageSize)
+			}
+		}},
+	{group: "part", name: "transfer", args: "<PartID> <NewOrganization>", summary: "offer a part to another organization", minArgs: 2, maxArgs: 2,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return transferPart(s.contract, args[0], args[1])
+			}
+		}},
+	{group: "part", name: "accept", args: "<OfferID>", summary: "accept a transfer offer made to this organization", minArgs: 1, maxArgs: 1,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return acceptPartTransfer(s.contract, args[0])
+			}
+		}},
+	{group: "part", name: "offers", args: "<Organization>", summary: "list the pending transfer offers made to an organization", minArgs: 1, maxArgs: 1, columns: offerColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return getPendingTransferOffers(s.contract, args[0])
+			}
+		}},
+	{group: "part", name: "history", args: "<PartID>", summary: "show every change of a part, most recent first", minArgs: 1, maxArgs: 1, columns: partHistoryColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return getPartHistory(s.contract, args[0])
+			}
+		}},
+	{group: "part", name: "register", args: "<manifest> [chunk size]", summary: "register the parts of a .json or .csv manifest", minArgs: 1, maxArgs: 2,
+		setup: func(flags *flag.FlagSet) runner {
+			from := flags.Int("from", 0, "index of the first manifest part to register, to resume after a rejected chunk")
+			return func(s *session, args []string) ([]byte, error) {
+				chunkSize := defaultChunkSize
+				if len(args) > 1 {
+					var err error
+					chunkSize, err = strconv.Atoi(args[1])
+					if err != nil {
+						return nil, usageErrorf("invalid chunk size %q", args[1])
+					}
+				}
+				return registerPartsFromManifest(s.contract, args[0], chunkSize, *from)
+			}
+		}},
+
+	{group: "asset", name: "create", args: "<AssetID> <MadeBy> <MadeIn> <SerialNumber> <Slot=PartID>...", summary: "assemble an asset from parts of this brand", minArgs: 5, maxArgs: -1, columns: assetColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			modelID := flags.String("model", "IVSLAB-PVC-V1", "product model of the asset")
+			return func(s *session, args []string) ([]byte, error) {
+				components, err := parseComponents(args[4:])
+				if err != nil {
+					return nil, err
+				}
+				if _, err := createAsset(s.contract, args[0], *modelID, args[1], args[2], args[3], components); err != nil {
+					return nil, err
+				}
+				return readAsset(s.contract, args[0])
+			}
+		}},
+	{group: "asset", name: "get", args: "[AssetID]", summary: "show an asset by ID, or by brand and serial number", maxArgs: 1, columns: assetColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			madeBy := flags.String("made-by", "", "brand of the asset, with -serial")
+			serialNumber := flags.String("serial", "", "serial number of the asset, with -made-by")
+			return func(s *session, args []string) ([]byte, error) {
+				if len(args) == 1 {
+					return readAsset(s.contract, args[0])
+				}
+				if *madeBy == "" || *serialNumber == "" {
+					return nil, usageErrorf("either an asset ID or both -made-by and -serial are required")
+				}
+				return readAssetBySerialNumber(s.contract, *madeBy, *serialNumber)
+			}
+		}},
+	{group: "asset", name: "update", args: "<AssetID> <MadeBy> <MadeIn> <SerialNumber> <Slot=PartID>...", summary: "replace the brand, location, serial number and parts of an asset", minArgs: 5, maxArgs: -1, columns: assetColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				components, err := parseComponents(args[4:])
+				if err != nil {
+					return nil, err
+				}
+				if _, err := updateAsset(s.contract, args[0], args[1], args[2], args[3], components); err != nil {
+					return nil, err
+				}
+				return readAsset(s.contract, args[0])
+			}
+		}},
+	{group: "asset", name: "list", summary: "list every asset, or the assets of a brand", columns: assetColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			madeBy := flags.String("made-by", "", "list the assets of this brand only")
+			return func(s *session, args []string) ([]byte, error) {
+				return getAssets(s.contract, *madeBy)
+			}
+		}},
+	{group: "asset", name: "query", summary: "search assets by brand, location, serial number, production date or part", columns: assetColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			var query assetQuery
+			flags.StringVar(&query.MadeBy, "made-by", "", "brand")
+			flags.StringVar(&query.MadeIn, "made-in", "", "location")
+			flags.StringVar(&query.SerialNumberFrom, "serial-from", "", "first serial number")
+			flags.StringVar(&query.SerialNumberTo, "serial-to", "", "last serial number")
+			flags.StringVar(&query.ProducedFrom, "produced-from", "", "earliest production date (RFC3339)")
+			flags.StringVar(&query.ProducedTo, "produced-to", "", "latest production date (RFC3339)")
+			flags.StringVar(&query.PartID, "part", "", "ID of a part the asset contains")
+			flags.StringVar(&query.PartManufacturer, "part-manufacturer", "", "manufacturer of a part the asset contains")
+			flags.StringVar(&query.SortBy, "sort-by", "", "field to sort by")
+			flags.BoolVar(&query.SortDescending, "desc", false, "sort in descending order")
+			return func(s *session, args []string) ([]byte, error) {
+				return searchAssets(s.contract, query)
+			}
+		}},
+	{group: "asset", name: "history", args: "<AssetID>", summary: "show every change of an asset, most recent first", minArgs: 1, maxArgs: 1, columns: assetHistoryColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return getAssetHistory(s.contract, args[0])
+			}
+		}},
+	{group: "asset", name: "provenance", args: "<AssetID>", summary: "show the custody timeline of an asset and its parts", minArgs: 1, maxArgs: 1, columns: provenanceColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return getAssetProvenance(s.contract, args[0])
+			}
+		}},
+	{group: "asset", name: "relocate", args: "<AssetID> <MadeIn>", summary: "move an asset", minArgs: 2, maxArgs: 2, columns: assetColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			reason := flags.String("reason", "RELOCATION", "reason code of the change")
+			revision := flags.Int("revision", -1, "expected revision of the asset, -1 skips the check")
+			return func(s *session, args []string) ([]byte, error) {
+				if _, err := updateAssetLocation(s.contract, args[0], args[1], *reason, *revision); err != nil {
+					return nil, err
+				}
+				return readAsset(s.contract, args[0])
+			}
+		}},
+	{group: "asset", name: "replace-part", args: "<AssetID> <OldPartID> <NewPartID>", summary: "swap a part of an asset", minArgs: 3, maxArgs: 3, columns: assetColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			reason := flags.String("reason", "PART_REPLACEMENT", "reason code of the change")
+			revision := flags.Int("revision", -1, "expected revision of the asset, -1 skips the check")
+			return func(s *session, args []string) ([]byte, error) {
+				if _, err := replaceAssetPart(s.contract, args[0], args[1], args[2], *reason, *revision); err != nil {
+					return nil, err
+				}
+				return readAsset(s.contract, args[0])
+			}
+		}},
+	{group: "asset", name: "repair", args: "<OrderID> <AssetID> <RemovedPartID> <ReplacementPartID>", summary: "swap a part of a deployed asset and record the repair order", minArgs: 4, maxArgs: 4, columns: assetColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			disposition := flags.String("disposition", "returned", "state of the removed part, returned or scrapped")
+			technician := flags.String("technician", "", "organization of the technician (required)")
+			reason := flags.String("reason", "", "reason of the repair (required)")
+			revision := flags.Int("revision", -1, "expected revision of the asset, -1 skips the check")
+			return func(s *session, args []string) ([]byte, error) {
+				order := repairOrder{
+					OrderID:                args[0],
+					AssetID:                args[1],
+					RemovedPartID:          args[2],
+					ReplacementPartID:      args[3],
+					Disposition:            *disposition,
+					TechnicianOrganization: *technician,
+					Reason:                 *reason,
+				}
+				if _, err := repairAsset(s.contract, order, *revision); err != nil {
+					return nil, err
+				}
+				return readAsset(s.contract, order.AssetID)
+			}
+		}},
+	{group: "asset", name: "decommission", args: "<AssetID>", summary: "decommission an asset and release or scrap its parts", minArgs: 1, maxArgs: 1, columns: assetColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			scrapParts := flags.Bool("scrap-parts", false, "scrap the parts instead of releasing them")
+			reason := flags.String("reason", "end of life", "reason of the decommissioning")
+			return func(s *session, args []string) ([]byte, error) {
+				if _, err := decommissionAsset(s.contract, args[0], *scrapParts, *reason); err != nil {
+					return nil, err
+				}
+				return readAsset(s.contract, args[0])
+			}
+		}},
+
+	{group: "org", name: "transfer-all", args: "<Organization> <NewOrganization>", summary: "offer every part of an organization to another one", minArgs: 2, maxArgs: 2,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return transferPartsByOrganization(s.contract, args[0], args[1])
+			}
+		}},
+	{group: "org", name: "inventory", summary: "count the parts of every organization", columns: inventoryColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return countPartsByOrganization(s.contract)
+			}
+		}},
+
+	{group: "lot", name: "create", args: "<LotID> <Manufacturer> <Organization> <FabLocation> <Quantity> <ProductionStart> <ProductionEnd>", summary: "register a production lot", minArgs: 7, maxArgs: 7, columns: lotColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			certificateHash := flags.String("certificate-hash", "", "SHA-256 hash of the quality certificate")
+			return func(s *session, args []string) ([]byte, error) {
+				quantity, err := strconv.Atoi(args[4])
+				if err != nil {
+					return nil, usageErrorf("invalid quantity %q", args[4])
+				}
+				newLot := lot{LotID: args[0], Manufacturer: args[1], Organization: args[2], FabLocation: args[3], Quantity: quantity, ProductionStart: args[5], ProductionEnd: args[6], CertificateHash: *certificateHash}
+				if _, err := createLot(s.contract, newLot); err != nil {
+					return nil, err
+				}
+				return readLot(s.contract, newLot.LotID)
+			}
+		}},
+	{group: "lot", name: "get", args: "<LotID>", summary: "show a production lot", minArgs: 1, maxArgs: 1, columns: lotColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return readLot(s.contract, args[0])
+			}
+		}},
+	{group: "lot", name: "parts", args: "<LotID>", summary: "list the parts of a production lot", minArgs: 1, maxArgs: 1, columns: partColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return getPartsByLot(s.contract, args[0])
+			}
+		}},
+	{group: "lot", name: "assets", args: "<LotID>", summary: "list the assets that contain a part of a production lot", minArgs: 1, maxArgs: 1, columns: assetColumns,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return getAssetsByLot(s.contract, args[0])
+			}
+		}},
+
+	{group: "wallet", name: "list", summary: "list the identities of the wallet", columns: walletColumns, local: true,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				w, err := openWallet(s.profile)
+				if err != nil {
+					return nil, err
+				}
+				labels, err := w.List()
+				if err != nil {
+					return nil, err
+				}
+				identities := make([]*identityInfo, 0, len(labels))
+				for _, label := range labels {
+					id, err := w.Get(label)
+					if err != nil {
+						return nil, err
+					}
+					info, err := describeIdentity(label, id)
+					if err != nil {
+						return nil, err
+					}
+					identities = append(identities, info)
+				}
+				return json.Marshal(identities)
+			}
+		}},
+	{group: "wallet", name: "import", args: "<label> <certificate> <private key>", summary: "store a certificate and its private key in the wallet, -msp-id names their MSP", minArgs: 3, maxArgs: 3, columns: walletColumns, local: true,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				if s.profile.MSPID == "" {
+					return nil, usageErrorf("the MSP ID of the identity is required, use -msp-id or IVS_MSP_ID")
+				}
+				info, err := importIdentity(s.profile, args[0], s.profile.MSPID, args[1], args[2])
+				if err != nil {
+					return nil, err
+				}
+				return json.Marshal(info)
+			}
+		}},
+	{group: "wallet", name: "remove", args: "<label>", summary: "remove an identity from the wallet", minArgs: 1, maxArgs: 1, local: true,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				w, err := openWallet(s.profile)
+				if err != nil {
+					return nil, err
+				}
+				return nil, w.Remove(args[0])
+			}
+		}},
+
+	{group: "offline", name: "propose", args: "<file> <transaction> [arguments...]", summary: "write the unsigned proposal of a transaction to a request file, without the private key", minArgs: 2, maxArgs: -1, unsigned: true,
+		setup: func(flags *flag.FlagSet) runner {
+			evaluate := flags.Bool("evaluate", false, "evaluate the transaction instead of submitting it")
+			return func(s *session, args []string) ([]byte, error) {
+				return proposeOffline(s.contract, args[0], *evaluate, args[1], args[2:]...)
+			}
+		}},
+	{group: "offline", name: "sign", args: "<file>", summary: "sign a request file with the private key, on the signing host", minArgs: 1, maxArgs: 1, local: true,
+		setup: func(flags *flag.FlagSet) runner {
+			digest := flags.String("digest", "", "sign only if the request has this digest, as shown on the networked host")
+			return func(s *session, args []string) ([]byte, error) {
+				signer, err := loadSigner(s.profile, "")
+				if err != nil {
+					return nil, err
+				}
+				return signOffline(signer, args[0], *digest)
+			}
+		}},
+	{group: "offline", name: "submit", args: "<file>", summary: "send a signed request file, leaving the next request to sign in it", minArgs: 1, maxArgs: 1, unsigned: true,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return submitOffline(s.gateway, args[0])
+			}
+		}},
+	{group: "offline", name: "show", args: "<file>", summary: "show the transaction, digest and stage of a request file", minArgs: 1, maxArgs: 1, local: true, unsigned: true,
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return showOffline(args[0])
+			}
+		}},
+
+	{group: "api", name: "serve", summary: "serve the REST API until interrupted, see /openapi.yaml; clients authenticate with the bearer tokens of -tokens",
+		setup: func(flags *flag.FlagSet) runner {
+			address := flags.String("listen", "127.0.0.1:8080", "address to serve the REST API on, overrides IVS_API_ADDRESS; other than loopback addresses require -tokens")
+			tokensFile := flags.String("tokens", "", "file of the bearer tokens of the clients and the wallet identities they may use, overrides IVS_API_TOKENS; without it every request uses the identity of the profile")
+			return func(s *session, args []string) ([]byte, error) {
+				if !isFlagSet(flags, "listen") {
+					if value := os.Getenv("IVS_API_ADDRESS"); value != "" {
+						*address = value
+					}
+				}
+				if !isFlagSet(flags, "tokens") {
+					*tokensFile = os.Getenv("IVS_API_TOKENS")
+				}
+				var auth *authenticator
+				if *tokensFile != "" {
+					var err error
+					if auth, err = readTokenFile(*tokensFile); err != nil {
+						return nil, usageErrorf("%v", err)
+					}
+				}
+				ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+				defer cancel()
+				return nil, serveAPI(ctx, s.connections, auth, *address)
+			}
+		}},
+
+	{group: "ledger", name: "init", summary: "create the initial parts and product model",
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return initLedger(s.contract)
+			}
+		}},
+	{group: "ledger", name: "migrate-keys", summary: "move parts and assets of earlier chaincode versions to prefixed keys and mark their installed parts",
+		setup: func(flags *flag.FlagSet) runner {
+			limit := flags.Int("limit", 100, "records moved per transaction")
+			return func(s *session, args []string) ([]byte, error) {
+				if *limit < 1 {
+					return nil, usageErrorf("-limit must be positive, got %d", *limit)
+				}
+				return migrateLedgerKeys(s.contract, *limit)
+			}
+		}},
+	{group: "ledger", name: "rebuild-indexes", summary: "reconcile the composite key indexes with the ledger (ivs.admin only)",
+		setup: func(flags *flag.FlagSet) runner {
+			return func(s *session, args []string) ([]byte, error) {
+				return rebuildIndexes(s.contract)
+			}
+		}},
+	{group: "ledger", name: "listen", summary: "print chaincode events until interrupted",
+		setup: func(flags *flag.FlagSet) runner {
+			checkpointFile := flags.String("checkpoint", "checkpoint.json", "file storing the last processed event, overrides CHECKPOINT_FILE")
+			return func(s *session, args []string) ([]byte, error) {
+				if !isFlagSet(flags, "checkpoint") {
+					if cpfile := os.Getenv("CHECKPOINT_FILE"); cpfile != "" {
+						*checkpointFile = cpfile
+					}
+				}
+				ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+				defer cancel()
+				if err := listenChaincodeEvents(ctx, s.network, s.profile.Chaincode, *checkpointFile); err != nil && !errors.Is(err, context.Canceled) {
+					return nil, err
+				}
+				return nil, nil
+			}
+		}},
+}
+
+// runCLI runs the command given by args and returns the exit code of the process
+func runCLI(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("contract-gateway", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	defaultOutput := outputJSON
+	if value := os.Getenv("IVS_OUTPUT"); value != "" {
+		defaultOutput = value
+	}
+	output := flags.String("output", defaultOutput, "output format json, table or yaml, overrides IVS_OUTPUT")
+	flags.Usage = func() {
+		printUsage(stderr, flags)
+	}
+
+	config := newConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	args = flags.Args()
+	if len(args) == 0 || args[0] == "help" {
+		printUsage(stderr, flags)
+		return exitUsage
+	}
+
+	cmd, args := findCommand(args)
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", strings.Join(args, " "))
+		printUsage(stderr, flags)
+		return exitUsage
+	}
+	cmdFlags := flag.NewFlagSet(cmd.String(), flag.ContinueOnError)
+	cmdFlags.SetOutput(stderr)
+	cmdFlags.StringVar(output, "output", *output, "output format json, table or yaml")
+	run := cmd.setup(cmdFlags)
+	cmdFlags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: contract-gateway %s\n\n%s\n\nflags:\n", strings.TrimSpace(cmd.String()+" [flags] "+cmd.args), cmd.summary)
+		cmdFlags.PrintDefaults()
+	}
+	args, err := parseArgs(cmdFlags, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		fmt.Fprintf(stderr, "wrong number of arguments for %s\n\n", cmd)
+		cmdFlags.Usage()
+		return exitUsage
+	}
+	if err := validOutput(*output); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	profile, err := config.loadConfig(!cmd.local, !cmd.unsigned)
+	if err != nil {
+		fmt.Fprintf(stderr, "configuration error: %s\n", err)
+		return exitUsage
+	}
+	s := &session{profile: profile}
+	if !cmd.local {
+		conns := newConnections(profile)
+		conns.unsigned = cmd.unsigned
+		defer conns.Close()
+		s, err = conns.session("")
+		if err != nil {
+			fmt.Fprintf(stderr, "identity error: %s\n", err)
+			return exitUsage
+		}
+	}
+
+	result, err := run(s, args)
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintln(stderr, usageErr)
+		return exitUsage
+	}
+	if err != nil {
+		if writeErr := writeOutput(stdout, *output, result, cmd.columns); writeErr != nil {
+			fmt.Fprintf(stderr, "failed to print the result: %s\n", writeErr)
+		}
+		printError(stderr, err)
+		return exitCode(err)
+	}
+	if err := writeOutput(stdout, *output, result, cmd.columns); err != nil {
+		fmt.Fprintf(stderr, "failed to print the result: %s\n", err)
+		return exitFailure
+	}
+
+	return exitOK
+}
+
+// findCommand returns the command named by the first arguments and the arguments after its name,
+// or nil and the unknown command name
+func findCommand(args []string) (*command, []string) {
+	if alias, ok := commandAliases[args[0]]; ok {
+		args = append(append([]string{}, alias...), args[1:]...)
+	}
+	if len(args) < 2 {
+		return nil, args
+	}
+	for _, cmd := range commands {
+		if cmd.group == args[0] && cmd.name == args[1] {
+			return cmd, args[2:]
+		}
+	}
+	return nil, args[:2]
+}
+
+// parseArgs parses the flags of a command and returns its positional arguments. Flags may precede
+// or follow the arguments, e.g. "part get IVSLAB-S23FA0001 -output table"; arguments after "--"
+// are never taken as flags.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// parseComponents parses bill of materials items given as Slot=PartID
+func parseComponents(args []string) ([]bomItem, error) {
+	components := make([]bomItem, 0, len(args))
+	for _, arg := range args {
+		slot, partID, ok := strings.Cut(arg, "=")
+		if !ok || slot == "" || partID == "" {
+			return nil, usageErrorf("component %q must be given as Slot=PartID", arg)
+		}
+		components = append(components, bomItem{Slot: slot, PartID: partID})
+	}
+	return components, nil
+}
+
+// isFlagSet reports whether a flag was given on the command line
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// printUsage prints the commands, global flags and exit codes of the contract gateway
+func printUsage(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintf(w, "usage: contract-gateway [flags] <group> <command> [flags] [arguments]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\n    \t%s\n", strings.TrimSpace(cmd.String()+" "+cmd.args), cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"contract-gateway <group> <command> -h\" for the flags of a command.\n\nflags:\n")
+	flags.PrintDefaults()
+	fmt.Fprintf(w, "\nexit codes:\n  %d ok, %d failure, %d usage, %d NOT_FOUND, %d ALREADY_EXISTS, %d FORBIDDEN, %d INVALID_ARGUMENT, %d CONFLICT, %d INTERNAL\n",
+		exitOK, exitFailure, exitUsage, exitNotFound, exitAlreadyExists, exitForbidden, exitInvalidArgument, exitConflict, exitInternal)
+}
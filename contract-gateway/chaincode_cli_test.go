@@ -0,0 +1,143 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		positional []string
+		output     string
+		lot        string
+		invalid    bool
+	}{
+		{name: "flags first", args: []string{"-output", "table", "-lot", "LOT1", "IVSLAB-S23FA0001"}, positional: []string{"IVSLAB-S23FA0001"}, output: "table", lot: "LOT1"},
+		{name: "flags last", args: []string{"IVSLAB-S23FA0001", "Security.Co", "-output", "yaml"}, positional: []string{"IVSLAB-S23FA0001", "Security.Co"}, output: "yaml"},
+		{name: "flags between arguments", args: []string{"IVSLAB-S23FA0001", "-lot=LOT2", "Security.Co"}, positional: []string{"IVSLAB-S23FA0001", "Security.Co"}, lot: "LOT2"},
+		{name: "arguments after --", args: []string{"-lot", "LOT3", "--", "-output", "table"}, positional: []string{"-output", "table"}, lot: "LOT3"},
+		{name: "no arguments", args: []string{"-output", "json"}, output: "json"},
+		{name: "unknown flag", args: []string{"IVSLAB-S23FA0001", "-color"}, invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("part create", flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+			output := flags.String("output", "", "output format")
+			lot := flags.String("lot", "", "production lot")
+			positional, err := parseArgs(flags, test.args)
+			if test.invalid {
+				if err == nil {
+					t.Fatalf("expected %v to be rejected", test.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseArgs failed: %v", err)
+			}
+			if !reflect.DeepEqual(positional, test.positional) || *output != test.output || *lot != test.lot {
+				t.Errorf("got arguments %q, -output %q, -lot %q, want %q, %q, %q", positional, *output, *lot, test.positional, test.output, test.lot)
+			}
+		})
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command string
+		rest    []string
+	}{
+		{[]string{"part", "get", "IVSLAB-S23FA0001"}, "part get", []string{"IVSLAB-S23FA0001"}},
+		{[]string{"register-parts", "manifest.csv", "50"}, "part register", []string{"manifest.csv", "50"}},
+		{[]string{"serve", "-listen", ":9090"}, "api serve", []string{"-listen", ":9090"}},
+		{[]string{"sign", "request.json"}, "offline sign", []string{"request.json"}},
+		{[]string{"listen"}, "ledger listen", []string{}},
+		{[]string{"part", "melt", "IVSLAB-S23FA0001"}, "", []string{"part", "melt"}},
+		{[]string{"part"}, "", []string{"part"}},
+	}
+	for _, test := range tests {
+		cmd, rest := findCommand(test.args)
+		name := ""
+		if cmd != nil {
+			name = cmd.String()
+		}
+		if name != test.command || !reflect.DeepEqual(rest, test.rest) {
+			t.Errorf("findCommand(%q) = %q, %q, want %q, %q", test.args, name, rest, test.command, test.rest)
+		}
+	}
+}
+
+func TestParseComponents(t *testing.T) {
+	components, err := parseComponents([]string{"SecurityChip=IVSLAB-S23FA0001", "NetworkChip=IVSLAB-N23FA0001"})
+	if err != nil {
+		t.Fatalf("parseComponents failed: %v", err)
+	}
+	want := []bomItem{{Slot: "SecurityChip", PartID: "IVSLAB-S23FA0001"}, {Slot: "NetworkChip", PartID: "IVSLAB-N23FA0001"}}
+	if !reflect.DeepEqual(components, want) {
+		t.Errorf("got %+v, want %+v", components, want)
+	}
+	for _, arg := range []string{"IVSLAB-S23FA0001", "=IVSLAB-S23FA0001", "SecurityChip="} {
+		if _, err := parseComponents([]string{arg}); err == nil {
+			t.Errorf("expected component %q to be rejected", arg)
+		}
+	}
+}
+
+func TestRunCLI(t *testing.T) {
+	wallet := t.TempDir()
+	tests := []struct {
+		name   string
+		env    map[string]string
+		args   []string
+		exit   int
+		stdout string
+		stderr string
+	}{
+		{name: "no command", exit: exitUsage, stderr: "usage: contract-gateway"},
+		{name: "help", args: []string{"help"}, exit: exitUsage, stderr: "exit codes:"},
+		{name: "help flag", args: []string{"-h"}, exit: exitOK, stderr: "usage: contract-gateway"},
+		{name: "unknown global flag", args: []string{"-color", "part", "list"}, exit: exitUsage},
+		{name: "unknown command", args: []string{"part", "melt"}, exit: exitUsage, stderr: `unknown command "part melt"`},
+		{name: "command help", args: []string{"part", "get", "-h"}, exit: exitOK, stderr: "usage: contract-gateway part get [flags] <PartID>"},
+		{name: "wrong number of arguments", args: []string{"part", "get"}, exit: exitUsage, stderr: "wrong number of arguments for part get"},
+		{name: "alias", args: []string{"sign"}, exit: exitUsage, stderr: "wrong number of arguments for offline sign"},
+		{name: "unknown output format", args: []string{"-output", "xml", "part", "get", "IVSLAB-S23FA0001"}, exit: exitUsage, stderr: `unknown output format "xml"`},
+		{name: "unknown output format from environment", env: map[string]string{"IVS_OUTPUT": "csv"}, args: []string{"part", "get", "IVSLAB-S23FA0001"}, exit: exitUsage, stderr: `unknown output format "csv"`},
+		{name: "configuration error", args: []string{"-profile", "lens", "wallet", "list"}, exit: exitUsage, stderr: `configuration error: unknown connection profile "lens"`},
+		{name: "local command", args: []string{"-wallet", wallet, "wallet", "list"}, exit: exitOK, stdout: "[]"},
+		{name: "output flag of the command", args: []string{"-wallet", wallet, "wallet", "list", "-output", "table"}, exit: exitOK, stdout: "LABEL  MSPID  KEYTYPE  SUBJECT  NOTAFTER"},
+		{name: "usage error of the runner", args: []string{"-msp-id", "", "-wallet", wallet, "wallet", "import", "admin", "cert.pem", "key.pem"}, exit: exitUsage, stderr: "the MSP ID of the identity is required"},
+		{name: "failed command", args: []string{"-wallet", wallet, "wallet", "remove", "admin"}, exit: exitFailure, stderr: "Error: "},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			t.Setenv("IVS_OUTPUT", "")
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			var stdout, stderr bytes.Buffer
+			exit := runCLI(test.args, &stdout, &stderr)
+			if exit != test.exit {
+				t.Errorf("got exit code %d, want %d, stderr:\n%s", exit, test.exit, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), test.stdout) {
+				t.Errorf("expected %q on stdout, got:\n%s", test.stdout, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), test.stderr) {
+				t.Errorf("expected %q on stderr, got:\n%s", test.stderr, stderr.String())
+			}
+		})
+	}
+}
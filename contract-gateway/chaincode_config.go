@@ -0,0 +1,381 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is read when no configuration file is given and it exists in the working directory
+const defaultConfigFile = "gateway.yaml"
+
+// gatewayConfig is the configuration file of the gateway: one connection profile per organization
+type gatewayConfig struct {
+	DefaultProfile string                        `yaml:"defaultProfile" json:"defaultProfile"` // 預設使用之連線設定
+	Profiles       map[string]*connectionProfile `yaml:"profiles" json:"profiles"`             // 各組織之連線設定
+}
+
+// connectionProfile describes how the client of one organization connects to the network
+type connectionProfile struct {
+	MSPID        string   `yaml:"mspId" json:"mspId"`               // 所屬組織的MSPID
+	PeerEndpoint string   `yaml:"peerEndpoint" json:"peerEndpoint"` // peer節點地址 (host:port)
+	GatewayPeer  string   `yaml:"gatewayPeer" json:"gatewayPeer"`   // peer節點名稱, 用於驗證TLS證書
+	TLSCertPath  string   `yaml:"tlsCertPath" json:"tlsCertPath"`   // client tls證書
+	CertPath     string   `yaml:"certPath" json:"certPath"`         // client數位簽章
+	KeyPath      string   `yaml:"keyPath" json:"keyPath"`           // client私鑰檔案, 或只含私鑰之目錄
+	Wallet       string   `yaml:"wallet" json:"wallet"`             // 錢包目錄或加密錢包檔案, 設定後取代certPath與keyPath
+	WalletType   string   `yaml:"walletType" json:"walletType"`     // 錢包類型 (filesystem/encrypted)
+	Identity     string   `yaml:"identity" json:"identity"`         // 預設使用之錢包身分標籤
+	Channel      string   `yaml:"channel" json:"channel"`           // 通道名稱
+	Chaincode    string   `yaml:"chaincode" json:"chaincode"`       // 鏈碼名稱
+	Timeouts     timeouts `yaml:"timeouts" json:"timeouts"`         // gRPC呼叫逾時
+}
+
+// timeouts are the default timeouts of the gRPC calls made through the Gateway
+type timeouts struct {
+	Evaluate     duration `yaml:"evaluate" json:"evaluate"`
+	Endorse      duration `yaml:"endorse" json:"endorse"`
+	Submit       duration `yaml:"submit" json:"submit"`
+	CommitStatus duration `yaml:"commitStatus" json:"commitStatus"`
+}
+
+// duration is a time.Duration written as a string such as "15s" in configuration files
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// defaultConfig is used when there is no configuration file. It connects as user1 of Brand-Org.
+func defaultConfig() *gatewayConfig {
+	cryptoPath := "/root/MyLab_IVS/organizations/brand.ivsorg.net"
+	return &gatewayConfig{
+		DefaultProfile: "brand",
+		Profiles: map[string]*connectionProfile{
+			"brand": {
+				MSPID:        "brandMSP",
+				PeerEndpoint: "peer1.brand.ivsorg.net:7151",
+				GatewayPeer:  "peer1.brand.ivsorg.net",
+				TLSCertPath:  cryptoPath + "/alliance/tls-ca-cert.pem",
+				CertPath:     cryptoPath + "/registers_users/user1/msp/signcerts/cert.pem",
+				KeyPath:      cryptoPath + "/registers_users/user1/msp/keystore/",
+			},
+		},
+	}
+}
+
+// applyDefaults fills the settings a profile may leave out
+func (p *connectionProfile) applyDefaults() {
+	defaults := []struct {
+		value    *string
+		fallback string
+	}{
+		{&p.Channel, "ivschannel"},
+		{&p.Chaincode, "ivs_basic"},
+	}
+	for _, d := range defaults {
+		if *d.value == "" {
+			*d.value = d.fallback
+		}
+	}
+	if p.GatewayPeer == "" {
+		p.GatewayPeer, _, _ = net.SplitHostPort(p.PeerEndpoint)
+	}
+	if p.Wallet != "" && p.WalletType == "" {
+		p.WalletType = walletFileSystem
+	}
+	timeoutDefaults := []struct {
+		value    *duration
+		fallback time.Duration
+	}{
+		{&p.Timeouts.Evaluate, 5 * time.Second},
+		{&p.Timeouts.Endorse, 15 * time.Second},
+		{&p.Timeouts.Submit, 5 * time.Second},
+		{&p.Timeouts.CommitStatus, 1 * time.Minute},
+	}
+	for _, d := range timeoutDefaults {
+		if d.value.Duration == 0 {
+			d.value.Duration = d.fallback
+		}
+	}
+}
+
+// profileSetting is a setting of a connection profile that can be overridden by an environment
+// variable and by a command line flag
+type profileSetting struct {
+	flag  string
+	env   string
+	usage string
+	set   func(p *connectionProfile, value string) error
+}
+
+// stringSetting sets a string field of a profile
+func stringSetting(field func(p *connectionProfile) *string) func(p *connectionProfile, value string) error {
+	return func(p *connectionProfile, value string) error {
+		*field(p) = value
+		return nil
+	}
+}
+
+// durationSetting sets a timeout of a profile
+func durationSetting(field func(p *connectionProfile) *duration) func(p *connectionProfile, value string) error {
+	return func(p *connectionProfile, value string) error {
+		return field(p).UnmarshalText([]byte(value))
+	}
+}
+
+// profileSettings lists the overridable settings. CHANNEL_NAME and CHAINCODE_NAME keep the names
+// used by earlier versions of the gateway.
+var profileSettings = []profileSetting{
+	{"msp-id", "IVS_MSP_ID", "MSP ID of the client", stringSetting(func(p *connectionProfile) *string { return &p.MSPID })},
+	{"peer-endpoint", "IVS_PEER_ENDPOINT", "gateway peer address, host:port", stringSetting(func(p *connectionProfile) *string { return &p.PeerEndpoint })},
+	{"gateway-peer", "IVS_GATEWAY_PEER", "host name in the TLS certificate of the gateway peer", stringSetting(func(p *connectionProfile) *string { return &p.GatewayPeer })},
+	{"tls-cert", "IVS_TLS_CERT", "TLS CA certificate file", stringSetting(func(p *connectionProfile) *string { return &p.TLSCertPath })},
+	{"cert", "IVS_CERT", "client certificate file", stringSetting(func(p *connectionProfile) *string { return &p.CertPath })},
+	{"key", "IVS_KEY", "client private key file, or a directory holding only the key", stringSetting(func(p *connectionProfile) *string { return &p.KeyPath })},
+	{"wallet", "IVS_WALLET", "wallet directory, or encrypted wallet file, holding the client identities", stringSetting(func(p *connectionProfile) *string { return &p.Wallet })},
+	{"wallet-type", "IVS_WALLET_TYPE", "wallet type, filesystem or encrypted", stringSetting(func(p *connectionProfile) *string { return &p.WalletType })},
+	{"identity", "IVS_IDENTITY", "label of the wallet identity to use", stringSetting(func(p *connectionProfile) *string { return &p.Identity })},
+	{"channel", "CHANNEL_NAME", "channel name", stringSetting(func(p *connectionProfile) *string { return &p.Channel })},
+	{"chaincode", "CHAINCODE_NAME", "chaincode name", stringSetting(func(p *connectionProfile) *string { return &p.Chaincode })},
+	{"evaluate-timeout", "IVS_EVALUATE_TIMEOUT", "timeout of evaluate calls, e.g. 5s", durationSetting(func(p *connectionProfile) *duration { return &p.Timeouts.Evaluate })},
+	{"endorse-timeout", "IVS_ENDORSE_TIMEOUT", "timeout of endorse calls", durationSetting(func(p *connectionProfile) *duration { return &p.Timeouts.Endorse })},
+	{"submit-timeout", "IVS_SUBMIT_TIMEOUT", "timeout of submit calls", durationSetting(func(p *connectionProfile) *duration { return &p.Timeouts.Submit })},
+	{"commit-timeout", "IVS_COMMIT_TIMEOUT", "timeout of commit status calls", durationSetting(func(p *connectionProfile) *duration { return &p.Timeouts.CommitStatus })},
+}
+
+// configFlags are the command line flags that select and override a connection profile
+type configFlags struct {
+	flags       *flag.FlagSet
+	configFile  *string
+	profileName *string
+	values      map[string]*string
+}
+
+// newConfigFlags registers the connection flags with flags
+func newConfigFlags(flags *flag.FlagSet) *configFlags {
+	c := &configFlags{
+		flags:       flags,
+		configFile:  flags.String("config", "", "configuration file (.yaml or .json), overrides IVS_GATEWAY_CONFIG"),
+		profileName: flags.String("profile", "", "connection profile to use, overrides IVS_PROFILE"),
+		values:      make(map[string]*string),
+	}
+	for _, setting := range profileSettings {
+		c.values[setting.flag] = flags.String(setting.flag, "", fmt.Sprintf("%s, overrides %s", setting.usage, setting.env))
+	}
+	return c
+}
+
+// loadConfig returns the connection profile selected by the parsed flags. Settings are taken from
+// the configuration file, then from environment variables, then from flags, each overriding the
+// previous one. The peer settings are only required to connect to the gateway, and the private
+// key only to sign.
+func (c *configFlags) loadConfig(connect bool, sign bool) (*connectionProfile, error) {
+	configFile := *c.configFile
+	if configFile == "" {
+		configFile = os.Getenv("IVS_GATEWAY_CONFIG")
+	}
+	if configFile == "" {
+		if _, err := os.Stat(defaultConfigFile); err == nil {
+			configFile = defaultConfigFile
+		}
+	}
+	config := defaultConfig()
+	if configFile != "" {
+		var err error
+		config, err = readConfigFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	profileName := *c.profileName
+	if profileName == "" {
+		profileName = os.Getenv("IVS_PROFILE")
+	}
+	name, profile, err := config.profile(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// environment variables override the file, flags override both
+	for _, setting := range profileSettings {
+		if value := os.Getenv(setting.env); value != "" {
+			if err := setting.set(profile, value); err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", setting.env, value, err)
+			}
+		}
+	}
+	var flagErr error
+	c.flags.Visit(func(f *flag.Flag) {
+		for _, setting := range profileSettings {
+			if setting.flag == f.Name && flagErr == nil {
+				if err := setting.set(profile, *c.values[f.Name]); err != nil {
+					flagErr = fmt.Errorf("invalid -%s %q: %w", f.Name, *c.values[f.Name], err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	profile.applyDefaults()
+	if err := profile.validate(name, connect, sign); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// readConfigFile parses a .yaml, .yml or .json configuration file. Unknown settings are rejected,
+// so that a misspelt setting does not silently fall back to its default.
+func readConfigFile(filename string) (*gatewayConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	var config gatewayConfig
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&config)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&config)
+	default:
+		return nil, fmt.Errorf("configuration file %s must be a .yaml, .yml or .json file", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", filename, err)
+	}
+	if len(config.Profiles) == 0 {
+		return nil, fmt.Errorf("configuration file %s defines no profiles", filename)
+	}
+
+	return &config, nil
+}
+
+// profile returns the name and a copy of the named profile. Without a name it returns the default
+// profile, or the only profile of the file.
+func (c *gatewayConfig) profile(name string) (string, *connectionProfile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" && len(c.Profiles) == 1 {
+		for only := range c.Profiles {
+			name = only
+		}
+	}
+	names := make([]string, 0, len(c.Profiles))
+	for known := range c.Profiles {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	if name == "" {
+		return "", nil, fmt.Errorf("no connection profile selected, use -profile or IVS_PROFILE to choose one of: %s", strings.Join(names, ", "))
+	}
+	profile, ok := c.Profiles[name]
+	if !ok || profile == nil {
+		return "", nil, fmt.Errorf("unknown connection profile %q, known profiles: %s", name, strings.Join(names, ", "))
+	}
+
+	selected := *profile
+	return name, &selected, nil
+}
+
+// validate reports every problem of a profile at once, so that a misconfiguration can be fixed in one go.
+// The peer settings are checked if connect is set, the private key if sign is set.
+func (p *connectionProfile) validate(name string, connect bool, sign bool) error {
+	var problems []string
+	type setting struct {
+		field string
+		value string
+	}
+	var required, files []setting
+	if connect {
+		required = append(required, setting{"peerEndpoint", p.PeerEndpoint}, setting{"tlsCertPath", p.TLSCertPath})
+		files = append(files, setting{"tlsCertPath", p.TLSCertPath})
+	}
+	// a wallet identity carries its MSP ID, certificate and key
+	if p.Wallet == "" {
+		required = append(required, setting{"mspId", p.MSPID}, setting{"certPath", p.CertPath})
+		files = append(files, setting{"certPath", p.CertPath})
+		if sign {
+			required = append(required, setting{"keyPath", p.KeyPath})
+			files = append(files, setting{"keyPath", p.KeyPath})
+		}
+	}
+	for _, r := range required {
+		if r.value == "" {
+			problems = append(problems, fmt.Sprintf("%s is not set", r.field))
+		}
+	}
+	if connect && p.PeerEndpoint != "" {
+		if _, port, err := net.SplitHostPort(p.PeerEndpoint); err != nil || port == "" {
+			problems = append(problems, fmt.Sprintf("peerEndpoint %q is not a host:port address", p.PeerEndpoint))
+		}
+	}
+	for _, f := range files {
+		if f.value == "" {
+			continue
+		}
+		if _, err := os.Stat(f.value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s cannot be read: %v", f.field, f.value, err))
+		}
+	}
+	if p.Wallet != "" && p.WalletType != walletFileSystem && p.WalletType != walletEncrypted {
+		problems = append(problems, fmt.Sprintf("walletType %q must be %s or %s", p.WalletType, walletFileSystem, walletEncrypted))
+	}
+	timeouts := []struct {
+		field string
+		value duration
+	}{
+		{"timeouts.evaluate", p.Timeouts.Evaluate},
+		{"timeouts.endorse", p.Timeouts.Endorse},
+		{"timeouts.submit", p.Timeouts.Submit},
+		{"timeouts.commitStatus", p.Timeouts.CommitStatus},
+	}
+	for _, t := range timeouts {
+		if t.value.Duration <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be positive, got %s", t.field, t.value.Duration))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid connection profile %q:\n  - %s", name, strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
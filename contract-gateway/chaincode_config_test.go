@@ -0,0 +1,360 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// writeTestConfig writes a configuration file with the profiles brand and security to a temporary
+// directory. The certificate and key paths point to files that exist.
+func writeTestConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"tls.pem", "cert.pem", "key.pem"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("pem"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := `
+defaultProfile: brand
+profiles:
+  brand:
+    mspId: brandMSP
+    peerEndpoint: peer1.brand.ivsorg.net:7151
+    tlsCertPath: ` + filepath.Join(dir, "tls.pem") + `
+    certPath: ` + filepath.Join(dir, "cert.pem") + `
+    keyPath: ` + filepath.Join(dir, "key.pem") + `
+    channel: file-channel
+    timeouts:
+      evaluate: 7s
+  security:
+    mspId: securityMSP
+    peerEndpoint: peer1.security.ivsorg.net:7051
+    tlsCertPath: ` + filepath.Join(dir, "tls.pem") + `
+    certPath: ` + filepath.Join(dir, "cert.pem") + `
+    keyPath: ` + filepath.Join(dir, "key.pem") + `
+`
+	filename := filepath.Join(dir, "gateway.yaml")
+	if err := os.WriteFile(filename, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// clearConfigEnv unsets every environment variable read by loadConfig for the duration of the test
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"IVS_GATEWAY_CONFIG", "IVS_PROFILE"} {
+		t.Setenv(name, "")
+	}
+	for _, setting := range profileSettings {
+		t.Setenv(setting.env, "")
+	}
+}
+
+// loadTestConfig parses args with the connection flags and loads the selected profile
+func loadTestConfig(t *testing.T, args []string, connect bool, sign bool) (*connectionProfile, error) {
+	t.Helper()
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := newConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return config.loadConfig(connect, sign)
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	filename := writeTestConfig(t)
+	tests := []struct {
+		name      string
+		env       map[string]string
+		args      []string
+		mspID     string
+		channel   string
+		chaincode string
+		evaluate  time.Duration
+	}{
+		{
+			name:      "file",
+			mspID:     "brandMSP",
+			channel:   "file-channel",
+			chaincode: "ivs_basic",
+			evaluate:  7 * time.Second,
+		},
+		{
+			name:      "environment overrides file",
+			env:       map[string]string{"CHANNEL_NAME": "env-channel", "IVS_EVALUATE_TIMEOUT": "9s"},
+			mspID:     "brandMSP",
+			channel:   "env-channel",
+			chaincode: "ivs_basic",
+			evaluate:  9 * time.Second,
+		},
+		{
+			name:      "flag overrides environment",
+			env:       map[string]string{"CHANNEL_NAME": "env-channel", "IVS_EVALUATE_TIMEOUT": "9s", "CHAINCODE_NAME": "env-chaincode"},
+			args:      []string{"-channel", "flag-channel", "-evaluate-timeout", "11s"},
+			mspID:     "brandMSP",
+			channel:   "flag-channel",
+			chaincode: "env-chaincode",
+			evaluate:  11 * time.Second,
+		},
+		{
+			name:      "profile from environment",
+			env:       map[string]string{"IVS_PROFILE": "security"},
+			mspID:     "securityMSP",
+			channel:   "ivschannel",
+			chaincode: "ivs_basic",
+			evaluate:  5 * time.Second,
+		},
+		{
+			name:      "profile flag overrides environment",
+			env:       map[string]string{"IVS_PROFILE": "security", "IVS_MSP_ID": "otherMSP"},
+			args:      []string{"-profile", "brand"},
+			mspID:     "otherMSP",
+			channel:   "file-channel",
+			chaincode: "ivs_basic",
+			evaluate:  7 * time.Second,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			t.Setenv("IVS_GATEWAY_CONFIG", filename)
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			profile, err := loadTestConfig(t, test.args, true, true)
+			if err != nil {
+				t.Fatalf("loadConfig failed: %v", err)
+			}
+			if profile.MSPID != test.mspID || profile.Channel != test.channel || profile.Chaincode != test.chaincode {
+				t.Errorf("got MSP ID %s, channel %s, chaincode %s, want %s, %s, %s",
+					profile.MSPID, profile.Channel, profile.Chaincode, test.mspID, test.channel, test.chaincode)
+			}
+			if profile.Timeouts.Evaluate.Duration != test.evaluate {
+				t.Errorf("got evaluate timeout %s, want %s", profile.Timeouts.Evaluate.Duration, test.evaluate)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	filename := writeTestConfig(t)
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unknown profile", nil, []string{"-profile", "lens"}, `unknown connection profile "lens", known profiles: brand, security`},
+		{"invalid environment duration", map[string]string{"IVS_SUBMIT_TIMEOUT": "soon"}, nil, `invalid IVS_SUBMIT_TIMEOUT "soon"`},
+		{"invalid flag duration", nil, []string{"-commit-timeout", "1 minute"}, `invalid -commit-timeout "1 minute"`},
+		{"unsupported file", nil, []string{"-config", "gateway.toml"}, "failed to read configuration file"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			t.Setenv("IVS_GATEWAY_CONFIG", filename)
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			_, err := loadTestConfig(t, test.args, true, true)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestReadConfigFileRejectsUnknownSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"misspelt profile setting", "gateway.yaml", "profiles:\n  brand:\n    mspId: brandMSP\n    peerEndpont: peer1.brand.ivsorg.net:7151\n", "field peerEndpont not found"},
+		{"misspelt JSON setting", "gateway.json", `{"profiles":{"brand":{"mspId":"brandMSP","peerEndpont":"peer1.brand.ivsorg.net:7151"}}}`, `unknown field "peerEndpont"`},
+		{"misspelt top-level setting", "gateway.yaml", "defaultProfle: brand\nprofiles:\n  brand:\n    mspId: brandMSP\n", "field defaultProfle not found"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), test.file)
+			if err := os.WriteFile(filename, []byte(test.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := readConfigFile(filename)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestReadExampleConfig(t *testing.T) {
+	config, err := readConfigFile("gateway.example.yaml")
+	if err != nil {
+		t.Fatalf("readConfigFile failed: %v", err)
+	}
+	name, profile, err := config.profile("")
+	if err != nil || name != "brand" || profile.MSPID != "brandMSP" || profile.Timeouts.CommitStatus.Duration != time.Minute {
+		t.Fatalf("unexpected default profile %s %+v, %v", name, profile, err)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    time.Duration
+		invalid bool
+	}{
+		{text: "15s", want: 15 * time.Second},
+		{text: "1m30s", want: 90 * time.Second},
+		{text: "250ms", want: 250 * time.Millisecond},
+		{text: "15", invalid: true},
+		{text: "fifteen seconds", invalid: true},
+	}
+	for _, test := range tests {
+		var fromYAML timeouts
+		yamlErr := yaml.Unmarshal([]byte("evaluate: "+test.text), &fromYAML)
+		var fromJSON timeouts
+		jsonErr := json.Unmarshal([]byte(`{"evaluate":"`+test.text+`"}`), &fromJSON)
+		if test.invalid {
+			if yamlErr == nil || jsonErr == nil {
+				t.Errorf("expected %q to be rejected, got %v and %v", test.text, yamlErr, jsonErr)
+			}
+			continue
+		}
+		if yamlErr != nil || jsonErr != nil {
+			t.Fatalf("failed to parse %q: %v, %v", test.text, yamlErr, jsonErr)
+		}
+		if fromYAML.Evaluate.Duration != test.want || fromJSON.Evaluate.Duration != test.want {
+			t.Errorf("parsed %q as %s and %s, want %s", test.text, fromYAML.Evaluate.Duration, fromJSON.Evaluate.Duration, test.want)
+		}
+		text, err := fromYAML.Evaluate.MarshalText()
+		if err != nil || string(text) != test.want.String() {
+			t.Errorf("marshaled %s as %q, %v", test.want, text, err)
+		}
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.pem")
+	valid := func() *connectionProfile {
+		return &connectionProfile{
+			MSPID:        "brandMSP",
+			PeerEndpoint: "peer1.brand.ivsorg.net:7151",
+			TLSCertPath:  dir,
+			CertPath:     dir,
+			KeyPath:      dir,
+			Timeouts: timeouts{
+				Evaluate:     duration{time.Second},
+				Endorse:      duration{time.Second},
+				Submit:       duration{time.Second},
+				CommitStatus: duration{time.Second},
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		change   func(p *connectionProfile)
+		connect  bool
+		sign     bool
+		problems []string
+	}{
+		{
+			name:    "valid",
+			change:  func(p *connectionProfile) {},
+			connect: true,
+			sign:    true,
+		},
+		{
+			name: "every problem at once",
+			change: func(p *connectionProfile) {
+				p.MSPID = ""
+				p.PeerEndpoint = "peer1.brand.ivsorg.net"
+				p.TLSCertPath = missing
+				p.KeyPath = ""
+				p.Timeouts.Submit = duration{}
+				p.Timeouts.CommitStatus = duration{-time.Second}
+			},
+			connect: true,
+			sign:    true,
+			problems: []string{
+				"mspId is not set",
+				"keyPath is not set",
+				`peerEndpoint "peer1.brand.ivsorg.net" is not a host:port address`,
+				"tlsCertPath " + missing + " cannot be read",
+				"timeouts.submit must be positive, got 0s",
+				"timeouts.commitStatus must be positive, got -1s",
+			},
+		},
+		{
+			name: "peer settings only to connect",
+			change: func(p *connectionProfile) {
+				p.PeerEndpoint = ""
+				p.TLSCertPath = ""
+			},
+			connect: false,
+			sign:    true,
+		},
+		{
+			name: "private key only to sign",
+			change: func(p *connectionProfile) {
+				p.KeyPath = ""
+			},
+			connect: true,
+			sign:    false,
+		},
+		{
+			name: "wallet replaces certificate and key",
+			change: func(p *connectionProfile) {
+				p.MSPID = ""
+				p.CertPath = ""
+				p.KeyPath = ""
+				p.Wallet = dir
+				p.WalletType = "vault"
+			},
+			connect:  true,
+			sign:     true,
+			problems: []string{`walletType "vault" must be filesystem or encrypted`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			profile := valid()
+			test.change(profile)
+			err := profile.validate("brand", test.connect, test.sign)
+			if len(test.problems) == 0 {
+				if err != nil {
+					t.Fatalf("expected the profile to be valid, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected problems %v", test.problems)
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if lines[0] != `invalid connection profile "brand":` || len(lines)-1 != len(test.problems) {
+				t.Fatalf("expected %d problems, got %v", len(test.problems), err)
+			}
+			for _, problem := range test.problems {
+				if !strings.Contains(err.Error(), "  - "+problem) {
+					t.Errorf("expected problem %q in %v", problem, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Codes of the structured errors returned by the chaincode
+const (
+	errCodeNotFound        = "NOT_FOUND"
+	errCodeAlreadyExists   = "ALREADY_EXISTS"
+	errCodeForbidden       = "FORBIDDEN"
+	errCodeInvalidArgument = "INVALID_ARGUMENT"
+	errCodeConflict        = "CONFLICT"
+	errCodeInternal        = "INTERNAL"
+)
+
+// Exit codes of the command line interface. Failed transactions exit with the code mapped from the
+// chaincode's error code, so that scripts can branch on it without parsing the message.
+const (
+	exitOK              = 0
+	exitFailure         = 1 // the transaction failed without a structured chaincode error
+	exitUsage           = 2 // invalid command line or configuration
+	exitNotFound        = 3
+	exitAlreadyExists   = 4
+	exitForbidden       = 5
+	exitInvalidArgument = 6
+	exitConflict        = 7
+	exitInternal        = 8
+)
+
+// exitCodes maps the chaincode's error codes to exit codes
+var exitCodes = map[string]int{
+	errCodeNotFound:        exitNotFound,
+	errCodeAlreadyExists:   exitAlreadyExists,
+	errCodeForbidden:       exitForbidden,
+	errCodeInvalidArgument: exitInvalidArgument,
+	errCodeConflict:        exitConflict,
+	errCodeInternal:        exitInternal,
+}
+
+// httpStatuses maps the chaincode's error codes to HTTP statuses of the REST API
+var httpStatuses = map[string]int{
+	errCodeNotFound:        http.StatusNotFound,
+	errCodeAlreadyExists:   http.StatusConflict,
+	errCodeForbidden:       http.StatusForbidden,
+	errCodeInvalidArgument: http.StatusBadRequest,
+	errCodeConflict:        http.StatusConflict,
+	errCodeInternal:        http.StatusInternalServerError,
+}
+
+// Codes of the REST API errors that do not come from the chaincode
+const (
+	apiCodeEndorseFailed      = "ENDORSE_FAILED"
+	apiCodeSubmitFailed       = "SUBMIT_FAILED"
+	apiCodeCommitStatusFailed = "COMMIT_STATUS_FAILED"
+	apiCodeCommitFailed       = "COMMIT_FAILED"
+	apiCodeTimeout            = "TIMEOUT"
+	apiCodeUnavailable        = "UNAVAILABLE"
+	apiCodeUnauthenticated    = "UNAUTHENTICATED"
+)
+
+// contractError mirrors the JSON error document returned by the chaincode when a transaction fails
+type contractError struct {
+	Code    string          `json:"code"`
+	Field   string          `json:"field,omitempty"`
+	Message string          `json:"message"`
+	Details json.RawMessage `json:"details,omitempty"`
+}
+
+// parseContractError extracts the chaincode's structured error from an endorsement or evaluation
+// error. The peers embed the chaincode response message, e.g. "chaincode response 500, {...}",
+// in the details of the gRPC status.
+func parseContractError(err error) (*contractError, bool) {
+	for _, detail := range status.Convert(err).Details() {
+		errorDetail, ok := detail.(*gateway.ErrorDetail)
+		if !ok {
+			continue
+		}
+		start := strings.Index(errorDetail.Message, "{")
+		if start < 0 {
+			continue
+		}
+		var contractErr contractError
+		if json.Unmarshal([]byte(errorDetail.Message[start:]), &contractErr) == nil && contractErr.Code != "" {
+			return &contractErr, true
+		}
+	}
+
+	return nil, false
+}
+
+// exitCode returns the exit code for a failed command
+func exitCode(err error) int {
+	if contractErr, ok := parseContractError(err); ok {
+		if code, ok := exitCodes[contractErr.Code]; ok {
+			return code
+		}
+	}
+	return exitFailure
+}
+
+// printError describes a failed command on w. A structured chaincode error is printed with its
+// code and field; other errors are described by the stage of the transaction that failed.
+func printError(w io.Writer, err error) {
+	if contractErr, ok := parseContractError(err); ok {
+		if contractErr.Field != "" {
+			fmt.Fprintf(w, "Error %s %s: %s\n", contractErr.Code, contractErr.Field, contractErr.Message)
+		} else {
+			fmt.Fprintf(w, "Error %s: %s\n", contractErr.Code, contractErr.Message)
+		}
+		return
+	}
+
+	var (
+		endorseErr      *client.EndorseError
+		submitErr       *client.SubmitError
+		commitStatusErr *client.CommitStatusError
+		commitErr       *client.CommitError
+	)
+	switch {
+	case errors.As(err, &endorseErr):
+		fmt.Fprintf(w, "Endorse error for transaction %s with gRPC status %v: %s\n", endorseErr.TransactionID, status.Code(endorseErr), endorseErr)
+	case errors.As(err, &submitErr):
+		fmt.Fprintf(w, "Submit error for transaction %s with gRPC status %v: %s\n", submitErr.TransactionID, status.Code(submitErr), submitErr)
+	case errors.As(err, &commitStatusErr):
+		if errors.Is(err, context.DeadlineExceeded) {
+			fmt.Fprintf(w, "Timeout waiting for transaction %s commit status: %s\n", commitStatusErr.TransactionID, commitStatusErr)
+		} else {
+			fmt.Fprintf(w, "Error obtaining commit status for transaction %s with gRPC status %v: %s\n", commitStatusErr.TransactionID, status.Code(commitStatusErr), commitStatusErr)
+		}
+	case errors.As(err, &commitErr):
+		fmt.Fprintf(w, "Transaction %s failed to commit with status %d: %s\n", commitErr.TransactionID, int32(commitErr.Code), commitErr)
+	default:
+		fmt.Fprintf(w, "Error: %s\n", err)
+	}
+
+	// errors that originate from a peer or orderer carry their details in the gRPC status
+	for _, detail := range status.Convert(err).Details() {
+		if detail, ok := detail.(*gateway.ErrorDetail); ok {
+			fmt.Fprintf(w, "- address: %s, mspId: %s, message: %s\n", detail.Address, detail.MspId, detail.Message)
+		}
+	}
+}
+
+// apiError is the body of a failed REST API request. Errors of the chaincode keep their code,
+// field and details; other failures are described by the stage of the transaction that failed.
+type apiError struct {
+	Code          string          `json:"code"`
+	Field         string          `json:"field,omitempty"`
+	Message       string          `json:"message"`
+	Details       json.RawMessage `json:"details,omitempty"`
+	TransactionID string          `json:"transactionId,omitempty"`
+}
+
+// httpError returns the HTTP status and body describing a failed transaction
+func httpError(err error) (int, *apiError) {
+	var (
+		endorseErr      *client.EndorseError
+		submitErr       *client.SubmitError
+		commitStatusErr *client.CommitStatusError
+		commitErr       *client.CommitError
+	)
+	body := &apiError{Message: err.Error()}
+	switch {
+	case errors.As(err, &endorseErr):
+		body.Code, body.TransactionID = apiCodeEndorseFailed, endorseErr.TransactionID
+	case errors.As(err, &submitErr):
+		body.Code, body.TransactionID = apiCodeSubmitFailed, submitErr.TransactionID
+	case errors.As(err, &commitStatusErr):
+		body.Code, body.TransactionID = apiCodeCommitStatusFailed, commitStatusErr.TransactionID
+	case errors.As(err, &commitErr):
+		// the transaction was ordered but invalidated, e.g. by a concurrent change of the same keys
+		body.Code, body.TransactionID = apiCodeCommitFailed, commitErr.TransactionID
+		return http.StatusConflict, body
+	}
+
+	if contractErr, ok := parseContractError(err); ok {
+		body.Code, body.Field, body.Message, body.Details = contractErr.Code, contractErr.Field, contractErr.Message, contractErr.Details
+		if httpStatus, ok := httpStatuses[contractErr.Code]; ok {
+			return httpStatus, body
+		}
+		return http.StatusInternalServerError, body
+	}
+	if errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded {
+		body.Code = apiCodeTimeout
+		return http.StatusGatewayTimeout, body
+	}
+	if status.Code(err) == codes.Unavailable {
+		body.Code = apiCodeUnavailable
+		return http.StatusServiceUnavailable, body
+	}
+	if body.Code != "" {
+		// the peers or the orderer rejected the transaction
+		return http.StatusBadGateway, body
+	}
+	body.Code = errCodeInternal
+	return http.StatusInternalServerError, body
+}
@@ -0,0 +1,129 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// peerError returns a gRPC error of a peer that carries message in its error details, like the
+// errors of a rejected endorsement or evaluation
+func peerError(t *testing.T, code codes.Code, message string) error {
+	t.Helper()
+	detail := &gateway.ErrorDetail{Address: "peer1.brand.ivsorg.net:7151", MspId: "brandMSP", Message: message}
+	st, err := status.New(code, "evaluate call to endorser returned error").WithDetails(protoadapt.MessageV1Of(detail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.Err()
+}
+
+// chaincodeError returns the error of a transaction rejected by the chaincode with a structured error
+func chaincodeError(t *testing.T, code string, field string) error {
+	t.Helper()
+	return peerError(t, codes.Aborted, fmt.Sprintf(`chaincode response 500, {"code":%q,"field":%q,"message":"rejected"}`, code, field))
+}
+
+func TestParseContractError(t *testing.T) {
+	contractErr, ok := parseContractError(chaincodeError(t, errCodeNotFound, "PartID"))
+	if !ok || contractErr.Code != errCodeNotFound || contractErr.Field != "PartID" || contractErr.Message != "rejected" {
+		t.Fatalf("unexpected contract error %+v, %v", contractErr, ok)
+	}
+	for _, err := range []error{
+		errors.New("connection refused"),
+		peerError(t, codes.Aborted, "chaincode response 500, asset not found"),
+		peerError(t, codes.Aborted, `chaincode response 500, {"message":"no code"}`),
+	} {
+		if contractErr, ok := parseContractError(err); ok {
+			t.Errorf("expected no contract error in %v, got %+v", err, contractErr)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want int
+	}{
+		{errCodeNotFound, exitNotFound},
+		{errCodeAlreadyExists, exitAlreadyExists},
+		{errCodeForbidden, exitForbidden},
+		{errCodeInvalidArgument, exitInvalidArgument},
+		{errCodeConflict, exitConflict},
+		{errCodeInternal, exitInternal},
+		{"TEAPOT", exitFailure},
+	}
+	for _, test := range tests {
+		if got := exitCode(chaincodeError(t, test.code, "")); got != test.want {
+			t.Errorf("exitCode of %s = %d, want %d", test.code, got, test.want)
+		}
+	}
+	if got := exitCode(errors.New("connection refused")); got != exitFailure {
+		t.Errorf("exitCode of a plain error = %d, want %d", got, exitFailure)
+	}
+}
+
+func TestPrintError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{chaincodeError(t, errCodeInvalidArgument, "SerialNumber"), "Error INVALID_ARGUMENT SerialNumber: rejected\n"},
+		{chaincodeError(t, errCodeForbidden, ""), "Error FORBIDDEN: rejected\n"},
+		{peerError(t, codes.Unavailable, "peer unreachable"), "- address: peer1.brand.ivsorg.net:7151, mspId: brandMSP, message: peer unreachable\n"},
+		{&client.CommitError{TransactionID: "tx1"}, "Transaction tx1 failed to commit with status 0"},
+		{errors.New("connection refused"), "Error: connection refused\n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		printError(&out, test.err)
+		if !strings.Contains(out.String(), test.want) {
+			t.Errorf("expected %q in %q", test.want, out.String())
+		}
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		field  string
+	}{
+		{"not found", chaincodeError(t, errCodeNotFound, "PartID"), http.StatusNotFound, errCodeNotFound, "PartID"},
+		{"already exists", chaincodeError(t, errCodeAlreadyExists, ""), http.StatusConflict, errCodeAlreadyExists, ""},
+		{"forbidden", chaincodeError(t, errCodeForbidden, ""), http.StatusForbidden, errCodeForbidden, ""},
+		{"invalid argument", chaincodeError(t, errCodeInvalidArgument, "SerialNumber"), http.StatusBadRequest, errCodeInvalidArgument, "SerialNumber"},
+		{"conflict", chaincodeError(t, errCodeConflict, "Revision"), http.StatusConflict, errCodeConflict, "Revision"},
+		{"internal", chaincodeError(t, errCodeInternal, ""), http.StatusInternalServerError, errCodeInternal, ""},
+		{"unknown chaincode code", chaincodeError(t, "TEAPOT", ""), http.StatusInternalServerError, "TEAPOT", ""},
+		{"commit failed", &client.CommitError{TransactionID: "tx1"}, http.StatusConflict, apiCodeCommitFailed, ""},
+		{"deadline", fmt.Errorf("evaluate: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, apiCodeTimeout, ""},
+		{"gRPC deadline", status.Error(codes.DeadlineExceeded, "deadline exceeded"), http.StatusGatewayTimeout, apiCodeTimeout, ""},
+		{"unavailable", status.Error(codes.Unavailable, "connection refused"), http.StatusServiceUnavailable, apiCodeUnavailable, ""},
+		{"other", errors.New("boom"), http.StatusInternalServerError, errCodeInternal, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			httpStatus, body := httpError(test.err)
+			if httpStatus != test.status || body.Code != test.code || body.Field != test.field {
+				t.Errorf("got %d %+v, want %d with code %s and field %q", httpStatus, body, test.status, test.code, test.field)
+			}
+		})
+	}
+}
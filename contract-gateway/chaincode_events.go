@@ -0,0 +1,71 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// supportedEventVersion is the version of the chaincode event payload this listener understands
+const supportedEventVersion = 1
+
+// contractEvent mirrors the JSON payload emitted by the chaincode for every state change
+type contractEvent struct {
+	Version    int      `json:"version"`
+	Name       string   `json:"name"`
+	ObjectType string   `json:"objectType"`
+	ObjectID   string   `json:"objectId"`
+	PartIDs    []string `json:"partIds,omitempty"`
+	OldOwner   string   `json:"oldOwner,omitempty"`
+	NewOwner   string   `json:"newOwner,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+	TxID       string   `json:"txId"`
+	Timestamp  string   `json:"timestamp"`
+}
+
+// listenChaincodeEvents prints chaincode events until ctx is cancelled. The position of the last processed
+// event is stored in checkpointFile, so a restarted listener resumes right after it without missing or
+// repeating events. Without a checkpoint the listener starts from the first block.
+func listenChaincodeEvents(ctx context.Context, network *client.Network, chaincodeName string, checkpointFile string) error {
+	checkpointer, err := client.NewFileCheckpointer(checkpointFile)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint file %s: %w", checkpointFile, err)
+	}
+	defer checkpointer.Close()
+
+	fmt.Printf("\n--> Start chaincode event listening from block %d\n", checkpointer.BlockNumber())
+	events, err := network.ChaincodeEvents(ctx, chaincodeName, client.WithCheckpoint(checkpointer), client.WithStartBlock(0))
+	if err != nil {
+		return fmt.Errorf("failed to start chaincode event listening: %w", err)
+	}
+
+	for event := range events {
+		handleChaincodeEvent(event)
+		if err := checkpointer.CheckpointChaincodeEvent(event); err != nil {
+			return fmt.Errorf("failed to checkpoint event %s in transaction %s: %w", event.EventName, event.TransactionID, err)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// handleChaincodeEvent prints a single chaincode event
+func handleChaincodeEvent(event *client.ChaincodeEvent) {
+	var payload contractEvent
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		fmt.Printf("<-- Block %d, transaction %s: %s with unreadable payload: %s\n", event.BlockNumber, event.TransactionID, event.EventName, err)
+		return
+	}
+	if payload.Version != supportedEventVersion {
+		fmt.Printf("<-- Block %d, transaction %s: %s with unsupported payload version %d\n", event.BlockNumber, event.TransactionID, event.EventName, payload.Version)
+		return
+	}
+
+	fmt.Printf("<-- Block %d, transaction %s: %s\n%s\n", event.BlockNumber, event.TransactionID, event.EventName, formatJSON(event.Payload))
+}
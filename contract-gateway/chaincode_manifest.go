@@ -0,0 +1,207 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultChunkSize is the number of parts submitted per CreatePartsBatch transaction. The chaincode
+// accepts at most 500 parts per batch.
+const defaultChunkSize = 100
+
+// partDefinition mirrors a part definition accepted by CreatePartsBatch
+type partDefinition struct {
+	PID                 string `json:"PID"`
+	Manufacturer        string `json:"Manufacturer"`
+	ManufactureLocation string `json:"ManufactureLocation"`
+	PartName            string `json:"PartName"`
+	PartNumber          string `json:"PartNumber"`
+	Organization        string `json:"Organization"`
+	LotID               string `json:"LotID,omitempty"`
+}
+
+// partBatchResult mirrors the per-item result returned by CreatePartsBatch
+type partBatchResult struct {
+	Index   int    `json:"index"`
+	PID     string `json:"PID"`
+	Status  string `json:"status"`
+	Code    string `json:"code,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// loadManifest reads the parts of a production lot from a .json or .csv manifest. A JSON manifest
+// is an array of part definitions; a CSV manifest has a header row naming the columns PID,
+// Manufacturer, ManufactureLocation, PartName, PartNumber and Organization in any order, and
+// optionally LotID to assign the parts to a production lot.
+func loadManifest(filename string) ([]partDefinition, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		var parts []partDefinition
+		if err := json.NewDecoder(file).Decode(&parts); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest %s: %w", filename, err)
+		}
+		return parts, nil
+	case ".csv":
+		parts, err := readCSVManifest(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest %s: %w", filename, err)
+		}
+		return parts, nil
+	default:
+		return nil, fmt.Errorf("manifest %s must be a .json or .csv file", filename)
+	}
+}
+
+// readCSVManifest reads part definitions from CSV records, mapping the columns by the header row
+func readCSVManifest(r io.Reader) ([]partDefinition, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	fields := []string{"PID", "Manufacturer", "ManufactureLocation", "PartName", "PartNumber", "Organization"}
+	for _, field := range fields {
+		if _, ok := columns[strings.ToLower(field)]; !ok {
+			return nil, fmt.Errorf("missing column %s", field)
+		}
+	}
+	column := func(record []string, field string) string {
+		i, ok := columns[strings.ToLower(field)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var parts []partDefinition
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, partDefinition{
+			PID:                 column(record, "PID"),
+			Manufacturer:        column(record, "Manufacturer"),
+			ManufactureLocation: column(record, "ManufactureLocation"),
+			PartName:            column(record, "PartName"),
+			PartNumber:          column(record, "PartNumber"),
+			Organization:        column(record, "Organization"),
+			LotID:               column(record, "LotID"),
+		})
+	}
+}
+
+// manifestChunk is the outcome of one CreatePartsBatch transaction of a manifest registration
+type manifestChunk struct {
+	First   int               `json:"first"`             // manifest index of the first part of the chunk
+	Last    int               `json:"last"`              // manifest index of the last part of the chunk
+	Status  string            `json:"status"`            // registered, rejected or failed
+	Code    string            `json:"code,omitempty"`    // error code of a rejected chunk
+	Message string            `json:"message,omitempty"` // error message of a rejected or failed chunk
+	Results []partBatchResult `json:"results,omitempty"` // per-part results, indexed by manifest index
+}
+
+// manifestSummary reports the registration of a manifest
+type manifestSummary struct {
+	Manifest   string          `json:"manifest"`
+	Parts      int             `json:"parts"`                // number of parts in the manifest
+	From       int             `json:"from"`                 // manifest index registration started from
+	Registered int             `json:"registered"`           // number of parts registered
+	Chunks     []manifestChunk `json:"chunks"`               // chunks submitted, in manifest order
+	ResumeFrom *int            `json:"resumeFrom,omitempty"` // manifest index to resume from after a rejected chunk
+}
+
+// registerPartsFromManifest submits the parts of a manifest from index from on in chunks of
+// chunkSize and returns a summary of the chunks submitted. Every chunk is a single transaction, so
+// registration stops at the first rejected chunk; the summary is returned together with the error
+// and names the index to resume from once the rejected parts are fixed.
+func registerPartsFromManifest(contract transactor, filename string, chunkSize int, from int) ([]byte, error) {
+	parts, err := loadManifest(filename)
+	if err != nil {
+		return nil, err
+	}
+	if from < 0 || from > len(parts) {
+		return nil, fmt.Errorf("cannot resume from index %d, manifest %s has %d parts", from, filename, len(parts))
+	}
+	if chunkSize < 1 {
+		chunkSize = defaultChunkSize
+	}
+
+	summary := &manifestSummary{Manifest: filename, Parts: len(parts), From: from, Chunks: []manifestChunk{}}
+	for start := from; start < len(parts); start += chunkSize {
+		end := start + chunkSize
+		if end > len(parts) {
+			end = len(parts)
+		}
+		chunkBytes, err := json.Marshal(parts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		chunk := manifestChunk{First: start, Last: end - 1, Status: "registered"}
+		resultBytes, err := contract.SubmitTransaction("CreatePartsBatch", string(chunkBytes))
+		if err != nil {
+			rejectChunk(&chunk, err)
+			summary.Chunks = append(summary.Chunks, chunk)
+			summary.ResumeFrom = &start
+			summaryBytes, marshalErr := json.Marshal(summary)
+			if marshalErr != nil {
+				return nil, marshalErr
+			}
+			return summaryBytes, fmt.Errorf("registered %d of %d parts, parts from index %d on were not registered, resume with -from %d: %w", start-from, len(parts)-from, start, start, err)
+		}
+		if json.Unmarshal(resultBytes, &chunk.Results) == nil {
+			offsetResults(chunk.Results, start)
+		}
+		summary.Chunks = append(summary.Chunks, chunk)
+		summary.Registered += end - start
+	}
+
+	return json.Marshal(summary)
+}
+
+// rejectChunk records the error of a chunk and the per-part results of a rejected chunk
+func rejectChunk(chunk *manifestChunk, err error) {
+	contractErr, ok := parseContractError(err)
+	if !ok {
+		chunk.Status = "failed"
+		chunk.Message = err.Error()
+		return
+	}
+	chunk.Status = "rejected"
+	chunk.Code = contractErr.Code
+	chunk.Message = contractErr.Message
+	if len(contractErr.Details) > 0 && json.Unmarshal(contractErr.Details, &chunk.Results) == nil {
+		offsetResults(chunk.Results, chunk.First)
+	}
+}
+
+// offsetResults turns the chunk indexes of batch results into manifest indexes
+func offsetResults(results []partBatchResult, offset int) {
+	for i := range results {
+		results[i].Index += offset
+	}
+}
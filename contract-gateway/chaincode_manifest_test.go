@@ -0,0 +1,165 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+// fakeContract records the transactions it runs and answers them with respond
+type fakeContract struct {
+	transactions [][]string
+	respond      func(name string, args []string) ([]byte, error)
+}
+
+func (c *fakeContract) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	return c.run(name, args)
+}
+
+func (c *fakeContract) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	return c.run(name, args)
+}
+
+func (c *fakeContract) run(name string, args []string) ([]byte, error) {
+	c.transactions = append(c.transactions, append([]string{name}, args...))
+	if c.respond == nil {
+		return []byte("{}"), nil
+	}
+	return c.respond(name, args)
+}
+
+// writeManifest writes a JSON manifest of count parts and returns its path
+func writeManifest(t *testing.T, count int) string {
+	t.Helper()
+	var parts []partDefinition
+	for i := 0; i < count; i++ {
+		parts = append(parts, partDefinition{
+			PID:                 fmt.Sprintf("IVSLAB-S23FA%04d", i+1),
+			Manufacturer:        "Security.Co",
+			ManufactureLocation: "Taiwan",
+			PartName:            "Security Chip",
+			PartNumber:          "SC-100",
+			Organization:        "Security-Org",
+		})
+	}
+	manifestBytes, err := json.Marshal(parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "lot.json")
+	if err := os.WriteFile(filename, manifestBytes, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// chunkPIDs returns the part IDs of every CreatePartsBatch transaction run by contract
+func chunkPIDs(t *testing.T, contract *fakeContract) [][]string {
+	t.Helper()
+	var chunks [][]string
+	for _, transaction := range contract.transactions {
+		var parts []partDefinition
+		if err := json.Unmarshal([]byte(transaction[1]), &parts); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, part := range parts {
+			ids = append(ids, part.PID)
+		}
+		chunks = append(chunks, ids)
+	}
+	return chunks
+}
+
+// batchResults returns the CreatePartsBatch results of a chunk of parts, all with the given status
+func batchResults(t *testing.T, chunk string, status string) []byte {
+	t.Helper()
+	var parts []partDefinition
+	if err := json.Unmarshal([]byte(chunk), &parts); err != nil {
+		t.Fatal(err)
+	}
+	results := []partBatchResult{}
+	for i, part := range parts {
+		results = append(results, partBatchResult{Index: i, PID: part.PID, Status: status})
+	}
+	resultBytes, err := json.Marshal(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resultBytes
+}
+
+func TestRegisterPartsFromManifestResumes(t *testing.T) {
+	filename := writeManifest(t, 5)
+
+	rejected := &fakeContract{respond: func(name string, args []string) ([]byte, error) {
+		if strings.Contains(args[0], "IVSLAB-S23FA0003") {
+			details := `[{"index":0,"PID":"IVSLAB-S23FA0003","status":"rejected","code":"ALREADY_EXISTS","message":"exists"},{"index":1,"PID":"IVSLAB-S23FA0004","status":"valid"}]`
+			return nil, peerError(t, codes.Aborted, `chaincode response 500, {"code":"ALREADY_EXISTS","message":"1 of 2 parts rejected","details":`+details+`}`)
+		}
+		return batchResults(t, args[0], "created"), nil
+	}}
+	summaryBytes, err := registerPartsFromManifest(rejected, filename, 2, 0)
+	if err == nil || !strings.Contains(err.Error(), "resume with -from 2") {
+		t.Fatalf("expected the error to name the index to resume from, got %v", err)
+	}
+	if chunks := chunkPIDs(t, rejected); len(chunks) != 2 {
+		t.Fatalf("expected registration to stop at the rejected chunk, got %v", chunks)
+	}
+	var summary manifestSummary
+	if err := json.Unmarshal(summaryBytes, &summary); err != nil {
+		t.Fatalf("unexpected summary %s: %v", summaryBytes, err)
+	}
+	if summary.Registered != 2 || summary.ResumeFrom == nil || *summary.ResumeFrom != 2 || len(summary.Chunks) != 2 {
+		t.Fatalf("unexpected summary %s", summaryBytes)
+	}
+	if chunk := summary.Chunks[0]; chunk.Status != "registered" || chunk.First != 0 || chunk.Last != 1 || len(chunk.Results) != 2 || chunk.Results[1].PID != "IVSLAB-S23FA0002" {
+		t.Fatalf("unexpected registered chunk %+v", chunk)
+	}
+	// the results of the rejected chunk are indexed by manifest index
+	chunk := summary.Chunks[1]
+	if chunk.Status != "rejected" || chunk.Code != "ALREADY_EXISTS" || chunk.First != 2 || len(chunk.Results) != 2 || chunk.Results[0].Index != 2 || chunk.Results[0].Code != "ALREADY_EXISTS" {
+		t.Fatalf("unexpected rejected chunk %+v", chunk)
+	}
+
+	resumed := &fakeContract{respond: func(name string, args []string) ([]byte, error) {
+		return batchResults(t, args[0], "created"), nil
+	}}
+	summaryBytes, err = registerPartsFromManifest(resumed, filename, 2, 2)
+	if err != nil {
+		t.Fatalf("registerPartsFromManifest failed: %v", err)
+	}
+	chunks := chunkPIDs(t, resumed)
+	if len(chunks) != 2 || chunks[0][0] != "IVSLAB-S23FA0003" || len(chunks[1]) != 1 || chunks[1][0] != "IVSLAB-S23FA0005" {
+		t.Fatalf("expected parts 2 to 4 to be registered, got %v", chunks)
+	}
+	summary = manifestSummary{}
+	if err := json.Unmarshal(summaryBytes, &summary); err != nil || summary.Registered != 3 || summary.ResumeFrom != nil || summary.Chunks[1].Results[0].Index != 4 {
+		t.Fatalf("unexpected summary %s, %v", summaryBytes, err)
+	}
+
+	failed := &fakeContract{respond: func(name string, args []string) ([]byte, error) {
+		return nil, errors.New("connection refused")
+	}}
+	summaryBytes, err = registerPartsFromManifest(failed, filename, 2, 4)
+	summary = manifestSummary{}
+	if err == nil || json.Unmarshal(summaryBytes, &summary) != nil || summary.Chunks[0].Status != "failed" || *summary.ResumeFrom != 4 {
+		t.Fatalf("unexpected summary %s, %v", summaryBytes, err)
+	}
+
+	for _, from := range []int{-1, 6} {
+		if _, err := registerPartsFromManifest(&fakeContract{}, filename, 2, from); err == nil {
+			t.Errorf("expected resuming from %d to fail", from)
+		}
+	}
+}
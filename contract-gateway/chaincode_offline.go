@@ -0,0 +1,359 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// Offline signing keeps the private key on a host without network access. The networked host
+// prepares a request file with "offline propose", the signing host signs it with "offline sign",
+// and the networked host sends it with "offline submit". Submitting a proposal endorses it and
+// leaves the transaction to sign in the same file, submitting the transaction leaves the commit
+// status request to sign, so a transaction takes three rounds.
+
+// offlineRequestVersion is the version of the request file format
+const offlineRequestVersion = 1
+
+// Stages of an offline request
+const (
+	stageProposal    = "proposal"    // to endorse, or to evaluate
+	stageTransaction = "transaction" // endorsed, to submit to the orderer
+	stageCommit      = "commit"      // submitted, to request the commit status of
+	stageCommitted   = "committed"   // committed and valid, shown by the last submit only
+)
+
+// offlineRequest is the request file carried between the networked host and the signing host
+type offlineRequest struct {
+	Version   int    `json:"version"`             // 檔案格式版本
+	Stage     string `json:"stage"`               // 目前階段 (proposal/transaction/commit)
+	Evaluate  bool   `json:"evaluate,omitempty"`  // 提案僅查詢不提交
+	Bytes     []byte `json:"bytes"`               // fabric-gateway序列化之提案、交易或提交狀態請求
+	Signature []byte `json:"signature,omitempty"` // 簽署主機產生之簽章
+	Result    []byte `json:"result,omitempty"`    // 背書時取得之交易結果
+}
+
+// offlineSummary describes an offline request, as decoded from its bytes, so that the operator can
+// check it on both hosts before signing. Digest is the SHA-256 digest of the signed message.
+type offlineSummary struct {
+	File          string          `json:"file"`
+	Stage         string          `json:"stage"`
+	Evaluate      bool            `json:"evaluate,omitempty"`
+	TransactionID string          `json:"transactionId"`
+	Channel       string          `json:"channel"`
+	Chaincode     string          `json:"chaincode,omitempty"`
+	Transaction   string          `json:"transaction,omitempty"`
+	Arguments     []string        `json:"arguments,omitempty"`
+	Digest        string          `json:"digest,omitempty"`
+	Signed        bool            `json:"signed"`
+	Next          string          `json:"next,omitempty"` // sign or submit
+	BlockNumber   uint64          `json:"blockNumber,omitempty"`
+	Result        json.RawMessage `json:"result,omitempty"`
+}
+
+// proposeOffline creates the unsigned proposal of a transaction and writes it to filename
+func proposeOffline(contract *client.Contract, filename string, evaluate bool, name string, args ...string) ([]byte, error) {
+	proposal, err := contract.NewProposal(name, client.WithArguments(args...))
+	if err != nil {
+		return nil, err
+	}
+	proposalBytes, err := proposal.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return writeOfflineRequest(filename, &offlineRequest{Stage: stageProposal, Evaluate: evaluate, Bytes: proposalBytes})
+}
+
+// signOffline signs the request of filename in place. The message is decoded and hashed from the
+// request bytes, so the signature always matches the digest shown. If digest is given, the request
+// is only signed if its digest matches, e.g. the one shown on the networked host.
+func signOffline(s *signer, filename string, digest string) ([]byte, error) {
+	request, err := readOfflineRequest(filename)
+	if err != nil {
+		return nil, err
+	}
+	summary, message, err := decodeOfflineRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if digest != "" && !strings.EqualFold(strings.ReplaceAll(digest, ":", ""), summary.Digest) {
+		return nil, fmt.Errorf("the %s of %s has digest %s, not %s; it was not signed", request.Stage, filename, summary.Digest, digest)
+	}
+
+	request.Signature, err = s.sign(s.hash(message))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the %s of %s: %w", request.Stage, filename, err)
+	}
+	return writeOfflineRequest(filename, request)
+}
+
+// submitOffline sends the signed request of filename. An endorsed proposal or a submitted
+// transaction is replaced by the next request to sign; an evaluated proposal returns the result of
+// the transaction, and the commit status request returns the outcome of the transaction.
+func submitOffline(gw *client.Gateway, filename string) ([]byte, error) {
+	request, err := readOfflineRequest(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Signature) == 0 {
+		return nil, fmt.Errorf("the %s of %s is not signed, sign it with \"offline sign\" first", request.Stage, filename)
+	}
+
+	switch request.Stage {
+	case stageProposal:
+		proposal, err := gw.NewSignedProposal(request.Bytes, request.Signature)
+		if err != nil {
+			return nil, err
+		}
+		if request.Evaluate {
+			return proposal.Evaluate()
+		}
+		transaction, err := proposal.Endorse()
+		if err != nil {
+			return nil, err
+		}
+		transactionBytes, err := transaction.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		return writeOfflineRequest(filename, &offlineRequest{Stage: stageTransaction, Bytes: transactionBytes, Result: transaction.Result()})
+
+	case stageTransaction:
+		transaction, err := gw.NewSignedTransaction(request.Bytes, request.Signature)
+		if err != nil {
+			return nil, err
+		}
+		commit, err := transaction.Submit()
+		if err != nil {
+			return nil, err
+		}
+		commitBytes, err := commit.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		return writeOfflineRequest(filename, &offlineRequest{Stage: stageCommit, Bytes: commitBytes, Result: request.Result})
+
+	case stageCommit:
+		commit, err := gw.NewSignedCommit(request.Bytes, request.Signature)
+		if err != nil {
+			return nil, err
+		}
+		commitStatus, err := commit.Status()
+		if err != nil {
+			return nil, err
+		}
+		if !commitStatus.Successful {
+			return nil, &client.CommitError{TransactionID: commitStatus.TransactionID, Code: commitStatus.Code}
+		}
+		summary, _, err := decodeOfflineRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		summary.File = filename
+		summary.Stage = stageCommitted
+		summary.Digest = ""
+		summary.Next = ""
+		summary.BlockNumber = commitStatus.BlockNumber
+		if json.Valid(request.Result) {
+			summary.Result = request.Result
+		}
+		return json.Marshal(summary)
+
+	default:
+		return nil, fmt.Errorf("%s has unknown stage %q", filename, request.Stage)
+	}
+}
+
+// showOffline describes the request of filename
+func showOffline(filename string) ([]byte, error) {
+	request, err := readOfflineRequest(filename)
+	if err != nil {
+		return nil, err
+	}
+	summary, _, err := decodeOfflineRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	summary.File = filename
+	return json.Marshal(summary)
+}
+
+// readOfflineRequest reads a request file
+func readOfflineRequest(filename string) (*offlineRequest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var request offlineRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, fmt.Errorf("failed to parse request file %s: %w", filename, err)
+	}
+	if request.Version != offlineRequestVersion {
+		return nil, fmt.Errorf("request file %s has version %d, expected %d", filename, request.Version, offlineRequestVersion)
+	}
+	switch request.Stage {
+	case stageProposal, stageTransaction, stageCommit:
+	default:
+		return nil, fmt.Errorf("request file %s has unknown stage %q", filename, request.Stage)
+	}
+	return &request, nil
+}
+
+// writeOfflineRequest writes a request file and returns its summary
+func writeOfflineRequest(filename string, request *offlineRequest) ([]byte, error) {
+	request.Version = offlineRequestVersion
+	summary, _, err := decodeOfflineRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filename, append(data, '\n')); err != nil {
+		return nil, err
+	}
+	summary.File = filename
+	return json.Marshal(summary)
+}
+
+// decodeOfflineRequest returns the summary of a request and the message its signature is made over
+func decodeOfflineRequest(request *offlineRequest) (*offlineSummary, []byte, error) {
+	summary := &offlineSummary{Stage: request.Stage, Evaluate: request.Evaluate, Signed: len(request.Signature) > 0}
+	var message []byte
+	switch request.Stage {
+	case stageProposal:
+		proposed := &gateway.ProposedTransaction{}
+		if err := unmarshal(request.Bytes, proposed, "proposal"); err != nil {
+			return nil, nil, err
+		}
+		message = proposed.GetProposal().GetProposalBytes()
+		proposal := &peer.Proposal{}
+		if err := unmarshal(message, proposal, "proposal"); err != nil {
+			return nil, nil, err
+		}
+		header := &common.Header{}
+		if err := unmarshal(proposal.GetHeader(), header, "header"); err != nil {
+			return nil, nil, err
+		}
+		if err := readChannelHeader(header, summary); err != nil {
+			return nil, nil, err
+		}
+		if err := readInvocation(proposal.GetPayload(), summary); err != nil {
+			return nil, nil, err
+		}
+
+	case stageTransaction:
+		prepared := &gateway.PreparedTransaction{}
+		if err := unmarshal(request.Bytes, prepared, "transaction"); err != nil {
+			return nil, nil, err
+		}
+		message = prepared.GetEnvelope().GetPayload()
+		payload := &common.Payload{}
+		if err := unmarshal(message, payload, "transaction payload"); err != nil {
+			return nil, nil, err
+		}
+		if err := readChannelHeader(payload.GetHeader(), summary); err != nil {
+			return nil, nil, err
+		}
+		transaction := &peer.Transaction{}
+		if err := unmarshal(payload.GetData(), transaction, "transaction"); err != nil {
+			return nil, nil, err
+		}
+		actions := transaction.GetActions()
+		if len(actions) != 1 {
+			return nil, nil, fmt.Errorf("the transaction has %d actions, expected one", len(actions))
+		}
+		actionPayload := &peer.ChaincodeActionPayload{}
+		if err := unmarshal(actions[0].GetPayload(), actionPayload, "transaction action"); err != nil {
+			return nil, nil, err
+		}
+		if err := readInvocation(actionPayload.GetChaincodeProposalPayload(), summary); err != nil {
+			return nil, nil, err
+		}
+
+	case stageCommit:
+		signed := &gateway.SignedCommitStatusRequest{}
+		if err := unmarshal(request.Bytes, signed, "commit status request"); err != nil {
+			return nil, nil, err
+		}
+		message = signed.GetRequest()
+		commitRequest := &gateway.CommitStatusRequest{}
+		if err := unmarshal(message, commitRequest, "commit status request"); err != nil {
+			return nil, nil, err
+		}
+		summary.TransactionID = commitRequest.GetTransactionId()
+		summary.Channel = commitRequest.GetChannelId()
+
+	default:
+		return nil, nil, fmt.Errorf("unknown stage %q", request.Stage)
+	}
+
+	if len(message) == 0 {
+		return nil, nil, errors.New("the request holds no message to sign")
+	}
+	digest := sha256.Sum256(message)
+	summary.Digest = hex.EncodeToString(digest[:])
+	summary.Next = "sign"
+	if summary.Signed {
+		summary.Next = "submit"
+	}
+	return summary, message, nil
+}
+
+// readChannelHeader sets the transaction ID and channel of a summary from a header
+func readChannelHeader(header *common.Header, summary *offlineSummary) error {
+	channelHeader := &common.ChannelHeader{}
+	if err := unmarshal(header.GetChannelHeader(), channelHeader, "channel header"); err != nil {
+		return err
+	}
+	summary.TransactionID = channelHeader.GetTxId()
+	summary.Channel = channelHeader.GetChannelId()
+	return nil
+}
+
+// readInvocation sets the chaincode, transaction name and arguments of a summary from a serialized
+// chaincode proposal payload
+func readInvocation(payloadBytes []byte, summary *offlineSummary) error {
+	payload := &peer.ChaincodeProposalPayload{}
+	if err := unmarshal(payloadBytes, payload, "chaincode proposal payload"); err != nil {
+		return err
+	}
+	invocation := &peer.ChaincodeInvocationSpec{}
+	if err := unmarshal(payload.GetInput(), invocation, "chaincode invocation"); err != nil {
+		return err
+	}
+	spec := invocation.GetChaincodeSpec()
+	summary.Chaincode = spec.GetChaincodeId().GetName()
+	args := spec.GetInput().GetArgs()
+	if len(args) > 0 {
+		summary.Transaction = string(args[0])
+		summary.Arguments = make([]string, len(args)-1)
+		for i, arg := range args[1:] {
+			summary.Arguments[i] = string(arg)
+		}
+	}
+	return nil
+}
+
+// unmarshal parses a protobuf message, naming what failed to parse
+func unmarshal(data []byte, message proto.Message, what string) error {
+	if err := proto.Unmarshal(data, message); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", what, err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// marshal serializes a protobuf message for a test
+func marshal(t *testing.T, message proto.Message) []byte {
+	t.Helper()
+	data, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testHeader returns the header of transaction tx1 on ivschannel
+func testHeader(t *testing.T) *common.Header {
+	return &common.Header{ChannelHeader: marshal(t, &common.ChannelHeader{TxId: "tx1", ChannelId: "ivschannel"})}
+}
+
+// testInvocation returns the chaincode proposal payload of ReadPart IVSLAB-S23FA0001 on ivs_basic
+func testInvocation(t *testing.T) []byte {
+	invocation := &peer.ChaincodeInvocationSpec{ChaincodeSpec: &peer.ChaincodeSpec{
+		ChaincodeId: &peer.ChaincodeID{Name: "ivs_basic"},
+		Input:       &peer.ChaincodeInput{Args: [][]byte{[]byte("ReadPart"), []byte("IVSLAB-S23FA0001")}},
+	}}
+	return marshal(t, &peer.ChaincodeProposalPayload{Input: marshal(t, invocation)})
+}
+
+// testRequest returns an unsigned request of a stage in the format of fabric-gateway, and the
+// message its signature is made over
+func testRequest(t *testing.T, stage string) (*offlineRequest, []byte) {
+	t.Helper()
+	var message []byte
+	request := &offlineRequest{Version: offlineRequestVersion, Stage: stage}
+	switch stage {
+	case stageProposal:
+		message = marshal(t, &peer.Proposal{Header: marshal(t, testHeader(t)), Payload: testInvocation(t)})
+		request.Bytes = marshal(t, &gateway.ProposedTransaction{TransactionId: "tx1", Proposal: &peer.SignedProposal{ProposalBytes: message}})
+	case stageTransaction:
+		action := &peer.TransactionAction{Payload: marshal(t, &peer.ChaincodeActionPayload{ChaincodeProposalPayload: testInvocation(t)})}
+		message = marshal(t, &common.Payload{Header: testHeader(t), Data: marshal(t, &peer.Transaction{Actions: []*peer.TransactionAction{action}})})
+		request.Bytes = marshal(t, &gateway.PreparedTransaction{TransactionId: "tx1", Envelope: &common.Envelope{Payload: message}})
+	case stageCommit:
+		message = marshal(t, &gateway.CommitStatusRequest{TransactionId: "tx1", ChannelId: "ivschannel"})
+		request.Bytes = marshal(t, &gateway.SignedCommitStatusRequest{Request: message})
+	}
+	return request, message
+}
+
+// writeTestRequest writes a request file to a temporary directory
+func writeTestRequest(t *testing.T, request *offlineRequest) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "request.json")
+	data, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestDecodeOfflineRequest(t *testing.T) {
+	invocation := &offlineSummary{TransactionID: "tx1", Channel: "ivschannel", Chaincode: "ivs_basic", Transaction: "ReadPart", Arguments: []string{"IVSLAB-S23FA0001"}}
+	tests := []struct {
+		stage string
+		want  *offlineSummary
+	}{
+		{stageProposal, invocation},
+		{stageTransaction, invocation},
+		{stageCommit, &offlineSummary{TransactionID: "tx1", Channel: "ivschannel"}},
+	}
+	for _, test := range tests {
+		t.Run(test.stage, func(t *testing.T) {
+			request, wantMessage := testRequest(t, test.stage)
+			for _, signed := range []bool{false, true} {
+				request.Signature = nil
+				if signed {
+					request.Signature = []byte("signature")
+				}
+				summary, message, err := decodeOfflineRequest(request)
+				if err != nil {
+					t.Fatalf("decodeOfflineRequest failed: %v", err)
+				}
+				if !reflect.DeepEqual(message, wantMessage) {
+					t.Fatalf("decoded the wrong message to sign")
+				}
+				digest := sha256.Sum256(wantMessage)
+				want := *test.want
+				want.Stage, want.Digest, want.Signed, want.Next = test.stage, hex.EncodeToString(digest[:]), signed, "sign"
+				if signed {
+					want.Next = "submit"
+				}
+				if !reflect.DeepEqual(summary, &want) {
+					t.Errorf("got summary %+v, want %+v", summary, &want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeOfflineRequestRejects(t *testing.T) {
+	twoActions, _ := testRequest(t, stageTransaction)
+	action := &peer.TransactionAction{Payload: marshal(t, &peer.ChaincodeActionPayload{ChaincodeProposalPayload: testInvocation(t)})}
+	payload := &common.Payload{Header: testHeader(t), Data: marshal(t, &peer.Transaction{Actions: []*peer.TransactionAction{action, action}})}
+	twoActions.Bytes = marshal(t, &gateway.PreparedTransaction{Envelope: &common.Envelope{Payload: marshal(t, payload)}})
+
+	tests := []struct {
+		name    string
+		request *offlineRequest
+		want    string
+	}{
+		{"unknown stage", &offlineRequest{Stage: "draft"}, `unknown stage "draft"`},
+		{"garbage", &offlineRequest{Stage: stageProposal, Bytes: []byte("not a proposal")}, "failed to parse proposal"},
+		{"empty commit status request", &offlineRequest{Stage: stageCommit, Bytes: marshal(t, &gateway.SignedCommitStatusRequest{})}, "no message to sign"},
+		{"two actions", twoActions, "the transaction has 2 actions, expected one"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := decodeOfflineRequest(test.request)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestReadOfflineRequest(t *testing.T) {
+	request, _ := testRequest(t, stageCommit)
+	if _, err := readOfflineRequest(writeTestRequest(t, request)); err != nil {
+		t.Fatalf("readOfflineRequest failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(request *offlineRequest)
+		want   string
+	}{
+		{"wrong version", func(request *offlineRequest) { request.Version = 2 }, "has version 2, expected 1"},
+		{"unknown stage", func(request *offlineRequest) { request.Stage = stageCommitted }, `has unknown stage "committed"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changed := *request
+			test.change(&changed)
+			_, err := readOfflineRequest(writeTestRequest(t, &changed))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestSignOffline(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCredentials(t, "user1")
+	s, err := newSigner("brandMSP", certificatePEM, privateKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, message := testRequest(t, stageProposal)
+	filename := writeTestRequest(t, request)
+	digest := sha256.Sum256(message)
+
+	if _, err := signOffline(s, filename, strings.Repeat("00", sha256.Size)); err == nil || !strings.Contains(err.Error(), "it was not signed") {
+		t.Fatalf("expected a digest mismatch to be refused, got %v", err)
+	}
+	unsigned, err := readOfflineRequest(filename)
+	if err != nil || len(unsigned.Signature) != 0 {
+		t.Fatalf("expected the refused request to stay unsigned, got %+v, %v", unsigned, err)
+	}
+
+	// the digest may be given as shown by other tools, in upper case with colons
+	var shown []string
+	for _, b := range digest {
+		shown = append(shown, strings.ToUpper(hex.EncodeToString([]byte{b})))
+	}
+	summaryBytes, err := signOffline(s, filename, strings.Join(shown, ":"))
+	if err != nil {
+		t.Fatalf("signOffline failed: %v", err)
+	}
+	var summary offlineSummary
+	if err := json.Unmarshal(summaryBytes, &summary); err != nil || !summary.Signed || summary.Next != "submit" {
+		t.Fatalf("unexpected summary %s, %v", summaryBytes, err)
+	}
+	signed, err := readOfflineRequest(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ecdsa.VerifyASN1(certificate.PublicKey.(*ecdsa.PublicKey), digest[:], signed.Signature) {
+		t.Error("the signature does not verify against the digest of the proposal")
+	}
+}
+
+func TestSubmitOfflineRequiresSignature(t *testing.T) {
+	for _, stage := range []string{stageProposal, stageTransaction, stageCommit} {
+		request, _ := testRequest(t, stage)
+		// the unsigned request is refused before the Gateway is used
+		_, err := submitOffline(nil, writeTestRequest(t, request))
+		if err == nil || !strings.Contains(err.Error(), "is not signed") {
+			t.Errorf("expected the unsigned %s to be refused, got %v", stage, err)
+		}
+	}
+}
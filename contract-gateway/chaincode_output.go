@@ -0,0 +1,158 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of the command line interface
+const (
+	outputJSON  = "json"
+	outputTable = "table"
+	outputYAML  = "yaml"
+)
+
+// Table columns of the results returned by the chaincode. A column is a dotted path into a result object.
+var (
+	partColumns         = []string{"PID", "PartName", "PartNumber", "Organization", "Status", "AssetID", "LotID"}
+	assetColumns        = []string{"ID", "ModelID", "MadeBy", "MadeIn", "SerialNumber", "Status", "Revision"}
+	partHistoryColumns  = []string{"txId", "timestamp", "isDelete", "record.Organization", "record.Status", "record.AssetID"}
+	assetHistoryColumns = []string{"txId", "timestamp", "isDelete", "record.MadeIn", "record.SerialNumber", "record.Status", "record.Revision"}
+	provenanceColumns   = []string{"timestamp", "objectType", "objectID", "action", "organization"}
+	offerColumns        = []string{"OfferID", "PartIDs", "FromOrganization", "ToOrganization", "Status", "Expires"}
+	inventoryColumns    = []string{"organization", "count"}
+	lotColumns          = []string{"LotID", "Manufacturer", "Organization", "FabLocation", "Quantity", "ProductionStart", "ProductionEnd"}
+	walletColumns       = []string{"label", "mspId", "keyType", "subject", "notAfter"}
+)
+
+// validOutput returns an error unless format is a supported output format
+func validOutput(format string) error {
+	switch format {
+	case outputJSON, outputTable, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q, expected json, table or yaml", format)
+	}
+}
+
+// writeOutput renders the JSON result of a transaction on w. A table has one row per element of an
+// array result and the given columns; an object result is shown as one field per row. An empty
+// result, e.g. of a transaction that returns nothing, prints nothing.
+func writeOutput(w io.Writer, format string, result []byte, columns []string) error {
+	if len(bytes.TrimSpace(result)) == 0 {
+		return nil
+	}
+	switch format {
+	case outputJSON:
+		_, err := fmt.Fprintln(w, formatJSON(result))
+		return err
+	case outputYAML:
+		var value interface{}
+		if err := json.Unmarshal(result, &value); err != nil {
+			return fmt.Errorf("failed to parse result: %w", err)
+		}
+		yamlBytes, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(yamlBytes)
+		return err
+	case outputTable:
+		var value interface{}
+		if err := json.Unmarshal(result, &value); err != nil {
+			return fmt.Errorf("failed to parse result: %w", err)
+		}
+		return writeTable(w, value, columns)
+	default:
+		return validOutput(format)
+	}
+}
+
+// writeTable renders a parsed JSON result as a table
+func writeTable(w io.Writer, value interface{}, columns []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	switch value := value.(type) {
+	case []interface{}:
+		if len(columns) == 0 {
+			fmt.Fprintln(tw, "VALUE")
+			for _, row := range value {
+				fmt.Fprintln(tw, formatCell(row))
+			}
+			break
+		}
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range value {
+			cells := make([]string, len(columns))
+			for i, column := range columns {
+				cells[i] = formatCell(lookup(row, column))
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", key, formatCell(value[key]))
+		}
+	default:
+		fmt.Fprintln(tw, formatCell(value))
+	}
+	return tw.Flush()
+}
+
+// lookup returns the field of a JSON object at a dotted path, or nil if there is none
+func lookup(value interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// formatCell formats a JSON value for a table cell. Arrays are joined with commas, nested objects
+// are shown as compact JSON.
+func formatCell(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "-"
+	case string:
+		if value == "" {
+			return "-"
+		}
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case []interface{}:
+		cells := make([]string, len(value))
+		for i, element := range value {
+			cells[i] = formatCell(element)
+		}
+		return strings.Join(cells, ",")
+	default:
+		valueBytes, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(valueBytes)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteOutput(t *testing.T) {
+	parts := `[{"PID":"IVSLAB-S23FA0001","PartName":"SecurityChip","Organization":"Security-Org","Status":"installed","AssetID":"IVSLAB-PVC23FG0001"},` +
+		`{"PID":"IVSLAB-S23FA0002","PartName":"SecurityChip","Organization":"Security-Org","Status":"available"}]`
+	history := `[{"txId":"tx1","timestamp":"2023-05-15T08:00:00Z","isDelete":false,"record":{"Organization":"Brand-Org","Status":"installed"}}]`
+	tests := []struct {
+		name    string
+		format  string
+		result  string
+		columns []string
+		want    string
+	}{
+		{
+			name:   "json",
+			format: outputJSON,
+			result: `{"PID":"IVSLAB-S23FA0001"}`,
+			want:   "{\n  \"PID\": \"IVSLAB-S23FA0001\"\n}\n",
+		},
+		{
+			name:   "yaml",
+			format: outputYAML,
+			result: `{"PID":"IVSLAB-S23FA0001","Quantity":500,"PartIDs":["a","b"]}`,
+			want:   "PID: IVSLAB-S23FA0001\nPartIDs:\n    - a\n    - b\nQuantity: 500\n",
+		},
+		{
+			name:    "table of an array",
+			format:  outputTable,
+			result:  parts,
+			columns: []string{"PID", "Status", "AssetID"},
+			want: "PID               STATUS     ASSETID\n" +
+				"IVSLAB-S23FA0001  installed  IVSLAB-PVC23FG0001\n" +
+				"IVSLAB-S23FA0002  available  -\n",
+		},
+		{
+			name:    "table with nested columns",
+			format:  outputTable,
+			result:  history,
+			columns: []string{"txId", "isDelete", "record.Organization", "record.AssetID"},
+			want: "TXID  ISDELETE  RECORD.ORGANIZATION  RECORD.ASSETID\n" +
+				"tx1   false     Brand-Org            -\n",
+		},
+		{
+			name:   "table of an object",
+			format: outputTable,
+			result: `{"PID":"IVSLAB-S23FA0001","PartIDs":["a","b"],"Lot":{"LotID":"LOT1"}}`,
+			want: "Lot      {\"LotID\":\"LOT1\"}\n" +
+				"PID      IVSLAB-S23FA0001\n" +
+				"PartIDs  a,b\n",
+		},
+		{
+			name:   "table without columns",
+			format: outputTable,
+			result: `["IVSLAB-S23FA0001","IVSLAB-S23FA0002"]`,
+			want:   "VALUE\nIVSLAB-S23FA0001\nIVSLAB-S23FA0002\n",
+		},
+		{
+			name:   "empty result",
+			format: outputTable,
+			result: "",
+			want:   "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeOutput(&out, test.format, []byte(test.result), test.columns); err != nil {
+				t.Fatalf("writeOutput failed: %v", err)
+			}
+			if out.String() != test.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestWriteOutputErrors(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeOutput(&out, "xml", []byte(`{}`), nil); err == nil || !strings.Contains(err.Error(), `unknown output format "xml"`) {
+		t.Errorf("expected an unknown output format error, got %v", err)
+	}
+	if err := writeOutput(&out, outputTable, []byte(`not json`), nil); err == nil {
+		t.Error("expected a result that is not JSON to be rejected")
+	}
+}
@@ -0,0 +1,618 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"google.golang.org/grpc"
+)
+
+// openAPISpec documents the REST API, it is served at /openapi.yaml
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// maxRequestBody limits the size of a request body
+const maxRequestBody = 1 << 20
+
+// transactionRetention is how long the outcome of an asynchronous submission can be polled
+const transactionRetention = time.Hour
+
+// States of a transaction resource
+const (
+	transactionPending   = "PENDING"   // submitted to the orderer, not committed yet
+	transactionCommitted = "COMMITTED" // committed and valid
+	transactionFailed    = "FAILED"    // committed but invalidated, see validationCode
+	transactionUnknown   = "UNKNOWN"   // the commit status could not be obtained
+)
+
+// transaction is the pollable resource of an asynchronous submission
+type transaction struct {
+	TransactionID  string          `json:"transactionId"`
+	Name           string          `json:"name"`
+	Status         string          `json:"status"`
+	Result         json.RawMessage `json:"result,omitempty"`
+	BlockNumber    uint64          `json:"blockNumber,omitempty"`
+	ValidationCode string          `json:"validationCode,omitempty"`
+	Error          *apiError       `json:"error,omitempty"`
+	Submitted      time.Time       `json:"submitted"`
+	Completed      *time.Time      `json:"completed,omitempty"`
+	client         string          // bearer token the transaction was submitted with
+}
+
+// transactionStore tracks asynchronous submissions until their retention expires
+type transactionStore struct {
+	mu           sync.Mutex
+	transactions map[string]*transaction
+}
+
+func newTransactionStore() *transactionStore {
+	return &transactionStore{transactions: make(map[string]*transaction)}
+}
+
+// track records a transaction submitted by a client and waits for its commit status in the background
+func (s *transactionStore) track(client string, name string, commit pendingCommit, result []byte) transaction {
+	tx := &transaction{
+		TransactionID: commit.TransactionID(),
+		Name:          name,
+		Status:        transactionPending,
+		Submitted:     time.Now().UTC(),
+		client:        client,
+	}
+	if len(result) > 0 && json.Valid(result) {
+		tx.Result = result
+	}
+
+	s.mu.Lock()
+	for id, old := range s.transactions {
+		if time.Since(old.Submitted) > transactionRetention {
+			delete(s.transactions, id)
+		}
+	}
+	s.transactions[tx.TransactionID] = tx
+	submitted := *tx
+	s.mu.Unlock()
+
+	go func() {
+		commitStatus, err := commit.Status()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		completed := time.Now().UTC()
+		tx.Completed = &completed
+		switch {
+		case err != nil:
+			_, tx.Error = httpError(err)
+			tx.Status = transactionUnknown
+		case !commitStatus.Successful:
+			tx.Status = transactionFailed
+			tx.BlockNumber = commitStatus.BlockNumber
+			tx.ValidationCode = fmt.Sprint(commitStatus.Code)
+			tx.Error = &apiError{
+				Code:          apiCodeCommitFailed,
+				Message:       fmt.Sprintf("transaction %s failed to commit with status %v", tx.TransactionID, commitStatus.Code),
+				TransactionID: tx.TransactionID,
+			}
+		default:
+			tx.Status = transactionCommitted
+			tx.BlockNumber = commitStatus.BlockNumber
+		}
+	}()
+
+	return submitted
+}
+
+// get returns a copy of a transaction tracked for a client. The transactions of other clients
+// and transactions past their retention are not found.
+func (s *transactionStore) get(client string, transactionID string) (transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, ok := s.transactions[transactionID]
+	if !ok || tx.client != client || time.Since(tx.Submitted) > transactionRetention {
+		return transaction{}, false
+	}
+	return *tx, true
+}
+
+// pendingCommit is the commit of a transaction submitted without waiting for it, a *client.Commit
+type pendingCommit interface {
+	TransactionID() string
+	Status(opts ...grpc.CallOption) (*client.Status, error)
+}
+
+// asyncTransactor also submits transactions without waiting for them to commit
+type asyncTransactor interface {
+	transactor
+	SubmitAsync(name string, args ...string) ([]byte, pendingCommit, error)
+}
+
+// gatewayContract is the asyncTransactor of a Gateway contract
+type gatewayContract struct {
+	*client.Contract
+}
+
+func (c gatewayContract) SubmitAsync(name string, args ...string) ([]byte, pendingCommit, error) {
+	result, commit, err := c.Contract.SubmitAsync(name, client.WithArguments(args...))
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, commit, nil
+}
+
+// asyncContract submits a transaction without waiting for it to commit and keeps its commit, so
+// that the REST API can return the submission as a transaction resource
+type asyncContract struct {
+	contract asyncTransactor
+	name     string
+	commit   pendingCommit
+}
+
+func (c *asyncContract) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	if c.commit != nil {
+		return nil, fmt.Errorf("transaction %s cannot be submitted, a request submits only one transaction", name)
+	}
+	result, commit, err := c.contract.SubmitAsync(name, args...)
+	if err != nil {
+		return nil, err
+	}
+	c.name, c.commit = name, commit
+	return result, nil
+}
+
+func (c *asyncContract) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	return c.contract.EvaluateTransaction(name, args...)
+}
+
+// badRequest is returned by a handler when the request itself is invalid
+func badRequest(field string, format string, args ...interface{}) error {
+	return &apiError{Code: errCodeInvalidArgument, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// request is a REST API request matched to a route
+type request struct {
+	*http.Request
+	caller   caller            // client and identity of the request
+	contract asyncTransactor   // contract of the identity selected by the request
+	params   map[string]string // path parameters
+	header   http.Header       // headers of the response
+}
+
+// decode parses the JSON body of the request into v
+func (r *request) decode(v interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return badRequest("body", "invalid request body: %v", err)
+	}
+	return nil
+}
+
+// intQuery returns an integer query parameter, or fallback if it is absent
+func (r *request) intQuery(name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, badRequest(name, "%s must be an integer, got %q", name, value)
+	}
+	return n, nil
+}
+
+// handler serves a route. An evaluation returns the JSON result; a submission goes through submit.
+type handler func(s *apiServer, r *request) (int, []byte, error)
+
+// route maps a method and a path pattern to a handler. Path segments in braces are parameters.
+type route struct {
+	method  string
+	pattern string
+	handle  handler
+}
+
+// evaluate returns a handler that responds with the result of an evaluation
+func evaluate(op func(contract transactor, r *request) ([]byte, error)) handler {
+	return func(s *apiServer, r *request) (int, []byte, error) {
+		result, err := op(r.contract, r)
+		return http.StatusOK, result, err
+	}
+}
+
+// submit returns a handler that submits a transaction without waiting for it to commit and
+// responds with 202 Accepted and the transaction resource to poll for its outcome
+func submit(op func(contract transactor, r *request) ([]byte, error)) handler {
+	return func(s *apiServer, r *request) (int, []byte, error) {
+		contract := &asyncContract{contract: r.contract}
+		result, err := op(contract, r)
+		if err != nil {
+			return 0, nil, err
+		}
+		if contract.commit == nil {
+			return 0, nil, fmt.Errorf("no transaction was submitted")
+		}
+		tx := s.transactions.track(r.caller.client, contract.name, contract.commit, result)
+		r.header.Set("Location", "/transactions/"+url.PathEscape(tx.TransactionID))
+		txBytes, err := json.Marshal(tx)
+		return http.StatusAccepted, txBytes, err
+	}
+}
+
+// revision is the optional expected revision of a change of an asset, a missing revision skips the check
+func revision(expectedRevision *int) int {
+	if expectedRevision == nil {
+		return -1
+	}
+	return *expectedRevision
+}
+
+// routes of the REST API
+var routes = []route{
+	{http.MethodGet, "/parts", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		organization := r.URL.Query().Get("organization")
+		if organization == "" {
+			return getAllParts(contract)
+		}
+		pageSize, err := r.intQuery("pageSize", 100)
+		if err != nil {
+			return nil, err
+		}
+		if pageSize < 1 {
+			return nil, badRequest("pageSize", "pageSize must be positive, got %d", pageSize)
+		}
+		return getPartsByOrganization(contract, organization, pageSize)
+	})},
+	{http.MethodPost, "/parts", submit(func(contract transactor, r *request) ([]byte, error) {
+		var part partDefinition
+		if err := r.decode(&part); err != nil {
+			return nil, err
+		}
+		return createPart(contract, part)
+	})},
+	{http.MethodGet, "/parts/{partID}", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		return readPart(contract, r.params["partID"])
+	})},
+	{http.MethodGet, "/parts/{partID}/history", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		return getPartHistory(contract, r.params["partID"])
+	})},
+	{http.MethodPost, "/parts/{partID}/transfers", submit(func(contract transactor, r *request) ([]byte, error) {
+		var body struct {
+			NewOrganization string `json:"NewOrganization"`
+		}
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+		return transferPart(contract, r.params["partID"], body.NewOrganization)
+	})},
+	{http.MethodGet, "/transfers", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		organization := r.URL.Query().Get("organization")
+		if organization == "" {
+			return nil, badRequest("organization", "the organization to list the pending offers of is required")
+		}
+		return getPendingTransferOffers(contract, organization)
+	})},
+	{http.MethodPost, "/transfers/{offerID}/accept", submit(func(contract transactor, r *request) ([]byte, error) {
+		return acceptPartTransfer(contract, r.params["offerID"])
+	})},
+	{http.MethodGet, "/organizations", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		return countPartsByOrganization(contract)
+	})},
+	{http.MethodPost, "/organizations/{organization}/transfers", submit(func(contract transactor, r *request) ([]byte, error) {
+		var body struct {
+			NewOrganization string `json:"NewOrganization"`
+		}
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+		return transferPartsByOrganization(contract, r.params["organization"], body.NewOrganization)
+	})},
+
+	{http.MethodGet, "/assets", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		values := r.URL.Query()
+		query := assetQuery{
+			MadeBy:           values.Get("madeBy"),
+			MadeIn:           values.Get("madeIn"),
+			SerialNumberFrom: values.Get("serialNumberFrom"),
+			SerialNumberTo:   values.Get("serialNumberTo"),
+			ProducedFrom:     values.Get("producedFrom"),
+			ProducedTo:       values.Get("producedTo"),
+			PartID:           values.Get("partID"),
+			PartManufacturer: values.Get("partManufacturer"),
+			SortBy:           values.Get("sortBy"),
+			SortDescending:   values.Get("sortDescending") == "true",
+		}
+		// listing by brand reads composite keys and also works on LevelDB-backed peers
+		if query == (assetQuery{MadeBy: query.MadeBy}) {
+			return getAssets(contract, query.MadeBy)
+		}
+		return searchAssets(contract, query)
+	})},
+	{http.MethodPost, "/assets", submit(func(contract transactor, r *request) ([]byte, error) {
+		var body struct {
+			ID           string    `json:"ID"`
+			ModelID      string    `json:"ModelID"`
+			MadeBy       string    `json:"MadeBy"`
+			MadeIn       string    `json:"MadeIn"`
+			SerialNumber string    `json:"SerialNumber"`
+			Components   []bomItem `json:"Components"`
+		}
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+		return createAsset(contract, body.ID, body.ModelID, body.MadeBy, body.MadeIn, body.SerialNumber, body.Components)
+	})},
+	{http.MethodGet, "/assets/{assetID}", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		return readAsset(contract, r.params["assetID"])
+	})},
+	{http.MethodPut, "/assets/{assetID}", submit(func(contract transactor, r *request) ([]byte, error) {
+		var body struct {
+			MadeBy       string    `json:"MadeBy"`
+			MadeIn       string    `json:"MadeIn"`
+			SerialNumber string    `json:"SerialNumber"`
+			Components   []bomItem `json:"Components"`
+		}
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+		return updateAsset(contract, r.params["assetID"], body.MadeBy, body.MadeIn, body.SerialNumber, body.Components)
+	})},
+	{http.MethodDelete, "/assets/{assetID}", submit(func(contract transactor, r *request) ([]byte, error) {
+		values := r.URL.Query()
+		reason := values.Get("reason")
+		if reason == "" {
+			return nil, badRequest("reason", "the reason of the decommissioning is required")
+		}
+		return decommissionAsset(contract, r.params["assetID"], values.Get("scrapParts") == "true", reason)
+	})},
+	{http.MethodGet, "/assets/{assetID}/history", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		return getAssetHistory(contract, r.params["assetID"])
+	})},
+	{http.MethodGet, "/assets/{assetID}/provenance", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		return getAssetProvenance(contract, r.params["assetID"])
+	})},
+	{http.MethodPut, "/assets/{assetID}/location", submit(func(contract transactor, r *request) ([]byte, error) {
+		var body struct {
+			MadeIn           string `json:"MadeIn"`
+			Reason           string `json:"Reason"`
+			ExpectedRevision *int   `json:"ExpectedRevision"`
+		}
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+		return updateAssetLocation(contract, r.params["assetID"], body.MadeIn, body.Reason, revision(body.ExpectedRevision))
+	})},
+	{http.MethodPost, "/assets/{assetID}/part-replacements", submit(func(contract transactor, r *request) ([]byte, error) {
+		var body struct {
+			OldPartID        string `json:"OldPartID"`
+			NewPartID        string `json:"NewPartID"`
+			Reason           string `json:"Reason"`
+			ExpectedRevision *int   `json:"ExpectedRevision"`
+		}
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+		return replaceAssetPart(contract, r.params["assetID"], body.OldPartID, body.NewPartID, body.Reason, revision(body.ExpectedRevision))
+	})},
+	{http.MethodPost, "/assets/{assetID}/repairs", submit(func(contract transactor, r *request) ([]byte, error) {
+		var body struct {
+			repairOrder
+			ExpectedRevision *int `json:"ExpectedRevision"`
+		}
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+		if body.AssetID != "" && body.AssetID != r.params["assetID"] {
+			return nil, badRequest("AssetID", "the repair order is for asset %s, not %s", body.AssetID, r.params["assetID"])
+		}
+		body.AssetID = r.params["assetID"]
+		return repairAsset(contract, body.repairOrder, revision(body.ExpectedRevision))
+	})},
+
+	{http.MethodPost, "/lots", submit(func(contract transactor, r *request) ([]byte, error) {
+		var body lot
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+		return createLot(contract, body)
+	})},
+	{http.MethodGet, "/lots/{lotID}", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		return readLot(contract, r.params["lotID"])
+	})},
+	{http.MethodGet, "/lots/{lotID}/parts", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		return getPartsByLot(contract, r.params["lotID"])
+	})},
+	{http.MethodGet, "/lots/{lotID}/assets", evaluate(func(contract transactor, r *request) ([]byte, error) {
+		return getAssetsByLot(contract, r.params["lotID"])
+	})},
+
+	{http.MethodGet, "/transactions/{transactionID}", func(s *apiServer, r *request) (int, []byte, error) {
+		tx, ok := s.transactions.get(r.caller.client, r.params["transactionID"])
+		if !ok {
+			return 0, nil, &apiError{Code: errCodeNotFound, Message: fmt.Sprintf("the transaction %s is not tracked by this server", r.params["transactionID"])}
+		}
+		txBytes, err := json.Marshal(tx)
+		return http.StatusOK, txBytes, err
+	}},
+}
+
+// identityHeader selects the wallet identity a request is made with, among the identities allowed
+// for the bearer token of the request
+const identityHeader = "X-IVS-Identity"
+
+// apiServer serves the REST API. The requests of each identity share one Gateway connection, and
+// all Gateway connections share one gRPC connection.
+type apiServer struct {
+	contract     func(label string) (asyncTransactor, error) // contract of a wallet identity, "" for the connection profile identity
+	auth         *authenticator                              // nil to make every request with the identity of the connection profile
+	transactions *transactionStore
+}
+
+func newAPIServer(conns *connections, auth *authenticator) *apiServer {
+	return &apiServer{contract: conns.contract, auth: auth, transactions: newTransactionStore()}
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/openapi.yaml" && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(openAPISpec)
+		return
+	}
+
+	handle, params, allowed := matchRoute(r.Method, r.URL.EscapedPath())
+	if handle == nil {
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			writeError(w, http.StatusMethodNotAllowed, &apiError{Code: errCodeInvalidArgument, Message: fmt.Sprintf("method %s is not allowed on %s", r.Method, r.URL.Path)})
+			return
+		}
+		writeError(w, http.StatusNotFound, &apiError{Code: errCodeNotFound, Message: fmt.Sprintf("no resource at %s", r.URL.Path)})
+		return
+	}
+
+	caller, httpStatus, apiErr := s.auth.authorize(r)
+	if apiErr != nil {
+		if httpStatus == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="contract-gateway"`)
+		}
+		writeError(w, httpStatus, apiErr)
+		return
+	}
+	contract, err := s.contract(caller.label)
+	if errors.Is(err, errIdentityNotFound) {
+		writeError(w, http.StatusBadRequest, &apiError{Code: errCodeInvalidArgument, Field: identityHeader, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, &apiError{Code: errCodeInternal, Message: err.Error()})
+		return
+	}
+
+	httpStatus, body, err := handle(s, &request{Request: r, caller: caller, contract: contract, params: params, header: w.Header()})
+	if err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			writeError(w, httpStatusOf(apiErr), apiErr)
+			return
+		}
+		httpStatus, apiErr = httpError(err)
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		writeError(w, httpStatus, apiErr)
+		return
+	}
+	if len(body) == 0 {
+		w.WriteHeader(httpStatus)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	w.Write(body)
+}
+
+// httpStatusOf returns the HTTP status of an error raised by the REST API itself
+func httpStatusOf(apiErr *apiError) int {
+	if httpStatus, ok := httpStatuses[apiErr.Code]; ok {
+		return httpStatus
+	}
+	return http.StatusInternalServerError
+}
+
+// matchRoute returns the handler and path parameters of the route matching a request, or the
+// methods allowed on the path if only the method does not match
+func matchRoute(method string, path string) (handler, map[string]string, []string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var allowed []string
+	for _, route := range routes {
+		params, ok := matchPath(route.pattern, segments)
+		if !ok {
+			continue
+		}
+		if route.method != method {
+			allowed = append(allowed, route.method)
+			continue
+		}
+		return route.handle, params, nil
+	}
+	return nil, nil, allowed
+}
+
+// matchPath matches path segments against a route pattern and returns the path parameters
+func matchPath(pattern string, segments []string) (map[string]string, bool) {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	if len(patternSegments) != len(segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, patternSegment := range patternSegments {
+		if strings.HasPrefix(patternSegment, "{") && strings.HasSuffix(patternSegment, "}") {
+			value, err := url.PathUnescape(segments[i])
+			if err != nil || value == "" {
+				return nil, false
+			}
+			params[strings.Trim(patternSegment, "{}")] = value
+		} else if patternSegment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// contract returns the contract of a wallet identity for the REST API
+func (c *connections) contract(label string) (asyncTransactor, error) {
+	s, err := c.session(label)
+	if err != nil {
+		return nil, err
+	}
+	return gatewayContract{s.contract}, nil
+}
+
+// writeError writes the JSON body of a failed request
+func writeError(w http.ResponseWriter, httpStatus int, apiErr *apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(apiErr)
+}
+
+// serveAPI serves the REST API on address until ctx is cancelled. Without bearer tokens the server
+// only listens on a loopback address, because it then signs for any caller.
+func serveAPI(ctx context.Context, conns *connections, auth *authenticator, address string) error {
+	if auth == nil && !isLoopback(address) {
+		return fmt.Errorf("the REST API serves %s only with bearer tokens, use -tokens or IVS_API_TOKENS", address)
+	}
+	server := &http.Server{
+		Addr:              address,
+		Handler:           newAPIServer(conns, auth),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		shutdownErr <- server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Serving the REST API on %s, see /openapi.yaml", address)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-shutdownErr
+}
@@ -0,0 +1,260 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/grpc"
+)
+
+// fakeCommit is the commit of a transaction submitted to fakeAsyncContract. Its status is
+// reported once the commit is released.
+type fakeCommit struct {
+	id      string
+	release chan struct{}
+	status  *client.Status
+}
+
+func (c *fakeCommit) TransactionID() string {
+	return c.id
+}
+
+func (c *fakeCommit) Status(opts ...grpc.CallOption) (*client.Status, error) {
+	<-c.release
+	return c.status, nil
+}
+
+// fakeAsyncContract records the transactions it runs like fakeContract, and submits them without
+// waiting for their commit
+type fakeAsyncContract struct {
+	fakeContract
+	commits []*fakeCommit
+}
+
+func (c *fakeAsyncContract) SubmitAsync(name string, args ...string) ([]byte, pendingCommit, error) {
+	result, err := c.run(name, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	commit := &fakeCommit{
+		id:      fmt.Sprintf("tx%d", len(c.commits)+1),
+		release: make(chan struct{}),
+		status:  &client.Status{Successful: true, BlockNumber: 7},
+	}
+	c.commits = append(c.commits, commit)
+	return result, commit, nil
+}
+
+// newTestServer returns a REST API server with the tokens of testAuthenticator that makes every
+// request on contract
+func newTestServer(t *testing.T, contract *fakeAsyncContract) *apiServer {
+	t.Helper()
+	return &apiServer{
+		contract: func(label string) (asyncTransactor, error) {
+			return contract, nil
+		},
+		auth:         testAuthenticator(t),
+		transactions: newTransactionStore(),
+	}
+}
+
+// serveTest serves a request with a bearer token and returns the response
+func serveTest(server *apiServer, method string, path string, token string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	return w
+}
+
+// decodeTransaction parses the transaction resource of a response
+func decodeTransaction(t *testing.T, w *httptest.ResponseRecorder) transaction {
+	t.Helper()
+	var tx transaction
+	if err := json.Unmarshal(w.Body.Bytes(), &tx); err != nil {
+		t.Fatalf("unexpected transaction %s: %v", w.Body.String(), err)
+	}
+	return tx
+}
+
+// pollTransaction gets a transaction resource until it is no longer pending
+func pollTransaction(t *testing.T, server *apiServer, location string, token string) transaction {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := serveTest(server, http.MethodGet, location, token, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d polling %s: %s", w.Code, location, w.Body.String())
+		}
+		tx := decodeTransaction(t, w)
+		if tx.Status != transactionPending {
+			return tx
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("transaction %s is still pending", tx.TransactionID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMatchRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		params  map[string]string
+		allowed []string
+	}{
+		{name: "parameter", method: http.MethodGet, path: "/parts/IVSLAB-S23FA0001", params: map[string]string{"partID": "IVSLAB-S23FA0001"}},
+		{name: "trailing slash", method: http.MethodGet, path: "/parts/IVSLAB-S23FA0001/history/", params: map[string]string{"partID": "IVSLAB-S23FA0001"}},
+		{name: "escaped parameter", method: http.MethodGet, path: "/lots/LOT%2FN2305%20A/parts", params: map[string]string{"lotID": "LOT/N2305 A"}},
+		{name: "submission", method: http.MethodPost, path: "/transfers/offer1/accept", params: map[string]string{"offerID": "offer1"}},
+		{name: "method not allowed", method: http.MethodPatch, path: "/assets/IVSLAB-PVC23FG0001", allowed: []string{http.MethodGet, http.MethodPut, http.MethodDelete}},
+		{name: "invalid escape", method: http.MethodGet, path: "/parts/%zz"},
+		{name: "empty parameter", method: http.MethodGet, path: "/parts//history"},
+		{name: "unknown path", method: http.MethodGet, path: "/cameras/IVSLAB-PVC23FG0001"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handle, params, allowed := matchRoute(test.method, test.path)
+			if (handle != nil) != (test.params != nil) {
+				t.Fatalf("got a handler: %v, want a handler: %v", handle != nil, test.params != nil)
+			}
+			if test.params != nil && !reflect.DeepEqual(params, test.params) {
+				t.Errorf("got parameters %v, want %v", params, test.params)
+			}
+			if !reflect.DeepEqual(allowed, test.allowed) {
+				t.Errorf("got allowed methods %v, want %v", allowed, test.allowed)
+			}
+		})
+	}
+}
+
+func TestServeHTTPRoutes(t *testing.T) {
+	contract := &fakeAsyncContract{}
+	server := newTestServer(t, contract)
+
+	w := serveTest(server, http.MethodPatch, "/assets/IVSLAB-PVC23FG0001", erpToken, "")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, PUT, DELETE" {
+		t.Fatalf("got status %d with Allow %q, want 405 with GET, PUT, DELETE", w.Code, w.Header().Get("Allow"))
+	}
+
+	w = serveTest(server, http.MethodGet, "/parts/IVSLAB%2FS23FA0001", erpToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+	}
+	w = serveTest(server, http.MethodPost, "/parts/IVSLAB-S23FA0001/transfers", erpToken, `{"NewOrganization":"Brand-Org","Reason":"repair"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown body field to be rejected, got status %d", w.Code)
+	}
+	want := [][]string{{"ReadPart", "IVSLAB/S23FA0001"}}
+	if !reflect.DeepEqual(contract.transactions, want) {
+		t.Errorf("got transactions %v, want %v", contract.transactions, want)
+	}
+}
+
+func TestSubmitAndPollTransaction(t *testing.T) {
+	contract := &fakeAsyncContract{fakeContract: fakeContract{respond: func(name string, args []string) ([]byte, error) {
+		return []byte("offer1"), nil
+	}}}
+	server := newTestServer(t, contract)
+
+	w := serveTest(server, http.MethodPost, "/parts/IVSLAB-S23FA0001/transfers", erpToken, `{"NewOrganization":"Brand-Org"}`)
+	if w.Code != http.StatusAccepted || w.Header().Get("Location") != "/transactions/tx1" {
+		t.Fatalf("got status %d with Location %q: %s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	tx := decodeTransaction(t, w)
+	if tx.TransactionID != "tx1" || tx.Name != "TransferPart" || tx.Status != transactionPending || string(tx.Result) != `{"offerId":"offer1"}` {
+		t.Fatalf("unexpected transaction %s", w.Body.String())
+	}
+	if want := [][]string{{"TransferPart", "IVSLAB-S23FA0001", "Brand-Org"}}; !reflect.DeepEqual(contract.transactions, want) {
+		t.Fatalf("got transactions %v, want %v", contract.transactions, want)
+	}
+
+	// the transaction is tracked for the token it was submitted with only
+	if w := serveTest(server, http.MethodGet, "/transactions/tx1", serviceToken, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected the transaction of another client to be not found, got status %d", w.Code)
+	}
+	if w := serveTest(server, http.MethodGet, "/transactions/tx1", erpToken, ""); w.Code != http.StatusOK || decodeTransaction(t, w).Status != transactionPending {
+		t.Fatalf("expected a pending transaction, got status %d: %s", w.Code, w.Body.String())
+	}
+
+	close(contract.commits[0].release)
+	tx = pollTransaction(t, server, "/transactions/tx1", erpToken)
+	if tx.Status != transactionCommitted || tx.BlockNumber != 7 || tx.Completed == nil || tx.Error != nil {
+		t.Fatalf("unexpected committed transaction %+v", tx)
+	}
+
+	w = serveTest(server, http.MethodPost, "/transfers/offer1/accept", serviceToken, "")
+	if w.Code != http.StatusAccepted || w.Header().Get("Location") != "/transactions/tx2" {
+		t.Fatalf("got status %d with Location %q: %s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	contract.commits[1].status = &client.Status{Code: peer.TxValidationCode_MVCC_READ_CONFLICT, BlockNumber: 8}
+	close(contract.commits[1].release)
+	tx = pollTransaction(t, server, "/transactions/tx2", serviceToken)
+	if tx.Status != transactionFailed || tx.ValidationCode != "MVCC_READ_CONFLICT" || tx.Error == nil || tx.Error.Code != apiCodeCommitFailed {
+		t.Fatalf("unexpected failed transaction %+v", tx)
+	}
+}
+
+func TestAsyncContractSubmitsOnce(t *testing.T) {
+	fake := &fakeAsyncContract{}
+	contract := &asyncContract{contract: fake}
+	if _, err := contract.SubmitTransaction("TransferPart", "IVSLAB-S23FA0001", "Brand-Org"); err != nil {
+		t.Fatalf("SubmitTransaction failed: %v", err)
+	}
+	_, err := contract.SubmitTransaction("AcceptPartTransfer", "offer1")
+	if err == nil || !strings.Contains(err.Error(), "a request submits only one transaction") {
+		t.Fatalf("expected a second submission to be refused, got %v", err)
+	}
+	if contract.name != "TransferPart" || contract.commit.TransactionID() != "tx1" || len(fake.transactions) != 1 {
+		t.Fatalf("expected only the first transaction to be submitted, got %v", fake.transactions)
+	}
+	// evaluations are not limited
+	for i := 0; i < 2; i++ {
+		if _, err := contract.EvaluateTransaction("ReadPart", "IVSLAB-S23FA0001"); err != nil {
+			t.Fatalf("EvaluateTransaction failed: %v", err)
+		}
+	}
+}
+
+func TestTransactionStoreExpiry(t *testing.T) {
+	store := newTransactionStore()
+	first := &fakeCommit{id: "tx1", release: make(chan struct{}), status: &client.Status{Successful: true}}
+	store.track("erp", "CreatePart", first, nil)
+	if _, ok := store.get("erp", "tx1"); !ok {
+		t.Fatal("expected the transaction to be tracked")
+	}
+	if _, ok := store.get("service-desk", "tx1"); ok {
+		t.Fatal("expected the transaction of another client to be not found")
+	}
+
+	store.mu.Lock()
+	store.transactions["tx1"].Submitted = time.Now().Add(-transactionRetention - time.Minute)
+	store.mu.Unlock()
+	if _, ok := store.get("erp", "tx1"); ok {
+		t.Fatal("expected a transaction past its retention to be not found")
+	}
+	store.track("erp", "CreatePart", &fakeCommit{id: "tx2", release: make(chan struct{})}, nil)
+	store.mu.Lock()
+	_, kept := store.transactions["tx1"]
+	store.mu.Unlock()
+	if kept {
+		t.Fatal("expected the expired transaction to be dropped when another one is tracked")
+	}
+	close(first.release)
+}
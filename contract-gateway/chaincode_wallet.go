@@ -0,0 +1,530 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/hash"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Wallet types of a connection profile
+const (
+	walletFileSystem = "filesystem"
+	walletEncrypted  = "encrypted"
+)
+
+// walletPassphraseEnv holds the passphrase of an encrypted wallet. It is deliberately not a flag,
+// so that it does not show up in the process list.
+const walletPassphraseEnv = "IVS_WALLET_PASSPHRASE"
+
+// Key derivation of encrypted wallets
+const (
+	walletKDF           = "pbkdf2-sha256"
+	walletKDFIterations = 600000
+	walletKeyLength     = 32
+)
+
+// walletIdentity is a client identity stored in a wallet, in the format of the Fabric SDK wallets
+type walletIdentity struct {
+	Version     int               `json:"version"`
+	MSPID       string            `json:"mspId"`
+	Type        string            `json:"type"`
+	Credentials walletCredentials `json:"credentials"`
+}
+
+// walletCredentials are the PEM encoded certificate and private key of an identity
+type walletCredentials struct {
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"privateKey"`
+}
+
+// wallet stores client identities by label
+type wallet interface {
+	Get(label string) (*walletIdentity, error)
+	Put(label string, id *walletIdentity) error
+	Remove(label string) error
+	List() ([]string, error)
+}
+
+// errIdentityNotFound is returned by a wallet that holds no identity with the requested label
+var errIdentityNotFound = errors.New("identity not found")
+
+// openWallet opens the wallet of a connection profile
+func openWallet(profile *connectionProfile) (wallet, error) {
+	if profile.Wallet == "" {
+		return nil, fmt.Errorf("the connection profile has no wallet, set wallet or use -wallet or IVS_WALLET")
+	}
+	switch profile.WalletType {
+	case "", walletFileSystem:
+		return &fileSystemWallet{dir: profile.Wallet}, nil
+	case walletEncrypted:
+		passphrase := os.Getenv(walletPassphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("the encrypted wallet %s needs its passphrase in %s", profile.Wallet, walletPassphraseEnv)
+		}
+		return &encryptedWallet{path: profile.Wallet, passphrase: []byte(passphrase)}, nil
+	default:
+		return nil, fmt.Errorf("unknown wallet type %q, expected %s or %s", profile.WalletType, walletFileSystem, walletEncrypted)
+	}
+}
+
+// checkLabel returns an error unless a label can name an identity in any wallet
+func checkLabel(label string) error {
+	if label == "" || strings.ContainsAny(label, `/\`) || strings.HasPrefix(label, ".") {
+		return fmt.Errorf("invalid identity label %q", label)
+	}
+	return nil
+}
+
+// fileSystemWallet stores every identity in a <label>.id file of a directory, like the wallets of
+// the Fabric SDKs
+type fileSystemWallet struct {
+	dir string
+}
+
+func (w *fileSystemWallet) Get(label string) (*walletIdentity, error) {
+	if err := checkLabel(label); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(w.path(label))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: no identity %q in wallet %s", errIdentityNotFound, label, w.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var id walletIdentity
+	if err := json.Unmarshal(data, &id); err != nil {
+		return nil, fmt.Errorf("failed to parse identity %q of wallet %s: %w", label, w.dir, err)
+	}
+	return &id, nil
+}
+
+func (w *fileSystemWallet) Put(label string, id *walletIdentity) error {
+	if err := checkLabel(label); err != nil {
+		return err
+	}
+	data, err := json.Marshal(id)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(w.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create wallet %s: %w", w.dir, err)
+	}
+	return writeFileAtomic(w.path(label), data)
+}
+
+func (w *fileSystemWallet) Remove(label string) error {
+	if err := checkLabel(label); err != nil {
+		return err
+	}
+	err := os.Remove(w.path(label))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: no identity %q in wallet %s", errIdentityNotFound, label, w.dir)
+	}
+	return err
+}
+
+func (w *fileSystemWallet) List() ([]string, error) {
+	entries, err := os.ReadDir(w.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".id") {
+			labels = append(labels, strings.TrimSuffix(entry.Name(), ".id"))
+		}
+	}
+	sort.Strings(labels)
+	return labels, nil
+}
+
+func (w *fileSystemWallet) path(label string) string {
+	return filepath.Join(w.dir, label+".id")
+}
+
+// encryptedWalletFile is the content of an encrypted wallet. The identities are sealed with
+// AES-256-GCM under a key derived from the passphrase; salt and nonce change with every write.
+type encryptedWalletFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// encryptedWallet stores every identity in a single passphrase-protected file
+type encryptedWallet struct {
+	path       string
+	passphrase []byte
+}
+
+func (w *encryptedWallet) Get(label string) (*walletIdentity, error) {
+	identities, err := w.read()
+	if err != nil {
+		return nil, err
+	}
+	id, ok := identities[label]
+	if !ok {
+		return nil, fmt.Errorf("%w: no identity %q in wallet %s", errIdentityNotFound, label, w.path)
+	}
+	return id, nil
+}
+
+func (w *encryptedWallet) Put(label string, id *walletIdentity) error {
+	if err := checkLabel(label); err != nil {
+		return err
+	}
+	identities, err := w.read()
+	if err != nil {
+		return err
+	}
+	identities[label] = id
+	return w.write(identities)
+}
+
+func (w *encryptedWallet) Remove(label string) error {
+	identities, err := w.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := identities[label]; !ok {
+		return fmt.Errorf("%w: no identity %q in wallet %s", errIdentityNotFound, label, w.path)
+	}
+	delete(identities, label)
+	return w.write(identities)
+}
+
+func (w *encryptedWallet) List() ([]string, error) {
+	identities, err := w.read()
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]string, 0, len(identities))
+	for label := range identities {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels, nil
+}
+
+// read decrypts the identities of the wallet. A wallet file that does not exist yet is empty.
+func (w *encryptedWallet) read() (map[string]*walletIdentity, error) {
+	data, err := os.ReadFile(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]*walletIdentity), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file encryptedWalletFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse wallet %s: %w", w.path, err)
+	}
+	if file.Version != 1 || file.KDF != walletKDF || file.Iterations < 1 {
+		return nil, fmt.Errorf("wallet %s has an unsupported format", w.path)
+	}
+	aead, err := newWalletCipher(w.passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt wallet %s, is %s correct?", w.path, walletPassphraseEnv)
+	}
+	identities := make(map[string]*walletIdentity)
+	if err := json.Unmarshal(plaintext, &identities); err != nil {
+		return nil, fmt.Errorf("failed to parse the identities of wallet %s: %w", w.path, err)
+	}
+	return identities, nil
+}
+
+// write encrypts the identities with a new salt and nonce and replaces the wallet file
+func (w *encryptedWallet) write(identities map[string]*walletIdentity) error {
+	plaintext, err := json.Marshal(identities)
+	if err != nil {
+		return err
+	}
+	file := encryptedWalletFile{Version: 1, KDF: walletKDF, Iterations: walletKDFIterations, Salt: make([]byte, 16)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	aead, err := newWalletCipher(w.passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create the directory of wallet %s: %w", w.path, err)
+	}
+	return writeFileAtomic(w.path, data)
+}
+
+// newWalletCipher returns the AES-256-GCM cipher keyed by a passphrase
+func newWalletCipher(passphrase []byte, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key(passphrase, salt, iterations, walletKeyLength, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFileAtomic replaces a file readable by its owner only, so that a failed write never leaves
+// a truncated wallet behind
+func writeFileAtomic(filename string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := file.Chmod(0o600); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filename)
+}
+
+// signer is a client identity ready to connect: its X.509 identity, the function signing with its
+// private key and the hash the Gateway applies to messages before they are signed
+type signer struct {
+	id   *identity.X509Identity
+	sign identity.Sign
+	hash hash.Hash
+}
+
+// newSigner creates the signer of a certificate and its private key. It fails if the private key
+// does not belong to the certificate, so that a wrong key is detected before anything is signed.
+// ECDSA keys sign a SHA-256 digest; Ed25519 keys sign the whole message.
+func newSigner(mspID string, certificatePEM []byte, privateKeyPEM []byte) (*signer, error) {
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyPair(certificate, privateKey); err != nil {
+		return nil, err
+	}
+
+	s := &signer{hash: hash.SHA256}
+	if _, ok := privateKey.(ed25519.PrivateKey); ok {
+		s.hash = hash.NONE
+	}
+	s.id, err = identity.NewX509Identity(mspID, certificate)
+	if err != nil {
+		return nil, err
+	}
+	s.sign, err = identity.NewPrivateKeySign(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parsePrivateKey parses a PEM encoded PKCS #8 or SEC 1 private key. Only ECDSA and Ed25519 keys
+// are supported by Fabric.
+func parsePrivateKey(privateKeyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse private key: no PEM data found")
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		var ecErr error
+		privateKey, ecErr = x509.ParseECPrivateKey(block.Bytes)
+		if ecErr != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+	}
+	switch privateKey := privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		return privateKey, nil
+	case ed25519.PrivateKey:
+		return privateKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T, expected an ECDSA or Ed25519 key", privateKey)
+	}
+}
+
+// checkKeyPair returns an error unless the private key belongs to the certificate
+func checkKeyPair(certificate *x509.Certificate, privateKey crypto.Signer) error {
+	publicKey, ok := privateKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(certificate.PublicKey) {
+		return fmt.Errorf("the private key does not belong to the certificate of %s", certificate.Subject.CommonName)
+	}
+	return nil
+}
+
+// identityInfo describes an identity of a wallet without its private key
+type identityInfo struct {
+	Label    string `json:"label"`
+	MSPID    string `json:"mspId"`
+	Subject  string `json:"subject"`
+	KeyType  string `json:"keyType"`
+	NotAfter string `json:"notAfter"`
+}
+
+// describeIdentity returns the description of an identity of a wallet
+func describeIdentity(label string, id *walletIdentity) (*identityInfo, error) {
+	certificate, err := identity.CertificateFromPEM([]byte(id.Credentials.Certificate))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the certificate of identity %q: %w", label, err)
+	}
+	info := &identityInfo{
+		Label:    label,
+		MSPID:    id.MSPID,
+		Subject:  certificate.Subject.String(),
+		KeyType:  certificate.PublicKeyAlgorithm.String(),
+		NotAfter: certificate.NotAfter.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	return info, nil
+}
+
+// loadSigner returns the signer of an identity. With a wallet the identity is looked up by label,
+// the profile's identity by default; without a wallet the profile's certPath and keyPath are used.
+func loadSigner(profile *connectionProfile, label string) (*signer, error) {
+	mspID, certificatePEM, privateKeyPEM, err := readCredentials(profile, label, true)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSigner(mspID, certificatePEM, privateKeyPEM)
+	if err != nil && profile.Wallet != "" {
+		if label == "" {
+			label = profile.Identity
+		}
+		return nil, fmt.Errorf("identity %q: %w", label, err)
+	}
+	return s, err
+}
+
+// loadIdentity returns an identity like loadSigner, without reading its private key. It is used
+// where the private key is kept on another host.
+func loadIdentity(profile *connectionProfile, label string) (*identity.X509Identity, error) {
+	mspID, certificatePEM, _, err := readCredentials(profile, label, false)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return identity.NewX509Identity(mspID, certificate)
+}
+
+// readCredentials returns the MSP ID, certificate and, if withKey is set, private key of an identity
+func readCredentials(profile *connectionProfile, label string, withKey bool) (string, []byte, []byte, error) {
+	if profile.Wallet == "" {
+		if label != "" {
+			return "", nil, nil, fmt.Errorf("identity %q cannot be selected, the connection profile has no wallet", label)
+		}
+		certificatePEM, err := os.ReadFile(profile.CertPath)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to read certificate file: %w", err)
+		}
+		var privateKeyPEM []byte
+		if withKey {
+			privateKeyPEM, err = readPrivateKey(profile.KeyPath)
+			if err != nil {
+				return "", nil, nil, err
+			}
+		}
+		return profile.MSPID, certificatePEM, privateKeyPEM, nil
+	}
+
+	if label == "" {
+		label = profile.Identity
+	}
+	if label == "" {
+		return "", nil, nil, fmt.Errorf("no identity selected, use -identity or IVS_IDENTITY to choose one of wallet %s", profile.Wallet)
+	}
+	w, err := openWallet(profile)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	id, err := w.Get(label)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if profile.MSPID != "" && id.MSPID != profile.MSPID {
+		return "", nil, nil, fmt.Errorf("identity %q belongs to %s, not to %s of the connection profile", label, id.MSPID, profile.MSPID)
+	}
+	var privateKeyPEM []byte
+	if withKey {
+		privateKeyPEM = []byte(id.Credentials.PrivateKey)
+	}
+	return id.MSPID, []byte(id.Credentials.Certificate), privateKeyPEM, nil
+}
+
+// importIdentity verifies a certificate and private key pair and stores it in the wallet of a profile
+func importIdentity(profile *connectionProfile, label string, mspID string, certPath string, keyPath string) (*identityInfo, error) {
+	if err := checkLabel(label); err != nil {
+		return nil, err
+	}
+	certificatePEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	privateKeyPEM, err := readPrivateKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := newSigner(mspID, certificatePEM, privateKeyPEM); err != nil {
+		return nil, err
+	}
+	w, err := openWallet(profile)
+	if err != nil {
+		return nil, err
+	}
+	id := &walletIdentity{
+		Version:     1,
+		MSPID:       mspID,
+		Type:        "X.509",
+		Credentials: walletCredentials{Certificate: string(certificatePEM), PrivateKey: string(privateKeyPEM)},
+	}
+	if err := w.Put(label, id); err != nil {
+		return nil, err
+	}
+	return describeIdentity(label, id)
+}
@@ -0,0 +1,264 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCredentials returns a self-signed PEM certificate and the PEM PKCS #8 private key of a
+// new ECDSA P-256 key pair
+func newTestCredentials(t *testing.T, commonName string) ([]byte, []byte) {
+	t.Helper()
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestCertificate(t, commonName, privateKey), encodeTestKey(t, privateKey)
+}
+
+// newTestCertificate returns a self-signed PEM certificate of a private key
+func newTestCertificate(t *testing.T, commonName string, privateKey crypto.Signer) []byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"brand.ivsorg.net"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificateDER, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER})
+}
+
+// encodeTestKey returns the PEM PKCS #8 encoding of a private key
+func encodeTestKey(t *testing.T, privateKey crypto.Signer) []byte {
+	t.Helper()
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+// newTestIdentity returns a wallet identity of brandMSP with new credentials
+func newTestIdentity(t *testing.T, commonName string) *walletIdentity {
+	t.Helper()
+	certificatePEM, privateKeyPEM := newTestCredentials(t, commonName)
+	return &walletIdentity{
+		Version:     1,
+		MSPID:       "brandMSP",
+		Type:        "X.509",
+		Credentials: walletCredentials{Certificate: string(certificatePEM), PrivateKey: string(privateKeyPEM)},
+	}
+}
+
+// testWalletContract runs the checks every wallet implementation must pass
+func testWalletContract(t *testing.T, w wallet) {
+	t.Helper()
+	labels, err := w.List()
+	if err != nil || len(labels) != 0 {
+		t.Fatalf("expected a new wallet to be empty, got %v, %v", labels, err)
+	}
+	user1, admin := newTestIdentity(t, "user1"), newTestIdentity(t, "admin")
+	for label, id := range map[string]*walletIdentity{"user1": user1, "admin": admin} {
+		if err := w.Put(label, id); err != nil {
+			t.Fatalf("Put %s failed: %v", label, err)
+		}
+	}
+
+	got, err := w.Get("user1")
+	if err != nil || !reflect.DeepEqual(got, user1) {
+		t.Fatalf("Get returned %+v, %v", got, err)
+	}
+	labels, err = w.List()
+	if err != nil || !reflect.DeepEqual(labels, []string{"admin", "user1"}) {
+		t.Fatalf("List returned %v, %v", labels, err)
+	}
+
+	if err := w.Remove("admin"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := w.Get("admin"); !errors.Is(err, errIdentityNotFound) {
+		t.Errorf("expected a removed identity to be not found, got %v", err)
+	}
+	if err := w.Remove("admin"); !errors.Is(err, errIdentityNotFound) {
+		t.Errorf("expected removing a missing identity to fail, got %v", err)
+	}
+	for _, label := range []string{"", "../user1", ".hidden"} {
+		if err := w.Put(label, user1); err == nil {
+			t.Errorf("expected label %q to be rejected", label)
+		}
+	}
+}
+
+func TestFileSystemWallet(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "wallet")
+	testWalletContract(t, &fileSystemWallet{dir: dir})
+
+	info, err := os.Stat(filepath.Join(dir, "user1.id"))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected user1.id to be readable by its owner only, got %v, %v", info, err)
+	}
+	// identities stored by the Fabric SDKs are read as well
+	sdkIdentity := `{"version":1,"mspId":"brandMSP","type":"X.509","credentials":{"certificate":"cert","privateKey":"key"}}`
+	if err := os.WriteFile(filepath.Join(dir, "sdk.id"), []byte(sdkIdentity), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	id, err := (&fileSystemWallet{dir: dir}).Get("sdk")
+	if err != nil || id.MSPID != "brandMSP" || id.Credentials.Certificate != "cert" {
+		t.Fatalf("failed to read an SDK identity: %+v, %v", id, err)
+	}
+}
+
+func TestEncryptedWallet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	testWalletContract(t, &encryptedWallet{path: path, passphrase: []byte("correct horse")})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "PRIVATE KEY") || strings.Contains(string(data), "user1") {
+		t.Fatalf("expected the wallet file to be encrypted, got %s", data)
+	}
+	// a new instance decrypts what the first one wrote
+	labels, err := (&encryptedWallet{path: path, passphrase: []byte("correct horse")}).List()
+	if err != nil || !reflect.DeepEqual(labels, []string{"user1"}) {
+		t.Fatalf("List returned %v, %v", labels, err)
+	}
+}
+
+func TestEncryptedWalletRejects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	if err := (&encryptedWallet{path: path, passphrase: []byte("correct horse")}).Put("user1", newTestIdentity(t, "user1")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file encryptedWalletFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		change     func(file *encryptedWalletFile)
+		want       string
+	}{
+		{name: "wrong passphrase", passphrase: "battery staple", want: "failed to decrypt"},
+		{name: "tampered ciphertext", change: func(file *encryptedWalletFile) { file.Ciphertext[0] ^= 0x01 }, want: "failed to decrypt"},
+		{name: "tampered salt", change: func(file *encryptedWalletFile) { file.Salt[0] ^= 0x01 }, want: "failed to decrypt"},
+		{name: "unknown key derivation", change: func(file *encryptedWalletFile) { file.KDF = "md5" }, want: "unsupported format"},
+		{name: "unknown version", change: func(file *encryptedWalletFile) { file.Version = 2 }, want: "unsupported format"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changed := file
+			changed.Salt = append([]byte(nil), file.Salt...)
+			changed.Ciphertext = append([]byte(nil), file.Ciphertext...)
+			if test.change != nil {
+				test.change(&changed)
+			}
+			changedPath := filepath.Join(t.TempDir(), "wallet.json")
+			changedData, err := json.Marshal(changed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(changedPath, changedData, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			passphrase := "correct horse"
+			if test.passphrase != "" {
+				passphrase = test.passphrase
+			}
+			_, err = (&encryptedWallet{path: changedPath, passphrase: []byte(passphrase)}).Get("user1")
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCredentials(t, "user1")
+	s, err := newSigner("brandMSP", certificatePEM, privateKeyPEM)
+	if err != nil {
+		t.Fatalf("newSigner failed: %v", err)
+	}
+	if s.id.MspID() != "brandMSP" {
+		t.Errorf("got MSP ID %s", s.id.MspID())
+	}
+	if _, err := s.sign(make([]byte, 32)); err != nil {
+		t.Errorf("failed to sign: %v", err)
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newSigner("brandMSP", newTestCertificate(t, "user2", ed25519Key), encodeTestKey(t, ed25519Key)); err != nil {
+		t.Errorf("newSigner failed for an Ed25519 key: %v", err)
+	}
+
+	_, otherKeyPEM := newTestCredentials(t, "admin")
+	if _, err := newSigner("brandMSP", certificatePEM, otherKeyPEM); err == nil || !strings.Contains(err.Error(), "does not belong to the certificate of user1") {
+		t.Errorf("expected the key of another certificate to be rejected, got %v", err)
+	}
+	if _, err := newSigner("brandMSP", certificatePEM, []byte("not a key")); err == nil {
+		t.Error("expected a private key without PEM data to be rejected")
+	}
+}
+
+func TestImportIdentity(t *testing.T) {
+	dir := t.TempDir()
+	certificatePEM, privateKeyPEM := newTestCredentials(t, "user1")
+	_, otherKeyPEM := newTestCredentials(t, "admin")
+	files := map[string][]byte{"cert.pem": certificatePEM, "key.pem": privateKeyPEM, "other.pem": otherKeyPEM}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	profile := &connectionProfile{Wallet: filepath.Join(dir, "wallet"), WalletType: walletFileSystem}
+
+	if _, err := importIdentity(profile, "user1", "brandMSP", filepath.Join(dir, "cert.pem"), filepath.Join(dir, "other.pem")); err == nil {
+		t.Fatal("expected a certificate with the key of another identity to be rejected")
+	}
+	info, err := importIdentity(profile, "user1", "brandMSP", filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil || info.MSPID != "brandMSP" || !strings.Contains(info.Subject, "CN=user1") || info.KeyType != "ECDSA" {
+		t.Fatalf("unexpected import result %+v, %v", info, err)
+	}
+
+	s, err := loadSigner(profile, "user1")
+	if err != nil || s.id.MspID() != "brandMSP" {
+		t.Fatalf("loadSigner failed: %v", err)
+	}
+	profile.MSPID = "securityMSP"
+	if _, err := loadSigner(profile, "user1"); err == nil || !strings.Contains(err.Error(), "belongs to brandMSP") {
+		t.Errorf("expected an identity of another MSP to be rejected, got %v", err)
+	}
+}
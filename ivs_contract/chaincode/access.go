@@ -0,0 +1,157 @@
+package chaincode
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// adminAttribute is the certificate attribute that grants admin rights, e.g. for raw CouchDB queries.
+// It is added to an identity at enrollment with --id.attrs 'ivs.admin=true:ecert'.
+const adminAttribute = "ivs.admin"
+
+// Organizations used in Part.Organization
+const (
+	SecurityOrg   = "Security-Org"
+	NetworkOrg    = "Network-Org"
+	CMOSOrg       = "CMOS-Org"
+	VideoCodecOrg = "VideoCodec-Org"
+	BrandOrg      = "Brand-Org"
+)
+
+// mspOrganizations maps the MSP ID of a client to the organization it acts for
+var mspOrganizations = map[string]string{
+	"securityMSP":   SecurityOrg,
+	"networkMSP":    NetworkOrg,
+	"cmosMSP":       CMOSOrg,
+	"videocodecMSP": VideoCodecOrg,
+	"brandMSP":      BrandOrg,
+}
+
+// chipMakers are the organizations allowed to register new parts
+var chipMakers = map[string]bool{
+	SecurityOrg:   true,
+	NetworkOrg:    true,
+	CMOSOrg:       true,
+	VideoCodecOrg: true,
+}
+
+// isOrganization returns true when organization is one of the network's organizations
+func isOrganization(organization string) bool {
+	for _, org := range mspOrganizations {
+		if org == organization {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessDeniedError is returned when the calling client is not allowed to invoke a transaction.
+// Its message is a JSON document so that clients can tell denials apart from other failures.
+type AccessDeniedError struct {
+	Code         string `json:"code"`
+	MSPID        string `json:"mspId"`
+	Organization string `json:"organization,omitempty"`
+	Message      string `json:"message"`
+}
+
+func (e *AccessDeniedError) Error() string {
+	errBytes, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+	return string(errBytes)
+}
+
+// accessDenied builds an AccessDeniedError for the calling client
+func accessDenied(mspID, organization, format string, args ...interface{}) error {
+	return &AccessDeniedError{
+		Code:         ErrCodeForbidden,
+		MSPID:        mspID,
+		Organization: organization,
+		Message:      fmt.Sprintf(format, args...),
+	}
+}
+
+// getClientOrganization returns the organization of the calling client, derived from its MSP ID
+func getClientOrganization(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", internalError("failed to get client MSP ID: %v", err)
+	}
+	organization, ok := mspOrganizations[mspID]
+	if !ok {
+		return "", accessDenied(mspID, "", "MSP %s is not a member of the traceability network", mspID)
+	}
+
+	return organization, nil
+}
+
+// getClientIdentity returns the organization and the identity of the calling client, e.g.
+// "Brand-Org/x509::CN=user1,OU=client::CN=ca.brand.ivs.com", for audit fields
+func getClientIdentity(ctx contractapi.TransactionContextInterface) (string, error) {
+	organization, err := getClientOrganization(ctx)
+	if err != nil {
+		return "", err
+	}
+	id, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", internalError("failed to get client ID: %v", err)
+	}
+	// the client ID is the base64 encoding of the certificate subject and issuer
+	if decoded, err := base64.StdEncoding.DecodeString(id); err == nil {
+		id = string(decoded)
+	}
+
+	return organization + "/" + id, nil
+}
+
+// requireOrganization returns an error unless the calling client acts for one of the given organizations
+func requireOrganization(ctx contractapi.TransactionContextInterface, organizations ...string) error {
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	for _, organization := range organizations {
+		if clientOrg == organization {
+			return nil
+		}
+	}
+	mspID, _ := ctx.GetClientIdentity().GetMSPID()
+
+	return accessDenied(mspID, clientOrg, "organization %s is not allowed to perform this operation", clientOrg)
+}
+
+// requireChipMaker returns an error unless the calling client is the chip maker named by organization
+func requireChipMaker(ctx contractapi.TransactionContextInterface, organization string) error {
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	mspID, _ := ctx.GetClientIdentity().GetMSPID()
+	if !chipMakers[clientOrg] {
+		return accessDenied(mspID, clientOrg, "only chip makers may create parts, %s is not a chip maker", clientOrg)
+	}
+	if clientOrg != organization {
+		return accessDenied(mspID, clientOrg, "organization %s may not create parts for %s", clientOrg, organization)
+	}
+
+	return nil
+}
+
+// requireAdmin returns an error unless the calling client is a network member whose certificate carries ivs.admin=true
+func requireAdmin(ctx contractapi.TransactionContextInterface) error {
+	clientOrg, err := getClientOrganization(ctx)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true")
+	if err != nil {
+		mspID, _ := ctx.GetClientIdentity().GetMSPID()
+		return accessDenied(mspID, clientOrg, "only admins may perform this operation")
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestAccessDenied(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		mspID string
+		fn    func(ctx contractapi.TransactionContextInterface) error
+	}{
+		{"unknown MSP", "otherMSP", func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreatePart(ctx, "IVSLAB-S23FA0009", "Security.Co", "Taiwan", "SecurityChip-v1", "SPN3R1C00AA9", SecurityOrg)
+		}},
+		{"part for another chip maker", networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreatePart(ctx, "IVSLAB-S23FA0009", "Security.Co", "Taiwan", "SecurityChip-v1", "SPN3R1C00AA9", SecurityOrg)
+		}},
+		{"part by the brand", brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreatePart(ctx, "IVSLAB-S23FA0009", "Security.Co", "Taiwan", "SecurityChip-v1", "SPN3R1C00AA9", BrandOrg)
+		}},
+		{"asset by a chip maker", securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreateAsset(ctx, "IVSLAB-PVC23FG0002", DefaultProductModelID, "Brand.Co", "Taiwan", "IVSPN902300AACDC02",
+				chipSet("IVSLAB-S23FA0002", "IVSLAB-N23FA0002", "IVSLAB-C23FA0002", "IVSLAB-V23FA0002"))
+		}},
+		{"update by a chip maker", cmosMSP, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FG0001", "Brand.Co", "Taiwan", "IVSPN902300AACDC01",
+				chipSet("IVSLAB-S23FA0001", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001"))
+		}},
+		{"delete asset by a chip maker", videocodecMSP, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.DeleteAsset(ctx, "IVSLAB-PVC23FG0001", false, "end of life")
+		}},
+		{"delete part of another organization", networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.DeletePart(ctx, "IVSLAB-S23FA0002", "defective")
+		}},
+		{"transfer part of another organization", brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.TransferPart(ctx, "IVSLAB-S23FA0002", NetworkOrg)
+			return err
+		}},
+		{"transfer all parts of another organization", brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.TransferPartsByOrganization(ctx, SecurityOrg, BrandOrg)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.submit(tt.mspID, tt.fn)
+			var denied *AccessDeniedError
+			if !errors.As(err, &denied) {
+				t.Fatalf("expected an AccessDeniedError, got %v", err)
+			}
+			var payload map[string]string
+			if err := json.Unmarshal([]byte(err.Error()), &payload); err != nil {
+				t.Fatalf("expected a JSON error message, got %q", err.Error())
+			}
+			if payload["code"] != "FORBIDDEN" || payload["mspId"] != tt.mspID {
+				t.Fatalf("unexpected error payload %v", payload)
+			}
+		})
+	}
+}
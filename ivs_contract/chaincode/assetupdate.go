@@ -0,0 +1,228 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Reason codes recorded with every change of an asset. ReasonDecommission is only recorded by DeleteAsset.
+const (
+	ReasonCorrection      = "CORRECTION"
+	ReasonRelocation      = "RELOCATION"
+	ReasonSerialChange    = "SERIAL_CHANGE"
+	ReasonPartReplacement = "PART_REPLACEMENT"
+	ReasonRepair          = "REPAIR"
+	ReasonDecommission    = "DECOMMISSION"
+)
+
+// assetUpdateReasons are the reason codes clients may give for a change of an asset
+var assetUpdateReasons = map[string]bool{
+	ReasonCorrection:      true,
+	ReasonRelocation:      true,
+	ReasonSerialChange:    true,
+	ReasonPartReplacement: true,
+	ReasonRepair:          true,
+}
+
+// UpdateAssetLocation changes where an asset is located. A negative expectedRevision skips the
+// revision check, otherwise the update fails unless the asset is still at that revision.
+func (t *SmartContract) UpdateAssetLocation(ctx contractapi.TransactionContextInterface, assetID string, madein string, reason string, expectedRevision int) error {
+	oldAsset, err := t.beginAssetUpdate(ctx, assetID, reason, expectedRevision)
+	if err != nil {
+		return err
+	}
+	asset := *oldAsset
+	asset.MadeIn = madein
+
+	return commitAssetUpdate(ctx, oldAsset, &asset, reason, EventAssetUpdated)
+}
+
+// UpdateAssetSerialNumber changes the serial number of an asset. The serial number must not be
+// used by another asset of the same brand.
+func (t *SmartContract) UpdateAssetSerialNumber(ctx contractapi.TransactionContextInterface, assetID string, serialnumber string, reason string, expectedRevision int) error {
+	oldAsset, err := t.beginAssetUpdate(ctx, assetID, reason, expectedRevision)
+	if err != nil {
+		return err
+	}
+	asset := *oldAsset
+	asset.SerialNumber = serialnumber
+
+	return commitAssetUpdate(ctx, oldAsset, &asset, reason, EventAssetUpdated)
+}
+
+// ReplaceAssetPart installs newPartID in the slot of oldPartID. The replaced part is marked as
+// removed; the other components of the asset are left untouched.
+func (t *SmartContract) ReplaceAssetPart(ctx contractapi.TransactionContextInterface, assetID string, oldPartID string, newPartID string, reason string, expectedRevision int) error {
+	oldAsset, err := t.beginAssetUpdate(ctx, assetID, reason, expectedRevision)
+	if err != nil {
+		return err
+	}
+	items, _, err := replacePartItems(oldAsset, oldPartID, newPartID)
+	if err != nil {
+		return err
+	}
+	asset := *oldAsset
+	err = t.replaceComponents(ctx, oldAsset, &asset, items, PartStatusRemoved, "")
+	if err != nil {
+		return err
+	}
+
+	return commitAssetUpdate(ctx, oldAsset, &asset, reason, EventAssetUpdated)
+}
+
+// beginAssetUpdate checks that the caller may change the asset, that reason is a known reason code
+// and that the asset is active and at expectedRevision, and returns the current asset
+func (t *SmartContract) beginAssetUpdate(ctx contractapi.TransactionContextInterface, assetID string, reason string, expectedRevision int) (*Asset, error) {
+	err := requireOrganization(ctx, BrandOrg)
+	if err != nil {
+		return nil, err
+	}
+	if !assetUpdateReasons[reason] {
+		return nil, invalidArgument("reason", "unknown reason code %q", reason)
+	}
+	asset, err := t.ReadAsset(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if asset.Status == AssetStatusDecommissioned {
+		return nil, conflict("asset %s is decommissioned and cannot be changed", assetID)
+	}
+	if expectedRevision >= 0 && asset.Revision != expectedRevision {
+		return nil, conflict("asset %s is at revision %d, expected revision %d", assetID, asset.Revision, expectedRevision)
+	}
+
+	return asset, nil
+}
+
+// replacePartItems returns the bill of materials of an asset with newPartID in place of oldPartID,
+// together with the slot of the replaced part. A part cannot replace itself.
+func replacePartItems(asset *Asset, oldPartID string, newPartID string) ([]BOMItem, string, error) {
+	if oldPartID == newPartID {
+		return nil, "", invalidArgument("newPartID", "part %s cannot replace itself", newPartID)
+	}
+	items := make([]BOMItem, 0, len(asset.Components))
+	slot := ""
+	for _, component := range asset.Components {
+		partID := component.Part.PID
+		if partID == oldPartID {
+			partID = newPartID
+			slot = component.Slot
+		}
+		items = append(items, BOMItem{Slot: component.Slot, PartID: partID})
+	}
+	if slot == "" {
+		return nil, "", invalidArgument("oldPartID", "part %s is not installed in asset %s", oldPartID, asset.ID)
+	}
+
+	return items, slot, nil
+}
+
+// replaceComponents checks the bill of materials items against the product model of the asset,
+// releases the parts of oldAsset that are no longer listed with releaseStatus and installs the
+// listed parts. A non-empty repairOrderID is recorded on the released and newly installed parts.
+func (t *SmartContract) replaceComponents(ctx contractapi.TransactionContextInterface, oldAsset *Asset, asset *Asset, items []BOMItem, releaseStatus string, repairOrderID string) error {
+	model, err := t.getAssetModel(ctx, oldAsset.ModelID)
+	if err != nil {
+		return err
+	}
+	// Get the Part instances from the ledger state and check them against the bill of materials
+	installed, err := t.resolveComponents(ctx, model, items)
+	if err != nil {
+		return err
+	}
+	// Ensure all parts belong to 'Brand-Org'
+	parts := componentParts(installed)
+	for _, part := range parts {
+		if part.Organization != BrandOrg {
+			return conflict("part %s does not belong to Brand-Org, failed to update asset %s", part.PID, asset.ID)
+		}
+	}
+	// Parts already installed in this asset may stay, any other part must be free
+	err = checkPartsInstallable(asset.ID, parts)
+	if err != nil {
+		return err
+	}
+	// Release the parts that are being replaced
+	keep := make(map[string]bool)
+	for _, part := range parts {
+		keep[part.PID] = true
+	}
+	installedBefore := make(map[string]bool)
+	for _, oldPart := range assetParts(oldAsset) {
+		installedBefore[oldPart.PID] = true
+		if keep[oldPart.PID] {
+			continue
+		}
+		err = releasePart(ctx, asset.ID, oldPart.PID, releaseStatus, repairOrderID)
+		if err != nil {
+			return err
+		}
+	}
+	if repairOrderID != "" {
+		for _, part := range parts {
+			if !installedBefore[part.PID] {
+				part.RepairOrderID = repairOrderID
+			}
+		}
+	}
+	err = installParts(ctx, asset.ID, parts)
+	if err != nil {
+		return err
+	}
+	asset.Components = installed
+
+	return nil
+}
+
+// commitAssetUpdate validates asset and writes it as the next revision of oldAsset, recording
+// the reason and the calling identity, and emits the event named eventName. The production
+// date and product model are never changed.
+func commitAssetUpdate(ctx contractapi.TransactionContextInterface, oldAsset *Asset, asset *Asset, reason string, eventName string) error {
+	asset.ProductionDate = oldAsset.ProductionDate
+	asset.ModelID = oldAsset.ModelID
+	err := validate(asset)
+	if err != nil {
+		return err
+	}
+	actor, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	asset.Revision = oldAsset.Revision + 1
+	asset.Updated = now
+	asset.UpdatedBy = actor
+	asset.UpdateReason = reason
+
+	assetBytes, err := json.Marshal(asset)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(assetKey(asset.ID), assetBytes)
+	if err != nil {
+		return internalError("failed to update asset %s: %v", asset.ID, err)
+	}
+	// Move the index entry when the brand or serial number changes
+	err = delAssetIndex(ctx, oldAsset)
+	if err != nil {
+		return err
+	}
+	err = putAssetIndex(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, &ContractEvent{
+		Name:       eventName,
+		ObjectType: "asset",
+		ObjectID:   asset.ID,
+		PartIDs:    partIDs(assetParts(asset)),
+		OldOwner:   oldAsset.MadeBy,
+		NewOwner:   asset.MadeBy,
+		Reason:     reason,
+	})
+}
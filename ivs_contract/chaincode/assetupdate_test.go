@@ -0,0 +1,125 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestAssetPatchUpdates(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA0002")
+	moveToBrand(t, l, networkMSP, "IVSLAB-N23FA0002")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	created := readAsset(t, l, "IVSLAB-PVC23FG0001")
+	if created.Revision != 1 {
+		t.Fatalf("expected a new asset at revision 1, got %d", created.Revision)
+	}
+
+	err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAssetLocation(ctx, "IVSLAB-PVC23FG0001", "Vietnam", ReasonRelocation, 1)
+	})
+	if err != nil {
+		t.Fatalf("UpdateAssetLocation failed: %v", err)
+	}
+	if event := expectEvent(t, l, EventAssetUpdated, "IVSLAB-PVC23FG0001"); event.Reason != ReasonRelocation {
+		t.Fatalf("expected the reason in the event, got %+v", event)
+	}
+	asset := readAsset(t, l, "IVSLAB-PVC23FG0001")
+	if asset.MadeIn != "Vietnam" || asset.Revision != 2 || asset.UpdateReason != ReasonRelocation {
+		t.Fatalf("unexpected asset %+v", asset)
+	}
+	if asset.ProductionDate != created.ProductionDate || asset.Updated == created.ProductionDate {
+		t.Fatalf("expected the production date to be kept, got %s (updated %s)", asset.ProductionDate, asset.Updated)
+	}
+	if !strings.HasPrefix(asset.UpdatedBy, BrandOrg+"/") {
+		t.Fatalf("expected the acting identity, got %q", asset.UpdatedBy)
+	}
+
+	// a client holding an old revision must not overwrite the newer one
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAssetLocation(ctx, "IVSLAB-PVC23FG0001", "Taiwan", ReasonRelocation, 1)
+	})
+	expectErrorCode(t, err, ErrCodeConflict)
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAssetLocation(ctx, "IVSLAB-PVC23FG0001", "Taiwan", "MOVED", -1)
+	})
+	if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "reason" {
+		t.Fatalf("unexpected error payload %v", payload)
+	}
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAssetLocation(ctx, "IVSLAB-PVC23FG0001", "Taiwan", ReasonRelocation, -1)
+	})
+	expectErrorCode(t, err, ErrCodeForbidden)
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAssetSerialNumber(ctx, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC09", ReasonSerialChange, -1)
+	})
+	if err != nil {
+		t.Fatalf("UpdateAssetSerialNumber failed: %v", err)
+	}
+	if asset, err := readAssetBySerialNumber(l, "Brand.Co", "IVSPN902300AACDC09"); err != nil || asset.Revision != 3 || asset.MadeIn != "Vietnam" {
+		t.Fatalf("ReadAssetBySerialNumber returned %+v, %v", asset, err)
+	}
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAssetSerialNumber(ctx, "IVSLAB-PVC23FG0001", "SN-1", ReasonSerialChange, -1)
+	})
+	expectErrorCode(t, err, ErrCodeInvalidArgument)
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.ReplaceAssetPart(ctx, "IVSLAB-PVC23FG0001", "IVSLAB-S23FA0001", "IVSLAB-S23FA0002", ReasonPartReplacement, 3)
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAssetPart failed: %v", err)
+	}
+	asset = readAsset(t, l, "IVSLAB-PVC23FG0001")
+	if component(t, asset, SlotSecurityChip).PID != "IVSLAB-S23FA0002" || component(t, asset, SlotNetworkChip).PID != "IVSLAB-N23FA0001" || asset.Revision != 4 {
+		t.Fatalf("unexpected asset %+v", asset)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0001"); part.Status != PartStatusRemoved || part.AssetID != "" {
+		t.Fatalf("expected replaced part to be removed, got %+v", part)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0002"); part.Status != PartStatusInstalled || part.AssetID != "IVSLAB-PVC23FG0001" {
+		t.Fatalf("expected new part to be installed, got %+v", part)
+	}
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.ReplaceAssetPart(ctx, "IVSLAB-PVC23FG0001", "IVSLAB-S23FA0001", "IVSLAB-S23FA0003", ReasonPartReplacement, -1)
+	})
+	if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "oldPartID" {
+		t.Fatalf("unexpected error payload %v", payload)
+	}
+	// replacing a part with itself would only bump the revision
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.ReplaceAssetPart(ctx, "IVSLAB-PVC23FG0001", "IVSLAB-S23FA0002", "IVSLAB-S23FA0002", ReasonPartReplacement, -1)
+	})
+	if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "newPartID" {
+		t.Fatalf("unexpected error payload %v", payload)
+	}
+	if asset := readAsset(t, l, "IVSLAB-PVC23FG0001"); asset.Revision != 4 {
+		t.Fatalf("expected the asset to stay at revision 4, got %+v", asset)
+	}
+	// the network chip does not fit the security chip slot
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.ReplaceAssetPart(ctx, "IVSLAB-PVC23FG0001", "IVSLAB-S23FA0002", "IVSLAB-N23FA0002", ReasonPartReplacement, -1)
+	})
+	expectError(t, err, "accepts")
+
+	// the full update keeps the production date and is recorded as a correction
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FG0001", "Brand.Co", "Taiwan", "IVSPN902300AACDC01",
+			chipSet("IVSLAB-S23FA0002", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001"))
+	})
+	if err != nil {
+		t.Fatalf("UpdateAsset failed: %v", err)
+	}
+	asset = readAsset(t, l, "IVSLAB-PVC23FG0001")
+	if asset.ProductionDate != created.ProductionDate || asset.UpdateReason != ReasonCorrection || asset.Revision != 5 || asset.ModelID != DefaultProductModelID {
+		t.Fatalf("unexpected asset %+v", asset)
+	}
+}
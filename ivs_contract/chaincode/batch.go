@@ -0,0 +1,173 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxBatchSize bounds the number of parts registered by one transaction so that its
+// read-write set stays well below the block size
+const maxBatchSize = 500
+
+// States of an item of a part batch
+const (
+	BatchItemCreated  = "created"
+	BatchItemValid    = "valid"
+	BatchItemRejected = "rejected"
+)
+
+// PartDefinition describes a part to be registered by CreatePartsBatch
+type PartDefinition struct {
+	PID                 string `json:"PID"`                                  // 零件唯ID
+	Manufacturer        string `json:"Manufacturer"`                         // 製造商
+	ManufactureLocation string `json:"ManufactureLocation"`                  // 製造地點
+	PartName            string `json:"PartName"`                             // 零件名稱
+	PartNumber          string `json:"PartNumber"`                           // 零件批號
+	Organization        string `json:"Organization"`                         // 組織
+	LotID               string `json:"LotID,omitempty" metadata:",optional"` // 生產批次ID
+}
+
+// PartBatchResult is the outcome of one item of a part batch. Rejected items carry the
+// code, field and message of the error that rejected them.
+type PartBatchResult struct {
+	Index   int    `json:"index"`                                  // 項目於批次中之位置
+	PID     string `json:"PID"`                                    // 零件唯ID
+	Status  string `json:"status"`                                 // created/valid/rejected
+	Code    string `json:"code,omitempty" metadata:",optional"`    // 錯誤代碼
+	Field   string `json:"field,omitempty" metadata:",optional"`   // 錯誤欄位
+	Message string `json:"message,omitempty" metadata:",optional"` // 錯誤訊息
+}
+
+// CreatePartsBatch registers a batch of parts of the calling chip maker. Every item is checked
+// before anything is written: if one item is rejected no part is created, and the returned
+// error carries the result of every item in its details.
+func (t *SmartContract) CreatePartsBatch(ctx contractapi.TransactionContextInterface, definitions []PartDefinition) ([]*PartBatchResult, error) {
+	if len(definitions) == 0 || len(definitions) > maxBatchSize {
+		return nil, invalidArgument("parts", "a batch must contain between 1 and %d parts, got %d", maxBatchSize, len(definitions))
+	}
+	parts := make([]*Part, len(definitions))
+	for i, definition := range definitions {
+		parts[i] = newPart(definition.PID, definition.Manufacturer, definition.ManufactureLocation, definition.PartName, definition.PartNumber, definition.Organization)
+		parts[i].LotID = definition.LotID
+	}
+
+	results, err := t.createParts(ctx, parts, func(part *Part) error {
+		return requireChipMaker(ctx, part.Organization)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, emitEvent(ctx, &ContractEvent{
+		Name:       EventPartsCreated,
+		ObjectType: "part",
+		PartIDs:    partIDs(parts),
+		NewOwner:   parts[0].Organization,
+	})
+}
+
+// createParts checks every part and, unless one of them is rejected, writes all of them with
+// their index entries. authorize, if not nil, checks that the caller may create a part.
+func (t *SmartContract) createParts(ctx contractapi.TransactionContextInterface, parts []*Part, authorize func(part *Part) error) ([]*PartBatchResult, error) {
+	results := make([]*PartBatchResult, len(parts))
+	rejected := 0
+	var firstErr error
+	seen := make(map[string]int)
+	lots := make(map[string]*lotUsage)
+	for i, part := range parts {
+		results[i] = &PartBatchResult{Index: i, PID: part.PID, Status: BatchItemValid}
+		err := t.checkNewPart(ctx, part, authorize)
+		if err == nil {
+			// the world state does not reflect the writes of this transaction yet
+			if first, ok := seen[part.PID]; ok {
+				err = alreadyExists("the part %s is listed more than once, first at index %d", part.PID, first)
+			} else {
+				seen[part.PID] = i
+			}
+		}
+		if err == nil {
+			err = t.checkPartLot(ctx, part, lots)
+		}
+		if err == nil {
+			continue
+		}
+		code, field, message, ok := errorFields(err)
+		if !ok {
+			return nil, err
+		}
+		results[i].Status = BatchItemRejected
+		results[i].Code = code
+		results[i].Field = field
+		results[i].Message = message
+		if firstErr == nil {
+			firstErr = err
+		}
+		rejected++
+	}
+	if rejected > 0 {
+		code, _, _, _ := errorFields(firstErr)
+		return nil, &ContractError{
+			Code:    code,
+			Field:   "parts",
+			Message: fmt.Sprintf("%d of %d parts were rejected, no part was created", rejected, len(parts)),
+			Details: results,
+		}
+	}
+
+	for i, part := range parts {
+		err := t.createPart(ctx, part)
+		if err != nil {
+			return nil, err
+		}
+		results[i].Status = BatchItemCreated
+	}
+
+	return results, nil
+}
+
+// checkNewPart returns an error unless part is valid, may be created by the caller and does not exist yet
+func (t *SmartContract) checkNewPart(ctx contractapi.TransactionContextInterface, part *Part, authorize func(part *Part) error) error {
+	err := validate(part)
+	if err != nil {
+		return err
+	}
+	if authorize != nil {
+		err = authorize(part)
+		if err != nil {
+			return err
+		}
+	}
+	err = checkPartCategory(part)
+	if err != nil {
+		return err
+	}
+	exists, err := t.PartExists(ctx, part.PID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return alreadyExists("the part %s already exists", part.PID)
+	}
+
+	return nil
+}
+
+// errorFields returns the code, field and message of a structured error. It reports false
+// for any other error, e.g. a failure to read the world state.
+func errorFields(err error) (string, string, string, bool) {
+	var contractErr *ContractError
+	if errors.As(err, &contractErr) {
+		if contractErr.Code == ErrCodeInternal {
+			return "", "", "", false
+		}
+		return contractErr.Code, contractErr.Field, contractErr.Message, true
+	}
+	var denied *AccessDeniedError
+	if errors.As(err, &denied) {
+		return denied.Code, "", denied.Message, true
+	}
+
+	return "", "", "", false
+}
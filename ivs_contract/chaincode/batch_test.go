@@ -0,0 +1,149 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// networkPart returns the definition of a Network-Org part
+func networkPart(partID string, partNumber string) PartDefinition {
+	return PartDefinition{PID: partID, Manufacturer: "Network.Co", ManufactureLocation: "Taiwan", PartName: "NetworkChip-v1", PartNumber: partNumber, Organization: NetworkOrg}
+}
+
+// batchError decodes the error of a rejected batch together with its per-item results
+func batchError(t *testing.T, err error) (*ContractError, []*PartBatchResult) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected the batch to be rejected")
+	}
+	var payload struct {
+		ContractError
+		Details []*PartBatchResult `json:"details"`
+	}
+	if jsonErr := json.Unmarshal([]byte(err.Error()), &payload); jsonErr != nil {
+		t.Fatalf("expected a JSON error message, got %q", err.Error())
+	}
+	return &payload.ContractError, payload.Details
+}
+
+func TestCreatePartsBatch(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	batch := []PartDefinition{
+		networkPart("IVSLAB-N23FA0004", "NPN3R1C00AA4"),
+		networkPart("IVSLAB-N23FA0005", "NPN3R1C00AA5"),
+		networkPart("IVSLAB-N23FA0006", "NPN3R1C00AA6"),
+	}
+	var results []*PartBatchResult
+	err := l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		results, err = l.contract.CreatePartsBatch(ctx, batch)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("CreatePartsBatch failed: %v", err)
+	}
+	for i, result := range results {
+		if result.Index != i || result.PID != batch[i].PID || result.Status != BatchItemCreated {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+	event := expectEvent(t, l, EventPartsCreated, "")
+	if len(event.PartIDs) != 3 || event.NewOwner != NetworkOrg {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	part := readPart(t, l, "IVSLAB-N23FA0005")
+	if part.Organization != NetworkOrg || part.Status != PartStatusAvailable || part.ManufactureDate == "" {
+		t.Fatalf("unexpected part %+v", part)
+	}
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		parts, err := l.contract.GetPartsByOrganization(ctx, NetworkOrg)
+		if err != nil {
+			return err
+		}
+		if len(parts) != 6 {
+			t.Fatalf("expected the index entries of the batch, got %v", partIDs(parts))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreatePartsBatchIsAtomic(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	invalid := networkPart("IVSLAB-N23FA0005", "NPN3R1C00AA5")
+	invalid.PartName = ""
+	batch := []PartDefinition{
+		networkPart("IVSLAB-N23FA0004", "NPN3R1C00AA4"),
+		invalid,
+		networkPart("IVSLAB-N23FA0001", "NPN3R1C00AA1"),
+		networkPart("IVSLAB-N23FA0004", "NPN3R1C00AA4"),
+		{PID: "IVSLAB-S23FA0004", Manufacturer: "Security.Co", ManufactureLocation: "Taiwan", PartName: "SecurityChip-v1", PartNumber: "SPN3R1C00AA4", Organization: SecurityOrg},
+	}
+	err := l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.CreatePartsBatch(ctx, batch)
+		return err
+	})
+	contractErr, results := batchError(t, err)
+	if contractErr.Code != ErrCodeInvalidArgument || contractErr.Field != "parts" {
+		t.Fatalf("expected the code of the first rejected item, got %+v", contractErr)
+	}
+	want := []struct {
+		status string
+		code   string
+		field  string
+	}{
+		{BatchItemValid, "", ""},
+		{BatchItemRejected, ErrCodeInvalidArgument, "PartName"},
+		{BatchItemRejected, ErrCodeAlreadyExists, ""},
+		{BatchItemRejected, ErrCodeAlreadyExists, ""},
+		{BatchItemRejected, ErrCodeForbidden, ""},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, w := range want {
+		if results[i].Index != i || results[i].Status != w.status || results[i].Code != w.code || results[i].Field != w.field {
+			t.Fatalf("unexpected result %d: %+v", i, results[i])
+		}
+	}
+
+	// nothing was written
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		exists, err := l.contract.PartExists(ctx, "IVSLAB-N23FA0004")
+		if exists {
+			t.Fatalf("the valid item of a rejected batch was created")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.CreatePartsBatch(ctx, batch[:1])
+		return err
+	})
+	_, results = batchError(t, err)
+	if results[0].Code != ErrCodeForbidden {
+		t.Fatalf("expected Brand-Org to be rejected, got %+v", results[0])
+	}
+
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.CreatePartsBatch(ctx, nil)
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeInvalidArgument)
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.CreatePartsBatch(ctx, make([]PartDefinition, maxBatchSize+1))
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeInvalidArgument)
+}
@@ -0,0 +1,276 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const modelKeyPrefix = "model_"
+
+// Slots of the legacy four-chip assets, which were stored with one field per chip
+const (
+	SlotSecurityChip   = "SecurityChip"
+	SlotNetworkChip    = "NetworkChip"
+	SlotCMOSChip       = "CMOSChip"
+	SlotVideoCodecChip = "VideoCodecChip"
+)
+
+// ProductModel 產品型號, with the bill of materials every asset of the model must satisfy.
+type ProductModel struct {
+	DocType string    `json:"docType"`                                         // DocType is used to distinguish the various types of objects in state database
+	ModelID string    `json:"ModelID" validate:"required,max=64,pattern=code"` // 型號唯一ID
+	Name    string    `json:"Name" validate:"max=128"`                         // 型號名稱
+	Slots   []BOMSlot `json:"Slots"`                                           // 物料清單
+	Created string    `json:"Created"`                                         // 建立日期
+}
+
+// BOMSlot 物料清單料位. A slot with MinCount 0 is optional; PartTypes lists the PartName values
+// that may be installed in the slot, an empty list allows any part.
+type BOMSlot struct {
+	Slot      string   `json:"Slot" validate:"required,max=64"`          // 料位名稱
+	PartTypes []string `json:"PartTypes,omitempty" metadata:",optional"` // 允許之零件名稱
+	MinCount  int      `json:"MinCount"`                                 // 最少數量
+	MaxCount  int      `json:"MaxCount"`                                 // 最多數量
+}
+
+// BOMItem assigns a part to a slot of a product model when creating or updating an asset
+type BOMItem struct {
+	Slot   string `json:"Slot"`
+	PartID string `json:"PartID"`
+}
+
+// Component 產品組件, a part installed in a slot of an asset.
+type Component struct {
+	Slot string `json:"Slot"` // 料位名稱
+	Part Part   `json:"Part"` // 零件
+}
+
+// DefaultProductModelID is the product model registered by InitLedger
+const DefaultProductModelID = "IVSLAB-PVC-V1"
+
+// defaultProductModelSlots is the bill of materials of the default product model
+var defaultProductModelSlots = []BOMSlot{
+	{Slot: SlotSecurityChip, PartTypes: []string{"SecurityChip-v1"}, MinCount: 1, MaxCount: 1},
+	{Slot: SlotNetworkChip, PartTypes: []string{"NetworkChip-v1"}, MinCount: 1, MaxCount: 1},
+	{Slot: SlotCMOSChip, PartTypes: []string{"CMOSChip-v1"}, MinCount: 1, MaxCount: 1},
+	{Slot: SlotVideoCodecChip, PartTypes: []string{"VideoCodecChip-v1"}, MinCount: 1, MaxCount: 1},
+}
+
+// legacyProductModel is the bill of materials of assets created before product models existed.
+// It is not stored on the ledger and applies to assets without a ModelID.
+var legacyProductModel = &ProductModel{
+	DocType: "productModel",
+	Name:    "Legacy four-chip camera",
+	Slots: []BOMSlot{
+		{Slot: SlotSecurityChip, MinCount: 1, MaxCount: 1},
+		{Slot: SlotNetworkChip, MinCount: 1, MaxCount: 1},
+		{Slot: SlotCMOSChip, MinCount: 1, MaxCount: 1},
+		{Slot: SlotVideoCodecChip, MinCount: 1, MaxCount: 1},
+	},
+}
+
+// CreateProductModel registers a product model and its bill of materials. Only the brand may call it.
+func (t *SmartContract) CreateProductModel(ctx contractapi.TransactionContextInterface, modelID string, name string, slots []BOMSlot) error {
+	err := requireOrganization(ctx, BrandOrg)
+	if err != nil {
+		return err
+	}
+	err = t.createProductModel(ctx, modelID, name, slots)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, &ContractEvent{
+		Name:       EventProductModelCreated,
+		ObjectType: "productModel",
+		ObjectID:   modelID,
+	})
+}
+
+// createProductModel validates and writes a product model without checking the caller
+func (t *SmartContract) createProductModel(ctx contractapi.TransactionContextInterface, modelID string, name string, slots []BOMSlot) error {
+	model := &ProductModel{
+		DocType: "productModel",
+		ModelID: modelID,
+		Name:    name,
+		Slots:   slots,
+	}
+	err := validate(model)
+	if err != nil {
+		return err
+	}
+	if len(slots) == 0 {
+		return invalidArgument("Slots", "product model %s has no slots", modelID)
+	}
+	seen := make(map[string]bool)
+	for _, slot := range slots {
+		err = validate(slot)
+		if err != nil {
+			return err
+		}
+		if seen[slot.Slot] {
+			return invalidArgument("Slots", "product model %s defines slot %s more than once", modelID, slot.Slot)
+		}
+		seen[slot.Slot] = true
+		if slot.MinCount < 0 || slot.MaxCount < 1 || slot.MaxCount < slot.MinCount {
+			return invalidArgument("Slots", "slot %s of product model %s has invalid counts %d..%d", slot.Slot, modelID, slot.MinCount, slot.MaxCount)
+		}
+	}
+
+	existing, err := ctx.GetStub().GetState(modelKey(modelID))
+	if err != nil {
+		return internalError("failed to read product model %s: %v", modelID, err)
+	}
+	if existing != nil {
+		return alreadyExists("the product model %s already exists", modelID)
+	}
+
+	model.Created, err = getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	modelBytes, err := json.Marshal(model)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(modelKey(modelID), modelBytes)
+}
+
+// ReadProductModel retrieves a product model from the ledger
+func (t *SmartContract) ReadProductModel(ctx contractapi.TransactionContextInterface, modelID string) (*ProductModel, error) {
+	modelBytes, err := ctx.GetStub().GetState(modelKey(modelID))
+	if err != nil {
+		return nil, internalError("failed to get product model %s: %v", modelID, err)
+	}
+	if modelBytes == nil {
+		return nil, notFound("product model %s does not exist", modelID)
+	}
+
+	var model ProductModel
+	err = json.Unmarshal(modelBytes, &model)
+	if err != nil {
+		return nil, internalError("failed to unmarshal product model %s: %v", modelID, err)
+	}
+
+	return &model, nil
+}
+
+// GetAllProductModels returns all product models found in world state
+func (t *SmartContract) GetAllProductModels(ctx contractapi.TransactionContextInterface) ([]*ProductModel, error) {
+	startKey, endKey := keyRange(modelKeyPrefix, "", "")
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, internalError("failed to read product models: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	models := []*ProductModel{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError("failed to read product models: %v", err)
+		}
+		var model ProductModel
+		err = json.Unmarshal(queryResult.Value, &model)
+		if err != nil {
+			return nil, internalError("failed to unmarshal %s: %v", queryResult.Key, err)
+		}
+		models = append(models, &model)
+	}
+
+	return models, nil
+}
+
+// getAssetModel returns the product model of an asset, the legacy model for assets without one
+func (t *SmartContract) getAssetModel(ctx contractapi.TransactionContextInterface, modelID string) (*ProductModel, error) {
+	if modelID == "" {
+		return legacyProductModel, nil
+	}
+	return t.ReadProductModel(ctx, modelID)
+}
+
+// resolveComponents reads the parts of a bill of materials and checks them against the model:
+// every slot must exist, every part must be of an allowed type, and slot counts must be respected.
+func (t *SmartContract) resolveComponents(ctx contractapi.TransactionContextInterface, model *ProductModel, items []BOMItem) ([]Component, error) {
+	slots := make(map[string]BOMSlot)
+	for _, slot := range model.Slots {
+		slots[slot.Slot] = slot
+	}
+
+	counts := make(map[string]int)
+	seen := make(map[string]bool)
+	components := make([]Component, 0, len(items))
+	for _, item := range items {
+		if seen[item.PartID] {
+			return nil, invalidArgument("components", "part %s is listed more than once in the bill of materials", item.PartID)
+		}
+		seen[item.PartID] = true
+		slot, ok := slots[item.Slot]
+		if !ok {
+			return nil, invalidArgument("components", "product model %s has no slot %s", model.ModelID, item.Slot)
+		}
+		part, err := t.GetPart(ctx, item.PartID)
+		if err != nil {
+			return nil, err
+		}
+		if len(slot.PartTypes) > 0 && !containsString(slot.PartTypes, part.PartName) {
+			return nil, invalidArgument("components", "part %s is a %s, slot %s accepts %v", part.PID, part.PartName, slot.Slot, slot.PartTypes)
+		}
+		counts[item.Slot]++
+		components = append(components, Component{Slot: item.Slot, Part: *part})
+	}
+
+	for _, slot := range model.Slots {
+		if counts[slot.Slot] < slot.MinCount {
+			return nil, invalidArgument("components", "slot %s requires at least %d parts, got %d", slot.Slot, slot.MinCount, counts[slot.Slot])
+		}
+		if counts[slot.Slot] > slot.MaxCount {
+			return nil, invalidArgument("components", "slot %s allows at most %d parts, got %d", slot.Slot, slot.MaxCount, counts[slot.Slot])
+		}
+	}
+
+	return components, nil
+}
+
+// normalizeAsset moves the chips of a legacy four-chip asset into its Components
+func normalizeAsset(asset *Asset) {
+	legacy := []struct {
+		slot string
+		part *Part
+	}{
+		{SlotSecurityChip, asset.SecurityChip},
+		{SlotNetworkChip, asset.NetworkChip},
+		{SlotCMOSChip, asset.CMOSChip},
+		{SlotVideoCodecChip, asset.VideoCodecChip},
+	}
+	for _, chip := range legacy {
+		if chip.part != nil && chip.part.PID != "" {
+			asset.Components = append(asset.Components, Component{Slot: chip.slot, Part: *chip.part})
+		}
+	}
+	asset.SecurityChip = nil
+	asset.NetworkChip = nil
+	asset.CMOSChip = nil
+	asset.VideoCodecChip = nil
+	// the contract schema requires a list, also for assets without parts
+	if asset.Components == nil {
+		asset.Components = []Component{}
+	}
+}
+
+// modelKey returns the world state key of a product model
+func modelKey(modelID string) string {
+	return modelKeyPrefix + modelID
+}
+
+// containsString returns true when value is an element of values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
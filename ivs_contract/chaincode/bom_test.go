@@ -0,0 +1,226 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// createProductModel registers a product model as the brand
+func createProductModel(l *testLedger, modelID string, slots []BOMSlot) error {
+	return l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CreateProductModel(ctx, modelID, modelID, slots)
+	})
+}
+
+func TestCreateProductModel(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	var models []*ProductModel
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		models, err = l.contract.GetAllProductModels(ctx)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("GetAllProductModels failed: %v", err)
+	}
+	if len(models) != 1 || models[0].ModelID != DefaultProductModelID || len(models[0].Slots) != 4 {
+		t.Fatalf("expected the default product model, got %+v", models)
+	}
+
+	slots := []BOMSlot{
+		{Slot: SlotSecurityChip, PartTypes: []string{"SecurityChip-v1"}, MinCount: 1, MaxCount: 1},
+		{Slot: "ImageSensor", PartTypes: []string{"CMOSChip-v1"}, MinCount: 0, MaxCount: 2},
+	}
+	if err := createProductModel(l, "IVSLAB-PVC-LITE", slots); err != nil {
+		t.Fatalf("CreateProductModel failed: %v", err)
+	}
+	expectEvent(t, l, EventProductModelCreated, "IVSLAB-PVC-LITE")
+
+	var model *ProductModel
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		model, err = l.contract.ReadProductModel(ctx, "IVSLAB-PVC-LITE")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ReadProductModel failed: %v", err)
+	}
+	if model.DocType != "productModel" || len(model.Slots) != 2 || model.Created == "" {
+		t.Fatalf("unexpected product model %+v", model)
+	}
+
+	expectError(t, createProductModel(l, "IVSLAB-PVC-LITE", slots), "already exists")
+	expectError(t, createProductModel(l, "", slots), "must not be empty")
+	expectError(t, createProductModel(l, "IVSLAB-PVC-X1", nil), "has no slots")
+	expectError(t, createProductModel(l, "IVSLAB-PVC-X1", []BOMSlot{
+		{Slot: "Chip", MinCount: 1, MaxCount: 1},
+		{Slot: "Chip", MinCount: 1, MaxCount: 1},
+	}), "more than once")
+	expectError(t, createProductModel(l, "IVSLAB-PVC-X1", []BOMSlot{
+		{Slot: "Chip", MinCount: 2, MaxCount: 1},
+	}), "invalid counts")
+	expectError(t, createProductModel(l, "IVSLAB-PVC-X1", []BOMSlot{
+		{Slot: "Chip", MinCount: 0, MaxCount: 0},
+	}), "invalid counts")
+
+	err = l.submit(cmosMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CreateProductModel(ctx, "IVSLAB-PVC-X1", "X1", slots)
+	})
+	expectError(t, err, "FORBIDDEN")
+}
+
+func TestCreateAssetWithBillOfMaterials(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	moveSetToBrand(t, l, "0002")
+	moveToBrand(t, l, cmosMSP, "IVSLAB-C23FA0003")
+
+	err := createProductModel(l, "IVSLAB-PVC-LITE", []BOMSlot{
+		{Slot: SlotSecurityChip, PartTypes: []string{"SecurityChip-v1"}, MinCount: 1, MaxCount: 1},
+		{Slot: "ImageSensor", PartTypes: []string{"CMOSChip-v1"}, MinCount: 0, MaxCount: 2},
+	})
+	if err != nil {
+		t.Fatalf("CreateProductModel failed: %v", err)
+	}
+
+	createLite := func(assetID string, serialNumber string, items []BOMItem) error {
+		return l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreateAsset(ctx, assetID, "IVSLAB-PVC-LITE", "Brand.Co", "Taiwan", serialNumber, items)
+		})
+	}
+
+	// optional slots may stay empty
+	err = createLite("IVSLAB-PVC23FL0001", "IVSPN902300AACDL01", []BOMItem{{Slot: SlotSecurityChip, PartID: "IVSLAB-S23FA0001"}})
+	if err != nil {
+		t.Fatalf("CreateAsset without optional slot failed: %v", err)
+	}
+	asset := readAsset(t, l, "IVSLAB-PVC23FL0001")
+	if asset.ModelID != "IVSLAB-PVC-LITE" || len(asset.Components) != 1 {
+		t.Fatalf("unexpected asset %+v", asset)
+	}
+
+	// a slot may hold several parts up to its maximum
+	err = createLite("IVSLAB-PVC23FL0002", "IVSPN902300AACDL02", []BOMItem{
+		{Slot: SlotSecurityChip, PartID: "IVSLAB-S23FA0002"},
+		{Slot: "ImageSensor", PartID: "IVSLAB-C23FA0001"},
+		{Slot: "ImageSensor", PartID: "IVSLAB-C23FA0002"},
+	})
+	if err != nil {
+		t.Fatalf("CreateAsset with two image sensors failed: %v", err)
+	}
+	event := expectEvent(t, l, EventAssetCreated, "IVSLAB-PVC23FL0002")
+	if len(event.PartIDs) != 3 {
+		t.Fatalf("expected 3 parts in the event, got %v", event.PartIDs)
+	}
+	if part := readPart(t, l, "IVSLAB-C23FA0002"); part.Status != PartStatusInstalled || part.AssetID != "IVSLAB-PVC23FL0002" {
+		t.Fatalf("expected part to be installed, got %+v", part)
+	}
+
+	tests := []struct {
+		name    string
+		modelID string
+		items   []BOMItem
+		want    string
+	}{
+		{"unknown model", "IVSLAB-PVC-NONE", chipSet("IVSLAB-S23FA0001", "IVSLAB-N23FA0001", "IVSLAB-C23FA0003", "IVSLAB-V23FA0001"), "does not exist"},
+		{"unknown slot", DefaultProductModelID, append(chipSet("IVSLAB-S23FA0001", "IVSLAB-N23FA0001", "IVSLAB-C23FA0003", "IVSLAB-V23FA0001"),
+			BOMItem{Slot: "Speaker", PartID: "IVSLAB-N23FA0002"}), "has no slot Speaker"},
+		{"wrong part type", DefaultProductModelID, chipSet("IVSLAB-S23FA0001", "IVSLAB-C23FA0003", "IVSLAB-N23FA0001", "IVSLAB-V23FA0001"), "slot NetworkChip accepts"},
+		{"missing required slot", DefaultProductModelID, chipSet("IVSLAB-S23FA0001", "IVSLAB-N23FA0001", "IVSLAB-C23FA0003", "IVSLAB-V23FA0001")[:3], "requires at least 1"},
+		{"too many parts", DefaultProductModelID, append(chipSet("IVSLAB-S23FA0001", "IVSLAB-N23FA0001", "IVSLAB-C23FA0003", "IVSLAB-V23FA0001"),
+			BOMItem{Slot: SlotNetworkChip, PartID: "IVSLAB-N23FA0002"}), "allows at most 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+				return l.contract.CreateAsset(ctx, "IVSLAB-PVC23FG0009", tt.modelID, "Brand.Co", "Taiwan", "IVSPN902300AACDC09", tt.items)
+			})
+			expectError(t, err, tt.want)
+		})
+	}
+
+	// the model of an asset does not change on update
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FL0001", "Brand.Co", "Taiwan", "IVSPN902300AACDL01",
+			chipSet("IVSLAB-S23FA0001", "IVSLAB-N23FA0001", "IVSLAB-C23FA0003", "IVSLAB-V23FA0001"))
+	})
+	expectError(t, err, "has no slot NetworkChip")
+}
+
+func TestLegacyAsset(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA0002")
+
+	// an asset written before product models existed, with one field per chip
+	legacy := map[string]interface{}{
+		"docType":        "asset",
+		"ID":             "IVSLAB-PVC23FG0001",
+		"MadeBy":         "Brand.Co",
+		"MadeIn":         "Taiwan",
+		"SerialNumber":   "IVSPN902300AACDC01",
+		"SecurityChip":   Part{DocType: "part", PID: "IVSLAB-S23FA0001", PartName: "SecurityChip-v1", Organization: BrandOrg},
+		"NetworkChip":    Part{DocType: "part", PID: "IVSLAB-N23FA0001", PartName: "NetworkChip-v1", Organization: BrandOrg},
+		"CMOSChip":       Part{DocType: "part", PID: "IVSLAB-C23FA0001", PartName: "CMOSChip-v1", Organization: BrandOrg},
+		"VideoCodecChip": Part{DocType: "part", PID: "IVSLAB-V23FA0001", PartName: "VideoCodecChip-v1", Organization: BrandOrg},
+		"ProductionDate": "2023-05-01T00:00:00Z",
+		"Updated":        "",
+	}
+	legacyBytes, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatalf("failed to marshal legacy asset: %v", err)
+	}
+	l.stub.state[assetKey("IVSLAB-PVC23FG0001")] = legacyBytes
+
+	asset := readAsset(t, l, "IVSLAB-PVC23FG0001")
+	if asset.ModelID != "" || len(asset.Components) != 4 || asset.SecurityChip != nil {
+		t.Fatalf("expected legacy chips to be read as components, got %+v", asset)
+	}
+	if component(t, asset, SlotVideoCodecChip).PID != "IVSLAB-V23FA0001" {
+		t.Fatalf("unexpected components %+v", asset.Components)
+	}
+
+	// legacy assets are updated against the built-in four-chip bill of materials
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FG0001", "Brand.Co", "Taiwan", "IVSPN902300AACDC01",
+			chipSet("IVSLAB-S23FA0002", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001"))
+	})
+	if err != nil {
+		t.Fatalf("UpdateAsset of legacy asset failed: %v", err)
+	}
+	asset = readAsset(t, l, "IVSLAB-PVC23FG0001")
+	if len(asset.Components) != 4 || component(t, asset, SlotSecurityChip).PID != "IVSLAB-S23FA0002" {
+		t.Fatalf("unexpected updated asset %+v", asset)
+	}
+
+	var stored map[string]json.RawMessage
+	if err := json.Unmarshal(l.stub.state[assetKey("IVSLAB-PVC23FG0001")], &stored); err != nil {
+		t.Fatalf("failed to unmarshal stored asset: %v", err)
+	}
+	if _, found := stored["SecurityChip"]; found {
+		t.Fatalf("expected the legacy chip fields to be dropped, got %s", l.stub.state[assetKey("IVSLAB-PVC23FG0001")])
+	}
+}
+
+func TestNormalizeAssetWithoutParts(t *testing.T) {
+	// the contract schema rejects a null list of components
+	var asset Asset
+	normalizeAsset(&asset)
+	assetBytes, err := json.Marshal(asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]json.RawMessage
+	if err := json.Unmarshal(assetBytes, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if string(stored["Components"]) != "[]" {
+		t.Fatalf("expected an empty list of components, got %s", stored["Components"])
+	}
+}
@@ -0,0 +1,208 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// The madeby~serialnumber index maps the brand and serial number of an asset to its ID, which is
+// stored as the value of the entry. Earlier versions wrote [MadeBy, ID] entries with a 0x00 value;
+// they are still understood when reading and are replaced by RebuildIndexes.
+
+// IndexRebuildResult reports the composite key changes made by RebuildIndexes
+type IndexRebuildResult struct {
+	Added      int      `json:"added"`                                     // 新增之索引
+	Updated    int      `json:"updated"`                                   // 修正之索引
+	Removed    int      `json:"removed"`                                   // 刪除之過期索引
+	Duplicates []string `json:"duplicates,omitempty" metadata:",optional"` // 序號與同品牌其他產品重複之產品ID
+}
+
+// ReadAssetBySerialNumber retrieves the asset with the given serial number of a brand
+func (t *SmartContract) ReadAssetBySerialNumber(ctx contractapi.TransactionContextInterface, madeby string, serialnumber string) (*Asset, error) {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(madeBySerialNumberIndex, []string{madeby, serialnumber})
+	if err != nil {
+		return nil, invalidArgument("serialNumber", "invalid brand or serial number: %v", err)
+	}
+	assetID, err := ctx.GetStub().GetState(indexKey)
+	if err != nil {
+		return nil, internalError("failed to get serial number %s: %v", serialnumber, err)
+	}
+	if assetID == nil {
+		return nil, notFound("%s has no asset with serial number %s", madeby, serialnumber)
+	}
+
+	return t.ReadAsset(ctx, string(assetID))
+}
+
+// RebuildIndexes reconciles the organization~partID, lotID~partID and madeby~serialnumber composite
+// keys with the parts and assets in the world state. Missing entries are added, stale entries removed.
+// When several assets of a brand share a serial number, the one with the lowest ID keeps the entry
+// and the others are reported as duplicates.
+func (t *SmartContract) RebuildIndexes(ctx contractapi.TransactionContextInterface) (*IndexRebuildResult, error) {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &IndexRebuildResult{}
+	wanted := make(map[string][]byte)
+	startKey, endKey := keyRange(partKeyPrefix, "", "")
+	err = forEachRecord(ctx, startKey, endKey, func(value []byte) error {
+		var part Part
+		if err := json.Unmarshal(value, &part); err != nil {
+			return err
+		}
+		indexKey, err := ctx.GetStub().CreateCompositeKey(manufacturerPartIndex, []string{part.Organization, part.PID})
+		if err != nil {
+			return err
+		}
+		wanted[indexKey] = []byte{0x00}
+		if part.LotID != "" {
+			lotIndexKey, err := ctx.GetStub().CreateCompositeKey(lotPartIndex, []string{part.LotID, part.PID})
+			if err != nil {
+				return err
+			}
+			wanted[lotIndexKey] = []byte{0x00}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	startKey, endKey = keyRange(assetKeyPrefix, "", "")
+	err = forEachRecord(ctx, startKey, endKey, func(value []byte) error {
+		var asset Asset
+		if err := json.Unmarshal(value, &asset); err != nil {
+			return err
+		}
+		indexKey, err := ctx.GetStub().CreateCompositeKey(madeBySerialNumberIndex, []string{asset.MadeBy, asset.SerialNumber})
+		if err != nil {
+			return err
+		}
+		if _, ok := wanted[indexKey]; ok {
+			result.Duplicates = append(result.Duplicates, asset.ID)
+			return nil
+		}
+		wanted[indexKey] = []byte(asset.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, index := range []string{manufacturerPartIndex, lotPartIndex, madeBySerialNumberIndex} {
+		resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{})
+		if err != nil {
+			return nil, err
+		}
+		for resultsIterator.HasNext() {
+			responseRange, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+			value, ok := wanted[responseRange.Key]
+			switch {
+			case !ok:
+				err = ctx.GetStub().DelState(responseRange.Key)
+				result.Removed++
+			case !bytes.Equal(value, responseRange.Value):
+				err = ctx.GetStub().PutState(responseRange.Key, value)
+				result.Updated++
+			}
+			if err != nil {
+				resultsIterator.Close()
+				return nil, internalError("failed to reconcile index entry %q: %v", responseRange.Key, err)
+			}
+			delete(wanted, responseRange.Key)
+		}
+		resultsIterator.Close()
+	}
+	for indexKey, value := range wanted {
+		err = ctx.GetStub().PutState(indexKey, value)
+		if err != nil {
+			return nil, internalError("failed to write index entry %q: %v", indexKey, err)
+		}
+		result.Added++
+	}
+
+	if result.Added+result.Updated+result.Removed > 0 {
+		err = emitEvent(ctx, &ContractEvent{
+			Name:       EventIndexesRebuilt,
+			ObjectType: "ledger",
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// forEachRecord calls fn with the value of every key in [startKey, endKey)
+func forEachRecord(ctx contractapi.TransactionContextInterface, startKey string, endKey string, fn func(value []byte) error) error {
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+		err = fn(queryResponse.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putAssetIndex writes the madeby~serialnumber entry of an asset. It fails when another asset
+// of the same brand already uses the serial number.
+func putAssetIndex(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(madeBySerialNumberIndex, []string{asset.MadeBy, asset.SerialNumber})
+	if err != nil {
+		return err
+	}
+	assetID, err := ctx.GetStub().GetState(indexKey)
+	if err != nil {
+		return internalError("failed to get serial number %s: %v", asset.SerialNumber, err)
+	}
+	if assetID != nil && string(assetID) != asset.ID {
+		return alreadyExists("serial number %s of %s is already used by asset %s", asset.SerialNumber, asset.MadeBy, assetID)
+	}
+
+	return ctx.GetStub().PutState(indexKey, []byte(asset.ID))
+}
+
+// delAssetIndex removes the madeby~serialnumber entry of an asset, together with the
+// [MadeBy, ID] entry written by earlier versions of the chaincode. Entries that refer to
+// another asset are left alone.
+func delAssetIndex(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	for _, attributes := range [][]string{{asset.MadeBy, asset.SerialNumber}, {asset.MadeBy, asset.ID}} {
+		indexKey, err := ctx.GetStub().CreateCompositeKey(madeBySerialNumberIndex, attributes)
+		if err != nil {
+			return err
+		}
+		value, err := ctx.GetStub().GetState(indexKey)
+		if err != nil {
+			return internalError("failed to get index entry of asset %s: %v", asset.ID, err)
+		}
+		legacy := attributes[1] == asset.ID && bytes.Equal(value, []byte{0x00})
+		if string(value) != asset.ID && !legacy {
+			continue
+		}
+		err = ctx.GetStub().DelState(indexKey)
+		if err != nil {
+			return internalError("failed to delete index entry of asset %s: %v", asset.ID, err)
+		}
+	}
+
+	return nil
+}
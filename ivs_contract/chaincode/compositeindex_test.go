@@ -0,0 +1,241 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// readAssetBySerialNumber looks up an asset through the madeby~serialnumber index
+func readAssetBySerialNumber(l *testLedger, madeby string, serialnumber string) (*Asset, error) {
+	var asset *Asset
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		asset, err = l.contract.ReadAssetBySerialNumber(ctx, madeby, serialnumber)
+		return err
+	})
+	return asset, err
+}
+
+func TestSerialNumberIndex(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	moveSetToBrand(t, l, "0002")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	asset, err := readAssetBySerialNumber(l, "Brand.Co", "IVSPN902300AACDC01")
+	if err != nil || asset.ID != "IVSLAB-PVC23FG0001" {
+		t.Fatalf("ReadAssetBySerialNumber returned %+v, %v", asset, err)
+	}
+	_, err = readAssetBySerialNumber(l, "Other.Co", "IVSPN902300AACDC01")
+	expectErrorCode(t, err, ErrCodeNotFound)
+
+	// serial numbers are unique per brand
+	err = createAsset(l, "IVSLAB-PVC23FG0002", "IVSPN902300AACDC01", "0002")
+	expectErrorCode(t, err, ErrCodeAlreadyExists)
+	if err := createAsset(l, "IVSLAB-PVC23FG0002", "IVSPN902300AACDC02", "0002"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FG0002", "Brand.Co", "Taiwan", "IVSPN902300AACDC01",
+			chipSet("IVSLAB-S23FA0002", "IVSLAB-N23FA0002", "IVSLAB-C23FA0002", "IVSLAB-V23FA0002"))
+	})
+	expectErrorCode(t, err, ErrCodeAlreadyExists)
+
+	// changing the brand and serial number moves the index entry
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FG0001", "Rebrand.Co", "Taiwan", "IVSPN902300AACDC09",
+			chipSet("IVSLAB-S23FA0001", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001"))
+	})
+	if err != nil {
+		t.Fatalf("UpdateAsset failed: %v", err)
+	}
+	_, err = readAssetBySerialNumber(l, "Brand.Co", "IVSPN902300AACDC01")
+	expectErrorCode(t, err, ErrCodeNotFound)
+	if asset, err := readAssetBySerialNumber(l, "Rebrand.Co", "IVSPN902300AACDC09"); err != nil || asset.ID != "IVSLAB-PVC23FG0001" {
+		t.Fatalf("ReadAssetBySerialNumber returned %+v, %v", asset, err)
+	}
+	err = l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		counts, err := l.contract.CountAssetsByBrand(ctx)
+		if err != nil {
+			return err
+		}
+		if len(counts) != 2 || counts[0].Count != 1 || counts[1].Count != 1 {
+			t.Fatalf("expected one asset per brand, got %+v %+v", counts[0], counts[len(counts)-1])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an update that keeps the serial number keeps the entry
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FG0002", "Brand.Co", "Vietnam", "IVSPN902300AACDC02",
+			chipSet("IVSLAB-S23FA0002", "IVSLAB-N23FA0002", "IVSLAB-C23FA0002", "IVSLAB-V23FA0002"))
+	})
+	if err != nil {
+		t.Fatalf("UpdateAsset failed: %v", err)
+	}
+	if asset, err := readAssetBySerialNumber(l, "Brand.Co", "IVSPN902300AACDC02"); err != nil || asset.MadeIn != "Vietnam" {
+		t.Fatalf("ReadAssetBySerialNumber returned %+v, %v", asset, err)
+	}
+
+	// a decommissioned asset keeps its serial number, a purged one frees it
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeleteAsset(ctx, "IVSLAB-PVC23FG0002", false, "end of life")
+	})
+	if err != nil {
+		t.Fatalf("DeleteAsset failed: %v", err)
+	}
+	if asset, err := readAssetBySerialNumber(l, "Brand.Co", "IVSPN902300AACDC02"); err != nil || asset.Status != AssetStatusDecommissioned {
+		t.Fatalf("ReadAssetBySerialNumber returned %+v, %v", asset, err)
+	}
+	err = l.submitWithAttributes(brandMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.PurgeAsset(ctx, "IVSLAB-PVC23FG0002")
+	})
+	if err != nil {
+		t.Fatalf("PurgeAsset failed: %v", err)
+	}
+	_, err = readAssetBySerialNumber(l, "Brand.Co", "IVSPN902300AACDC02")
+	expectErrorCode(t, err, ErrCodeNotFound)
+}
+
+func TestRebuildIndexes(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	// a second asset with the same serial number, written behind the contract's back
+	duplicate := readAsset(t, l, "IVSLAB-PVC23FG0001")
+	duplicate.ID = "IVSLAB-PVC23FG0002"
+	duplicate.Components = nil
+	duplicateBytes, _ := json.Marshal(duplicate)
+
+	// damage the indexes the way earlier versions of the chaincode left them
+	err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		stub := ctx.GetStub()
+		serialKey, _ := stub.CreateCompositeKey(madeBySerialNumberIndex, []string{"Brand.Co", "IVSPN902300AACDC01"})
+		legacyKey, _ := stub.CreateCompositeKey(madeBySerialNumberIndex, []string{"Brand.Co", "IVSLAB-PVC23FG0001"})
+		staleKey, _ := stub.CreateCompositeKey(manufacturerPartIndex, []string{SecurityOrg, "IVSLAB-S23FA0001"})
+		missingKey, _ := stub.CreateCompositeKey(manufacturerPartIndex, []string{NetworkOrg, "IVSLAB-N23FA0002"})
+		stub.DelState(serialKey)
+		stub.DelState(missingKey)
+		stub.PutState(legacyKey, []byte{0x00})
+		stub.PutState(staleKey, []byte{0x00})
+		return stub.PutState(assetKey(duplicate.ID), duplicateBytes)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// legacy entries are still readable
+	err = l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		assets, err := l.contract.GetAssetsByBrand(ctx, "Brand.Co")
+		if err != nil {
+			return err
+		}
+		if len(assets) != 1 || assets[0].ID != "IVSLAB-PVC23FG0001" {
+			t.Fatalf("expected the legacy entry to be read, got %+v", assets)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.RebuildIndexes(ctx)
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeForbidden)
+
+	var result *IndexRebuildResult
+	err = l.submitWithAttributes(brandMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = l.contract.RebuildIndexes(ctx)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RebuildIndexes failed: %v", err)
+	}
+	if result.Added != 2 || result.Removed != 2 || result.Updated != 0 {
+		t.Fatalf("unexpected rebuild result %+v", result)
+	}
+	if len(result.Duplicates) != 1 || result.Duplicates[0] != "IVSLAB-PVC23FG0002" {
+		t.Fatalf("expected IVSLAB-PVC23FG0002 to be reported as duplicate, got %v", result.Duplicates)
+	}
+	expectEvent(t, l, EventIndexesRebuilt, "")
+
+	if asset, err := readAssetBySerialNumber(l, "Brand.Co", "IVSPN902300AACDC01"); err != nil || asset.ID != "IVSLAB-PVC23FG0001" {
+		t.Fatalf("ReadAssetBySerialNumber returned %+v, %v", asset, err)
+	}
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		parts, err := l.contract.GetPartsByOrganization(ctx, NetworkOrg)
+		if err != nil {
+			return err
+		}
+		if len(parts) != 2 {
+			t.Fatalf("expected the missing entry to be restored, got %v", partIDs(parts))
+		}
+		counts, err := l.contract.CountPartsByOrganization(ctx)
+		if err != nil {
+			return err
+		}
+		for _, count := range counts {
+			if count.Organization == SecurityOrg && count.Count != 2 {
+				t.Fatalf("expected the stale entry to be removed, got %+v", count)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a consistent ledger needs no changes
+	err = l.submitWithAttributes(brandMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = l.contract.RebuildIndexes(ctx)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RebuildIndexes failed: %v", err)
+	}
+	if result.Added != 0 || result.Removed != 0 || result.Updated != 0 {
+		t.Fatalf("expected no changes, got %+v", result)
+	}
+}
+
+func TestPurgeKeepsIndexEntryOfAnotherAsset(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	// a second asset with the same serial number, written behind the contract's back
+	duplicate := readAsset(t, l, "IVSLAB-PVC23FG0001")
+	duplicate.ID = "IVSLAB-PVC23FG0002"
+	duplicate.Components = nil
+	duplicateBytes, _ := json.Marshal(duplicate)
+	l.stub.state[assetKey(duplicate.ID)] = duplicateBytes
+
+	err := l.submitWithAttributes(brandMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.PurgeAsset(ctx, "IVSLAB-PVC23FG0002")
+	})
+	if err != nil {
+		t.Fatalf("PurgeAsset failed: %v", err)
+	}
+	if asset, err := readAssetBySerialNumber(l, "Brand.Co", "IVSPN902300AACDC01"); err != nil || asset.ID != "IVSLAB-PVC23FG0001" {
+		t.Fatalf("expected the serial number to still resolve to the first asset, got %+v, %v", asset, err)
+	}
+}
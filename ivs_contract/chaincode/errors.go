@@ -0,0 +1,64 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Codes of the structured errors returned by the contract. They are part of the contract's API:
+// clients branch on them, so a code must never change meaning.
+const (
+	ErrCodeNotFound        = "NOT_FOUND"
+	ErrCodeAlreadyExists   = "ALREADY_EXISTS"
+	ErrCodeForbidden       = "FORBIDDEN"
+	ErrCodeInvalidArgument = "INVALID_ARGUMENT"
+	ErrCodeConflict        = "CONFLICT"
+	ErrCodeInternal        = "INTERNAL"
+)
+
+// ContractError is returned by every transaction that fails. Its message is a JSON document
+// with a stable code, so that clients do not have to parse English error strings.
+type ContractError struct {
+	Code    string      `json:"code"`
+	Field   string      `json:"field,omitempty"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *ContractError) Error() string {
+	errBytes, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+	return string(errBytes)
+}
+
+// contractError builds a ContractError with the given code
+func contractError(code string, format string, args ...interface{}) error {
+	return &ContractError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// notFound is returned when a requested record does not exist
+func notFound(format string, args ...interface{}) error {
+	return contractError(ErrCodeNotFound, format, args...)
+}
+
+// alreadyExists is returned when a record to be created already exists
+func alreadyExists(format string, args ...interface{}) error {
+	return contractError(ErrCodeAlreadyExists, format, args...)
+}
+
+// invalidArgument is returned when an argument of a transaction is malformed
+func invalidArgument(field string, format string, args ...interface{}) error {
+	return &ContractError{Code: ErrCodeInvalidArgument, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// conflict is returned when the ledger state does not allow the transaction, e.g. a part is already installed
+func conflict(format string, args ...interface{}) error {
+	return contractError(ErrCodeConflict, format, args...)
+}
+
+// internalError is returned when the world state cannot be read or written
+func internalError(format string, args ...interface{}) error {
+	return contractError(ErrCodeInternal, format, args...)
+}
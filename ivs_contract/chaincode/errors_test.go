@@ -0,0 +1,117 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// expectErrorCode fails the test unless err is a JSON error document with the given code
+func expectErrorCode(t *testing.T, err error, code string) map[string]string {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected %s error, got nil", code)
+	}
+	var payload map[string]string
+	if jsonErr := json.Unmarshal([]byte(err.Error()), &payload); jsonErr != nil {
+		t.Fatalf("expected a JSON error message, got %q", err.Error())
+	}
+	if payload["code"] != code {
+		t.Fatalf("expected %s error, got %s", code, err.Error())
+	}
+	return payload
+}
+
+func TestErrorCodes(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA0002")
+	if err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeleteAsset(ctx, "IVSLAB-PVC23FG0001", true, "water damage")
+	}); err != nil {
+		t.Fatalf("DeleteAsset failed: %v", err)
+	}
+	// records that can no longer be unmarshalled
+	l.stub.state[partKey("IVSLAB-S23FA0098")] = []byte("{")
+	l.stub.state[assetKey("IVSLAB-PVC23FG0098")] = []byte("{")
+	l.stub.state[modelKey("IVS-CORRUPT")] = []byte("{")
+
+	tests := []struct {
+		name  string
+		mspID string
+		code  string
+		fn    func(ctx contractapi.TransactionContextInterface) error
+	}{
+		{"read missing part", brandMSP, ErrCodeNotFound, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.ReadPart(ctx, "IVSLAB-S23FA9999")
+			return err
+		}},
+		{"read missing asset", brandMSP, ErrCodeNotFound, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.ReadAsset(ctx, "IVSLAB-PVC23FG9999")
+			return err
+		}},
+		{"read missing offer", brandMSP, ErrCodeNotFound, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.ReadTransferOffer(ctx, "tx9999")
+			return err
+		}},
+		{"create existing part", securityMSP, ErrCodeAlreadyExists, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreatePart(ctx, "IVSLAB-S23FA0003", "Security.Co", "Taiwan", "SecurityChip-v1", "SPN3R1C00AA3", SecurityOrg)
+		}},
+		{"create existing model", brandMSP, ErrCodeAlreadyExists, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreateProductModel(ctx, DefaultProductModelID, "duplicate", defaultProductModelSlots)
+		}},
+		{"create part for another organization", networkMSP, ErrCodeForbidden, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreatePart(ctx, "IVSLAB-S23FA0009", "Security.Co", "Taiwan", "SecurityChip-v1", "SPN3R1C00AA9", SecurityOrg)
+		}},
+		{"transfer to unknown organization", securityMSP, ErrCodeInvalidArgument, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.TransferPart(ctx, "IVSLAB-S23FA0003", "Other-Org")
+			return err
+		}},
+		{"install scrapped part", brandMSP, ErrCodeConflict, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreateAsset(ctx, "IVSLAB-PVC23FG0002", DefaultProductModelID, "Brand.Co", "Taiwan", "IVSPN902300AACDC02",
+				chipSet("IVSLAB-S23FA0001", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001"))
+		}},
+		{"install part of a chip maker", brandMSP, ErrCodeConflict, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreateAsset(ctx, "IVSLAB-PVC23FG0002", DefaultProductModelID, "Brand.Co", "Taiwan", "IVSPN902300AACDC02",
+				chipSet("IVSLAB-S23FA0002", "IVSLAB-N23FA0002", "IVSLAB-C23FA0002", "IVSLAB-V23FA0002"))
+		}},
+		{"transfer scrapped part", brandMSP, ErrCodeConflict, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.TransferPart(ctx, "IVSLAB-S23FA0001", NetworkOrg)
+			return err
+		}},
+		{"read corrupt part", brandMSP, ErrCodeInternal, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.ReadPart(ctx, "IVSLAB-S23FA0098")
+			return err
+		}},
+		{"read corrupt asset", brandMSP, ErrCodeInternal, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.ReadAsset(ctx, "IVSLAB-PVC23FG0098")
+			return err
+		}},
+		{"read corrupt product model", brandMSP, ErrCodeInternal, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.ReadProductModel(ctx, "IVS-CORRUPT")
+			return err
+		}},
+		{"list corrupt parts", brandMSP, ErrCodeInternal, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.GetAllParts(ctx)
+			return err
+		}},
+		{"list corrupt assets", brandMSP, ErrCodeInternal, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.GetAllAssets(ctx)
+			return err
+		}},
+		{"list corrupt product models", brandMSP, ErrCodeInternal, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.GetAllProductModels(ctx)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectErrorCode(t, l.submit(tt.mspID, tt.fn), tt.code)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventVersion is the version of the ContractEvent payload. It changes whenever a field
+// is removed or changes meaning, so that listeners can reject payloads they do not understand.
+const EventVersion = 1
+
+// Names of the chaincode events. Fabric keeps a single event per transaction, so every
+// mutating transaction emits exactly one of these.
+const (
+	EventLedgerInitialized     = "LedgerInitialized"
+	EventLedgerKeysMigrated    = "LedgerKeysMigrated"
+	EventPartCreated           = "PartCreated"
+	EventPartsCreated          = "PartsCreated"
+	EventPartDeleted           = "PartDeleted"
+	EventPartTransferOffered   = "PartTransferOffered"
+	EventPartTransferAccepted  = "PartTransferAccepted"
+	EventPartTransferRejected  = "PartTransferRejected"
+	EventPartTransferCancelled = "PartTransferCancelled"
+	EventPartTransferExpired   = "PartTransferExpired"
+	EventAssetCreated          = "AssetCreated"
+	EventAssetUpdated          = "AssetUpdated"
+	EventAssetDeleted          = "AssetDeleted"
+	EventAssetRepaired         = "AssetRepaired"
+	EventPartPurged            = "PartPurged"
+	EventAssetPurged           = "AssetPurged"
+	EventLotCreated            = "LotCreated"
+	EventProductModelCreated   = "ProductModelCreated"
+	EventIndexesRebuilt        = "IndexesRebuilt"
+)
+
+// offerEvents maps the final state of a transfer offer to the event emitted when it is reached
+var offerEvents = map[string]string{
+	OfferStatusAccepted:  EventPartTransferAccepted,
+	OfferStatusRejected:  EventPartTransferRejected,
+	OfferStatusCancelled: EventPartTransferCancelled,
+	OfferStatusExpired:   EventPartTransferExpired,
+}
+
+// ContractEvent is the JSON payload of every chaincode event
+type ContractEvent struct {
+	Version    int      `json:"version"`
+	Name       string   `json:"name"`
+	ObjectType string   `json:"objectType"`
+	ObjectID   string   `json:"objectId"`
+	PartIDs    []string `json:"partIds,omitempty"`
+	OldOwner   string   `json:"oldOwner,omitempty"`
+	NewOwner   string   `json:"newOwner,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+	TxID       string   `json:"txId"`
+	Timestamp  string   `json:"timestamp"`
+}
+
+// emitEvent completes the event with the version, tx ID and tx timestamp and sets it on the transaction
+func emitEvent(ctx contractapi.TransactionContextInterface, event *ContractEvent) error {
+	timestamp, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	event.Version = EventVersion
+	event.TxID = ctx.GetStub().GetTxID()
+	event.Timestamp = timestamp
+
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return internalError("failed to marshal %s event: %v", event.Name, err)
+	}
+
+	return ctx.GetStub().SetEvent(event.Name, eventBytes)
+}
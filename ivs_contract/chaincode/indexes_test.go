@@ -0,0 +1,195 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// indexDir holds the CouchDB indexes packaged with the chaincode
+const indexDir = "../META-INF/statedb/couchdb/indexes"
+
+// couchIndex is a CouchDB index definition as shipped in META-INF
+type couchIndex struct {
+	Index struct {
+		Fields []string `json:"fields"`
+	} `json:"index"`
+	DDoc string `json:"ddoc"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// loadIndexes reads every index definition shipped with the chaincode
+func loadIndexes(t *testing.T) map[string]*couchIndex {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(indexDir, "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no index definitions found in %s: %v", indexDir, err)
+	}
+
+	indexes := make(map[string]*couchIndex)
+	for _, file := range files {
+		indexBytes, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file, err)
+		}
+		var index couchIndex
+		if err := json.Unmarshal(indexBytes, &index); err != nil {
+			t.Fatalf("%s is not a valid index definition: %v", file, err)
+		}
+		indexes[filepath.Base(file)] = &index
+	}
+	return indexes
+}
+
+// requireIndexed fails the test unless a shipped index can serve the CouchDB query. Like CouchDB,
+// an index is usable when the selector constrains every indexed field; for a sorted query the
+// indexed fields that the selector does not pin to a single value must start with the sort fields.
+// A query filtering on more than docType must not fall back to an index on docType alone.
+func requireIndexed(t *testing.T, indexes map[string]*couchIndex, queryString string) {
+	t.Helper()
+	var request struct {
+		Selector map[string]interface{} `json:"selector"`
+		Sort     []map[string]string    `json:"sort"`
+	}
+	if err := json.Unmarshal([]byte(queryString), &request); err != nil {
+		t.Fatalf("invalid query %s: %v", queryString, err)
+	}
+	// sorting on a field pinned to a single value needs no index
+	var sortFields []string
+	for _, sortKey := range request.Sort {
+		for field := range sortKey {
+			if _, isOperator := request.Selector[field].(map[string]interface{}); isOperator {
+				sortFields = append(sortFields, field)
+			}
+		}
+	}
+
+	filtered := false
+	for field := range request.Selector {
+		if field != "docType" && !strings.HasPrefix(field, "$") {
+			filtered = true
+		}
+	}
+
+	for _, index := range indexes {
+		if filtered && len(index.Index.Fields) < 2 {
+			continue
+		}
+		usable := true
+		var ordered []string
+		for _, field := range index.Index.Fields {
+			condition, ok := request.Selector[field]
+			if !ok {
+				usable = false
+				break
+			}
+			if _, isOperator := condition.(map[string]interface{}); isOperator {
+				ordered = append(ordered, field)
+			}
+		}
+		if !usable || len(ordered) < len(sortFields) {
+			continue
+		}
+		sorted := true
+		for i, field := range sortFields {
+			if ordered[i] != field {
+				sorted = false
+			}
+		}
+		if sorted {
+			return
+		}
+	}
+	t.Fatalf("no shipped index serves query %s", queryString)
+}
+
+func TestIndexDefinitions(t *testing.T) {
+	documentFields := jsonFieldNames(Asset{})
+	for field := range jsonFieldNames(Part{}) {
+		documentFields[field] = true
+	}
+
+	names := make(map[string]string)
+	for file, index := range loadIndexes(t) {
+		if index.Type != "json" || index.DDoc == "" || index.Name == "" {
+			t.Fatalf("%s: incomplete index definition %+v", file, index)
+		}
+		if strings.TrimSuffix(file, ".json") != index.Name {
+			t.Fatalf("%s: index name %s does not match the file name", file, index.Name)
+		}
+		if len(index.Index.Fields) == 0 || index.Index.Fields[0] != "docType" {
+			t.Fatalf("%s: indexes must start with docType, got %v", file, index.Index.Fields)
+		}
+		if other, ok := names[index.Name]; ok {
+			t.Fatalf("%s: index name %s is also used by %s", file, index.Name, other)
+		}
+		names[index.Name] = file
+
+		// every indexed field must exist in the documents of the chaincode
+		for _, field := range index.Index.Fields[1:] {
+			if !documentFields[field] {
+				t.Fatalf("%s: no asset or part field named %s", file, field)
+			}
+		}
+	}
+}
+
+// jsonFieldNames returns the JSON names of the fields of a struct
+func jsonFieldNames(v interface{}) map[string]bool {
+	names := make(map[string]bool)
+	structType := reflect.TypeOf(v)
+	for i := 0; i < structType.NumField(); i++ {
+		names[strings.Split(structType.Field(i).Tag.Get("json"), ",")[0]] = true
+	}
+	return names
+}
+
+func TestAssetQueriesAreIndexed(t *testing.T) {
+	indexes := loadIndexes(t)
+
+	// every combination of the indexable filters, unsorted and sorted by each allowed field
+	filters := []func(q *AssetQuery){
+		func(q *AssetQuery) { q.MadeBy = "Brand.Co" },
+		func(q *AssetQuery) { q.MadeIn = "Taiwan" },
+		func(q *AssetQuery) { q.SerialNumberFrom = "IVSPN902300AACDC01" },
+		func(q *AssetQuery) { q.SerialNumberTo = "IVSPN902300AACDC09" },
+		func(q *AssetQuery) { q.ProducedFrom = "2023-05-01T00:00:00Z" },
+		func(q *AssetQuery) { q.PartID = "IVSLAB-S23FA0001" },
+		func(q *AssetQuery) { q.PartManufacturer = "Security.Co" },
+	}
+	sortFields := []string{""}
+	for field := range assetSortFields {
+		sortFields = append(sortFields, field)
+	}
+
+	for mask := 0; mask < 1<<len(filters); mask++ {
+		for _, sortBy := range sortFields {
+			query := AssetQuery{SortBy: sortBy}
+			for i, filter := range filters {
+				if mask&(1<<i) != 0 {
+					filter(&query)
+				}
+			}
+			queryString, err := buildAssetQuery(query)
+			if err != nil {
+				t.Fatalf("buildAssetQuery %+v failed: %v", query, err)
+			}
+			requireIndexed(t, indexes, queryString)
+		}
+	}
+}
+
+func TestPartQueriesAreIndexed(t *testing.T) {
+	indexes := loadIndexes(t)
+	for _, field := range []string{"Manufacturer", "PartNumber"} {
+		queryString, err := buildPartQuery(field, "value")
+		if err != nil {
+			t.Fatalf("buildPartQuery %s failed: %v", field, err)
+		}
+		requireIndexed(t, indexes, queryString)
+	}
+}
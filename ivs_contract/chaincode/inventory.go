@@ -0,0 +1,213 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// The queries in this file read the organization~partID and madeby~serialnumber composite keys
+// instead of issuing rich queries, so they also work on peers backed by LevelDB.
+
+// OrganizationCount is the number of records held by one organization or brand
+type OrganizationCount struct {
+	Organization string `json:"organization"` // 組織或品牌商
+	Count        int    `json:"count"`        // 數量
+}
+
+// GetPartsByOrganization returns the parts owned by organization
+func (t *SmartContract) GetPartsByOrganization(ctx contractapi.TransactionContextInterface, organization string) ([]*Part, error) {
+	if !isOrganization(organization) {
+		return nil, invalidArgument("organization", "unknown organization %s", organization)
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(manufacturerPartIndex, []string{organization})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return partsFromIndex(ctx, resultsIterator)
+}
+
+// GetAssetsByBrand returns the assets made by brand. Decommissioned assets stay on the ledger and
+// are listed as well, with their status set to decommissioned.
+func (t *SmartContract) GetAssetsByBrand(ctx contractapi.TransactionContextInterface, brand string) ([]*Asset, error) {
+	if brand == "" {
+		return nil, invalidArgument("brand", "brand must not be empty")
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(madeBySerialNumberIndex, []string{brand})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return assetsFromIndex(ctx, resultsIterator)
+}
+
+// GetAssetsByBrandWithPagination returns a page of the assets made by brand. FetchedRecordsCount
+// is the number of index entries read, so Records may be shorter when stale entries are skipped.
+func (t *SmartContract) GetAssetsByBrandWithPagination(ctx contractapi.TransactionContextInterface, brand string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	err := validatePageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if brand == "" {
+		return nil, invalidArgument("brand", "brand must not be empty")
+	}
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(madeBySerialNumberIndex, []string{brand}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	assets, err := assetsFromIndex(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             assets,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// CountPartsByOrganization returns the number of parts owned by each organization, including
+// organizations that own none. Scrapped parts and stale index entries are not counted.
+func (t *SmartContract) CountPartsByOrganization(ctx contractapi.TransactionContextInterface) ([]*OrganizationCount, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(manufacturerPartIndex, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	parts, err := partsFromIndex(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, organization := range mspOrganizations {
+		counts[organization] = 0
+	}
+	for _, part := range parts {
+		if part.Status != PartStatusScrapped {
+			counts[part.Organization]++
+		}
+	}
+
+	return sortedCounts(counts), nil
+}
+
+// CountAssetsByBrand returns the number of assets made by each brand. Decommissioned assets and
+// stale index entries are not counted.
+func (t *SmartContract) CountAssetsByBrand(ctx contractapi.TransactionContextInterface) ([]*OrganizationCount, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(madeBySerialNumberIndex, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	assets, err := assetsFromIndex(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, asset := range assets {
+		if asset.Status != AssetStatusDecommissioned {
+			counts[asset.MadeBy]++
+		}
+	}
+
+	return sortedCounts(counts), nil
+}
+
+// partsFromIndex reads the parts referenced by the organization~partID keys of resultsIterator
+func partsFromIndex(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface) ([]*Part, error) {
+	parts := []*Part{}
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 {
+			continue
+		}
+		partBytes, err := ctx.GetStub().GetState(partKey(compositeKeyParts[1]))
+		if err != nil {
+			return nil, internalError("failed to get part %s: %v", compositeKeyParts[1], err)
+		}
+		// skip index entries that no longer match the part
+		var part Part
+		if partBytes == nil || json.Unmarshal(partBytes, &part) != nil || part.Organization != compositeKeyParts[0] {
+			continue
+		}
+		parts = append(parts, &part)
+	}
+
+	return parts, nil
+}
+
+// assetsFromIndex reads the assets referenced by the madeby~serialnumber entries of resultsIterator
+func assetsFromIndex(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface) ([]*Asset, error) {
+	assets := []*Asset{}
+	seen := make(map[string]bool)
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 {
+			continue
+		}
+		// entries written by earlier versions hold the asset ID in the key instead of the value
+		assetID := string(responseRange.Value)
+		legacy := bytes.Equal(responseRange.Value, []byte{0x00})
+		if legacy {
+			assetID = compositeKeyParts[1]
+		}
+		assetBytes, err := ctx.GetStub().GetState(assetKey(assetID))
+		if err != nil {
+			return nil, internalError("failed to get asset %s: %v", assetID, err)
+		}
+		// skip index entries that no longer match the asset
+		var asset Asset
+		if assetBytes == nil || json.Unmarshal(assetBytes, &asset) != nil || asset.MadeBy != compositeKeyParts[0] {
+			continue
+		}
+		if !legacy && asset.SerialNumber != compositeKeyParts[1] {
+			continue
+		}
+		// an asset not yet migrated may have both a legacy and a current entry
+		if seen[asset.ID] {
+			continue
+		}
+		seen[asset.ID] = true
+		normalizeAsset(&asset)
+		assets = append(assets, &asset)
+	}
+
+	return assets, nil
+}
+
+// sortedCounts returns the counts ordered by organization
+func sortedCounts(counts map[string]int) []*OrganizationCount {
+	result := make([]*OrganizationCount, 0, len(counts))
+	for organization, count := range counts {
+		result = append(result, &OrganizationCount{Organization: organization, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Organization < result[j].Organization
+	})
+	return result
+}
@@ -0,0 +1,196 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestInventoryQueries(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	moveSetToBrand(t, l, "0002")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	if err := createAsset(l, "IVSLAB-PVC23FG0002", "IVSPN902300AACDC02", "0002"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	err := l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		parts, err := l.contract.GetPartsByOrganization(ctx, NetworkOrg)
+		if err != nil {
+			return err
+		}
+		if ids := partIDs(parts); len(ids) != 1 || ids[0] != "IVSLAB-N23FA0003" {
+			t.Fatalf("expected Network-Org to keep one part, got %v", ids)
+		}
+		parts, err = l.contract.GetPartsByOrganization(ctx, BrandOrg)
+		if err != nil {
+			return err
+		}
+		if len(parts) != 8 {
+			t.Fatalf("expected 8 parts owned by Brand-Org, got %v", partIDs(parts))
+		}
+
+		assets, err := l.contract.GetAssetsByBrand(ctx, "Brand.Co")
+		if err != nil {
+			return err
+		}
+		if len(assets) != 2 || assets[0].ID != "IVSLAB-PVC23FG0001" || len(assets[1].Components) != 4 {
+			t.Fatalf("unexpected assets %+v", assets)
+		}
+		assets, err = l.contract.GetAssetsByBrand(ctx, "Other.Co")
+		if err != nil {
+			return err
+		}
+		if len(assets) != 0 {
+			t.Fatalf("expected no assets of Other.Co, got %d", len(assets))
+		}
+
+		page, err := l.contract.GetAssetsByBrandWithPagination(ctx, "Brand.Co", 1, "")
+		if err != nil {
+			return err
+		}
+		if page.FetchedRecordsCount != 1 || page.Records[0].ID != "IVSLAB-PVC23FG0001" || page.Bookmark == "" {
+			t.Fatalf("unexpected first page %+v", page)
+		}
+		page, err = l.contract.GetAssetsByBrandWithPagination(ctx, "Brand.Co", 1, page.Bookmark)
+		if err != nil {
+			return err
+		}
+		if page.FetchedRecordsCount != 1 || page.Records[0].ID != "IVSLAB-PVC23FG0002" {
+			t.Fatalf("unexpected second page %+v", page)
+		}
+
+		counts, err := l.contract.CountPartsByOrganization(ctx)
+		if err != nil {
+			return err
+		}
+		want := map[string]int{BrandOrg: 8, CMOSOrg: 1, NetworkOrg: 1, SecurityOrg: 1, VideoCodecOrg: 1}
+		if len(counts) != len(want) {
+			t.Fatalf("expected counts %v, got %d entries", want, len(counts))
+		}
+		for i, count := range counts {
+			if want[count.Organization] != count.Count {
+				t.Fatalf("expected counts %v, got %+v", want, count)
+			}
+			if i > 0 && counts[i-1].Organization >= count.Organization {
+				t.Fatalf("counts are not ordered by organization")
+			}
+		}
+
+		counts, err = l.contract.CountAssetsByBrand(ctx)
+		if err != nil {
+			return err
+		}
+		if len(counts) != 1 || counts[0].Organization != "Brand.Co" || counts[0].Count != 2 {
+			t.Fatalf("unexpected asset counts %+v", counts)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.GetPartsByOrganization(ctx, "Other-Org")
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeInvalidArgument)
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.GetAssetsByBrand(ctx, "")
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeInvalidArgument)
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.GetAssetsByBrandWithPagination(ctx, "Brand.Co", 0, "")
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeInvalidArgument)
+}
+
+func TestInventoryCountsSkipStaleEntries(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	moveSetToBrand(t, l, "0002")
+	if err := createAsset(l, "IVSLAB-PVC23FG0002", "IVSPN902300AACDC02", "0002"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeleteAsset(ctx, "IVSLAB-PVC23FG0002", false, "end of life")
+	})
+	if err != nil {
+		t.Fatalf("DeleteAsset failed: %v", err)
+	}
+	count := func(counts []*OrganizationCount, organization string) int {
+		for _, count := range counts {
+			if count.Organization == organization {
+				return count.Count
+			}
+		}
+		return 0
+	}
+	var before []*OrganizationCount
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		before, err = l.contract.CountPartsByOrganization(ctx)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeletePart(ctx, "IVSLAB-N23FA0003", "damaged")
+	})
+	if err != nil {
+		t.Fatalf("DeletePart failed: %v", err)
+	}
+	// an entry for a part that does not exist, one for a missing asset and a legacy duplicate
+	missingPart, _ := l.stub.CreateCompositeKey(manufacturerPartIndex, []string{SecurityOrg, "IVSLAB-S23FA0099"})
+	missingAsset, _ := l.stub.CreateCompositeKey(madeBySerialNumberIndex, []string{"Brand.Co", "IVSPN902300AACDC99"})
+	legacyAsset, _ := l.stub.CreateCompositeKey(madeBySerialNumberIndex, []string{"Brand.Co", "IVSLAB-PVC23FG0001"})
+	l.stub.state[missingPart] = []byte{0x00}
+	l.stub.state[missingAsset] = []byte("IVSLAB-PVC23FG0099")
+	l.stub.state[legacyAsset] = []byte{0x00}
+
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		after, err := l.contract.CountPartsByOrganization(ctx)
+		if err != nil {
+			return err
+		}
+		if count(after, NetworkOrg) != count(before, NetworkOrg)-1 {
+			t.Fatalf("expected the scrapped part not to be counted, got %+v", after)
+		}
+		if count(after, SecurityOrg) != count(before, SecurityOrg) {
+			t.Fatalf("expected the stale part entry not to be counted, got %+v", after)
+		}
+
+		counts, err := l.contract.CountAssetsByBrand(ctx)
+		if err != nil {
+			return err
+		}
+		if len(counts) != 1 || counts[0].Count != 1 {
+			t.Fatalf("expected one asset of Brand.Co besides the decommissioned one, got %+v", counts)
+		}
+
+		// the decommissioned asset is still listed, and the stale entries are fetched but skipped
+		page, err := l.contract.GetAssetsByBrandWithPagination(ctx, "Brand.Co", 10, "")
+		if err != nil {
+			return err
+		}
+		if len(page.Records) != 2 || page.FetchedRecordsCount != 4 || page.Records[1].Status != AssetStatusDecommissioned {
+			t.Fatalf("expected a page with two assets, got %+v", page)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,7 +2,6 @@ package chaincode
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"time"
 
@@ -11,7 +10,7 @@ import (
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 const manufacturerPartIndex = "organization~partID"
-const madeInSerialNumberIndex = "madeby~serialnumber"
+const madeBySerialNumberIndex = "madeby~serialnumber"
 
 //const index = "madein~serialnumber"
 
@@ -23,33 +22,75 @@ type SmartContract struct {
 // Asset Project項目列表.
 type Asset struct {
 	DocType             string `json:"docType"`             	// DocType is used to distinguish the various types of objects in state database
-	ID                	string `json:"ID"`                		// 項目唯一ID
-	MadeBy        		string `json:"MadeBy"`        			// 品牌商
-	MadeIn 				string `json:"MadeIn"` 					// 組裝地點
-	SerialNumber        string `json:"SerialNumber"`        	// 產品序號
-	SecurityChip        Part   `json:"SecurityChip"`        	// 安全晶片組織
-	NetworkChip         Part   `json:"NetworkChip"`         	// 網路晶片組織
-	CMOSChip            Part   `json:"CMOSChip"`            	// CMOS晶片組織
-	VideoCodecChip		Part   `json:"VideoCodecChip"`      	// VideoCodec晶片組織
+	ID                	string `json:"ID" validate:"required,pattern=assetID"`                		// 項目唯一ID
+	MadeBy        		string `json:"MadeBy" validate:"required,max=64"`        			// 品牌商
+	MadeIn 				string `json:"MadeIn" validate:"required,max=64"` 					// 組裝地點
+	SerialNumber        string `json:"SerialNumber" validate:"required,pattern=serialNumber"`        	// 產品序號
+	ModelID             string `json:"ModelID,omitempty" metadata:",optional" validate:"max=64,pattern=code"`     	// 產品型號
+	Components     []Component `json:"Components"`          	// 產品組件 (物料清單)
+	SecurityChip        *Part  `json:"SecurityChip,omitempty" metadata:",optional"`  	// 舊版安全晶片欄位, 讀取時移入Components
+	NetworkChip         *Part  `json:"NetworkChip,omitempty" metadata:",optional"`   	// 舊版網路晶片欄位, 讀取時移入Components
+	CMOSChip            *Part  `json:"CMOSChip,omitempty" metadata:",optional"`      	// 舊版CMOS晶片欄位, 讀取時移入Components
+	VideoCodecChip      *Part  `json:"VideoCodecChip,omitempty" metadata:",optional"`	// 舊版VideoCodec晶片欄位, 讀取時移入Components
 	ProductionDate      string `json:"ProductionDate"`      	// 產品生產日期
 	Updated				string `json:"Updated"`      			// 產品更新日期
+	Revision            int    `json:"Revision"`            	// 版本, 每次異動加一
+	UpdatedBy           string `json:"UpdatedBy,omitempty" metadata:",optional"` 	// 最後異動者
+	UpdateReason        string `json:"UpdateReason,omitempty" metadata:",optional"`	// 最後異動原因代碼
+	RepairOrderID       string `json:"RepairOrderID,omitempty" metadata:",optional"`	// 最後維修單號
+	Status              string `json:"Status,omitempty" metadata:",optional"`    	// 產品狀態 (active/decommissioned)
+	DecommissionReason  string `json:"DecommissionReason,omitempty" metadata:",optional"`	// 除役原因
+	DecommissionDate    string `json:"DecommissionDate,omitempty" metadata:",optional"`  	// 除役日期
 }
 
 // Part Project項目列表.
 type Part struct {
 	DocType             string `json:"docType"`             	// DocType is used to distinguish the various types of objects in state database
-	PID					string `json:"PID"`						// 零件唯ID
-	Manufacturer        string `json:"Manufacturer"`        	// 製造商
-	ManufactureLocation string `json:"ManufactureLocation"` 	// 製造地點
-	PartName            string `json:"PartName"`            	// 零件名稱
-	PartNumber          string `json:"PartNumber"`          	// 零件批號
-	Organization        string `json:"Organization"`        	// 組織
+	PID					string `json:"PID" validate:"required,pattern=partID"`						// 零件唯ID
+	Manufacturer        string `json:"Manufacturer" validate:"required,max=64"`        	// 製造商
+	ManufactureLocation string `json:"ManufactureLocation" validate:"required,max=64"` 	// 製造地點
+	PartName            string `json:"PartName" validate:"required,max=64"`            	// 零件名稱
+	PartNumber          string `json:"PartNumber" validate:"required,max=32,pattern=code"`          	// 零件批號
+	Organization        string `json:"Organization" validate:"required,organization"`        	// 組織
 	ManufactureDate     string `json:"ManufactureDate"`     	// 零件製造日期
 	TransferDate        string `json:"TransferDate"`        	// 零件交易日期
+	Status              string `json:"Status"`              	// 零件狀態 (available/installed/removed/scrapped/returned)
+	AssetID             string `json:"AssetID,omitempty" metadata:",optional"`   	// 安裝於之產品ID
+	OfferID             string `json:"OfferID,omitempty" metadata:",optional"`   	// 待處理之移轉要約ID
+	RepairOrderID       string `json:"RepairOrderID,omitempty" metadata:",optional"`	// 最後維修單號
+	DecommissionReason  string `json:"DecommissionReason,omitempty" metadata:",optional"`	// 報廢原因
+	DecommissionDate    string `json:"DecommissionDate,omitempty" metadata:",optional"`  	// 報廢日期
+	LotID               string `json:"LotID,omitempty" metadata:",optional" validate:"max=64,pattern=code"`	// 生產批次ID
 }
 
-// InitLedger adds a base set of assets to the ledger
+// Part lifecycle states. A part can be installed into an asset only while it is
+// available or removed; an installed part belongs to exactly one asset. Returned parts
+// were taken out of an asset by a repair and can only be transferred back to their maker,
+// where they become available again.
+const (
+	PartStatusAvailable = "available"
+	PartStatusInstalled = "installed"
+	PartStatusRemoved   = "removed"
+	PartStatusScrapped  = "scrapped"
+	PartStatusReturned  = "returned"
+)
+
+// Asset lifecycle states. Assets written before states were introduced have no status and are active.
+const (
+	AssetStatusActive         = "active"
+	AssetStatusDecommissioned = "decommissioned"
+)
+
+// InitLedger adds a base set of assets to the ledger. It bootstraps a new channel, so Brand-Org
+// creates the parts of every chip maker here, an exception to CreatePart where only the maker may
+// create its parts. It fails once the default product model or any of the parts exists, so it
+// cannot be used to create parts on a ledger in use.
 func (t *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	err := requireOrganization(ctx, BrandOrg)
+	if err != nil {
+		return err
+	}
+
 	parts := []Part{
 		{PID: "IVSLAB-S23FA0001", Manufacturer: "Security.Co", ManufactureLocation: "Taiwan", PartName: "SecurityChip-v1", PartNumber: "SPN3R1C00AA1", Organization: "Security-Org"},
 		{PID: "IVSLAB-N23FA0001", Manufacturer: "Network.Co", ManufactureLocation: "Taiwan", PartName: "NetworkChip-v1", PartNumber: "NPN3R1C00AA1", Organization: "Network-Org"},
@@ -65,14 +106,25 @@ func (t *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 		{PID: "IVSLAB-V23FA0003", Manufacturer: "VideoCodec.Co", ManufactureLocation: "USA", PartName: "VideoCodecChip-v1", PartNumber: "VPN3R1C00AA3", Organization: "VideoCodec-Org"},		
 	}
 
+	err = t.createProductModel(ctx, DefaultProductModelID, "IVSLAB PVC camera", defaultProductModelSlots)
+	if err != nil {
+		return err
+	}
+
+	var newParts []*Part
 	for _, part := range parts {
-		err := t.CreatePart(ctx, part.PID, part.Manufacturer, part.ManufactureLocation, part.PartName, part.PartNumber, part.Organization)
-		if err != nil {
-			return err
-		}
+		newParts = append(newParts, newPart(part.PID, part.Manufacturer, part.ManufactureLocation, part.PartName, part.PartNumber, part.Organization))
+	}
+	_, err = t.createParts(ctx, newParts, nil)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return emitEvent(ctx, &ContractEvent{
+		Name:       EventLedgerInitialized,
+		ObjectType: "part",
+		PartIDs:    partIDs(newParts),
+	})
 }
 
 // HistoryQueryResult structure used for returning result of history query
@@ -90,17 +142,38 @@ type PaginatedQueryResult struct {
 	Bookmark            string   `json:"bookmark"`
 }
 
-// CreatePart initializes a new part in the ledger
+// CreatePart initializes a new part in the ledger. Only the chip maker named by organization may call it.
 func (t *SmartContract) CreatePart(ctx contractapi.TransactionContextInterface, partID, manufacturer string, manufacturelocation string, partname string, partnumber string, organization string) error {
-	exists, err := t.PartExists(ctx, partID)
+	part := newPart(partID, manufacturer, manufacturelocation, partname, partnumber, organization)
+	err := validate(part)
 	if err != nil {
 		return err
 	}
-	if exists {
-		return fmt.Errorf("the part %s already exists", partID)
+	err = requireChipMaker(ctx, organization)
+	if err != nil {
+		return err
+	}
+	err = checkPartCategory(part)
+	if err != nil {
+		return err
 	}
 
-	part := &Part{
+	err = t.createPart(ctx, part)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, &ContractEvent{
+		Name:       EventPartCreated,
+		ObjectType: "part",
+		ObjectID:   partID,
+		NewOwner:   organization,
+	})
+}
+
+// newPart returns a new available part owned by organization
+func newPart(partID, manufacturer string, manufacturelocation string, partname string, partnumber string, organization string) *Part {
+	return &Part{
 		DocType:             "part",
 		PID:                 partID,
 		Manufacturer:        manufacturer,
@@ -108,14 +181,30 @@ func (t *SmartContract) CreatePart(ctx contractapi.TransactionContextInterface,
 		PartName:            partname,
 		PartNumber:          partnumber,
 		Organization:        organization,
-		ManufactureDate:     time.Now().Format("2006-01-02"),
+		Status:              PartStatusAvailable,
+	}
+}
+
+// createPart writes a new part and its organization index entry without checking the caller
+func (t *SmartContract) createPart(ctx contractapi.TransactionContextInterface, part *Part) error {
+	exists, err := t.PartExists(ctx, part.PID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return alreadyExists("the part %s already exists", part.PID)
+	}
+
+	part.ManufactureDate, err = getTxTimestamp(ctx)
+	if err != nil {
+		return err
 	}
 	partBytes, err := json.Marshal(part)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(partID, partBytes)
+	err = ctx.GetStub().PutState(partKey(part.PID), partBytes)
 	if err != nil {
 		return err
 	}
@@ -124,347 +213,478 @@ func (t *SmartContract) CreatePart(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 	value := []byte{0x00}
-	return ctx.GetStub().PutState(PartIndexKey, value)	
+	err = ctx.GetStub().PutState(PartIndexKey, value)
+	if err != nil {
+		return err
+	}
+	if part.LotID == "" {
+		return nil
+	}
+	lotIndexKey, err := ctx.GetStub().CreateCompositeKey(lotPartIndex, []string{part.LotID, part.PID})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(lotIndexKey, value)
 }
 
 // GetPart retrieves a part from the ledger by its ID
 func (t *SmartContract) GetPart(ctx contractapi.TransactionContextInterface, partID string) (*Part, error) {
-	partBytes, err := ctx.GetStub().GetState(partID)
+	partBytes, err := ctx.GetStub().GetState(partKey(partID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return nil, internalError("failed to read from world state: %v", err)
 	}
 	if partBytes == nil {
-		return nil, fmt.Errorf("the part %s does not exist", partID)
+		return nil, notFound("the part %s does not exist", partID)
 	}
 
 	part := new(Part)
 	err = json.Unmarshal(partBytes, part)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal part: %v", err)
+		return nil, internalError("failed to unmarshal part: %v", err)
+	}
+	if part.DocType != "part" {
+		return nil, notFound("%s is not a part", partID)
 	}
 
 	return part, nil
 }
 
-// CreateAsset initializes a new asset in the ledger
-func (t *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface, assetID string, madeby string, madein string, serialnumber string, securitychipID string, networkchipID string, cmoschipID string, videocodecchipID string) error {
-	exists, err := t.AssetExists(ctx, assetID)
+// CreateAsset initializes a new asset of a product model in the ledger. Every component
+// assigns a part to a slot of the model's bill of materials.
+func (t *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface, assetID string, modelID string, madeby string, madein string, serialnumber string, components []BOMItem) error {
+	err := requireOrganization(ctx, BrandOrg)
 	if err != nil {
 		return err
 	}
-	if exists {
-		return fmt.Errorf("the asset %s already exists", assetID)
+	asset := &Asset{
+		DocType:      "asset",
+		ID:           assetID,
+		MadeBy:       madeby,
+		MadeIn:       madein,
+		SerialNumber: serialnumber,
+		ModelID:      modelID,
 	}
-	var securitypart *Part
-	var networkpart *Part
-	var cmospart *Part
-	var videocodecpart *Part
-	// Get the Part instances from the ledger state
-	securitypart, err = t.GetPart(ctx, securitychipID)
+	err = validate(asset)
 	if err != nil {
 		return err
 	}
-	networkpart, err = t.GetPart(ctx, networkchipID)
+	exists, err := t.AssetExists(ctx, assetID)
 	if err != nil {
 		return err
 	}
-	cmospart, err = t.GetPart(ctx, cmoschipID)
+	if exists {
+		return alreadyExists("the asset %s already exists", assetID)
+	}
+	model, err := t.getAssetModel(ctx, modelID)
 	if err != nil {
 		return err
 	}
-	videocodecpart, err = t.GetPart(ctx, videocodecchipID)
+	// Get the Part instances from the ledger state and check them against the bill of materials
+	installed, err := t.resolveComponents(ctx, model, components)
 	if err != nil {
 		return err
-	}	
+	}
 	// Ensure all parts belong to 'Brand-Org'
-	parts := []*Part{securitypart, networkpart, cmospart, videocodecpart}
+	parts := componentParts(installed)
 	for _, part := range parts {
-		if part.Organization != "Brand-Org" {
-			return fmt.Errorf("part %s does not belong to Brand-Org, it belongs to %s", part.PID, part.Organization)
+		if part.Organization != BrandOrg {
+			return conflict("part %s does not belong to Brand-Org, it belongs to %s", part.PID, part.Organization)
 		}
 	}
-	asset := Asset{
-		DocType:        "asset",
-		ID:             assetID,
-		MadeBy:         madeby,
-		MadeIn:         madein,
-		SerialNumber:   serialnumber,
-		SecurityChip:   *securitypart,
-		NetworkChip:    *networkpart,
-		CMOSChip:       *cmospart,
-		VideoCodecChip: *videocodecpart,
-		ProductionDate: time.Now().Format("2006-01-02"),
+	// Ensure no part is already installed in another asset
+	err = checkPartsInstallable(assetID, parts)
+	if err != nil {
+		return err
+	}
+	actor, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
 	}
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	err = installParts(ctx, assetID, parts)
+	if err != nil {
+		return err
+	}
+	asset.Components = installed
+	asset.ProductionDate = now
+	asset.Status = AssetStatusActive
+	asset.Revision = 1
+	asset.UpdatedBy = actor
 	assetBytes, err := json.Marshal(asset)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(assetID, assetBytes)
+	err = ctx.GetStub().PutState(assetKey(assetID), assetBytes)
 	if err != nil {
 		return err
 	}
-	AssetIndexKey, err := ctx.GetStub().CreateCompositeKey(madeInSerialNumberIndex, []string{asset.MadeBy, asset.ID})
+	err = putAssetIndex(ctx, asset)
 	if err != nil {
 		return err
 	}
-	value := []byte{0x00}
-	return ctx.GetStub().PutState(AssetIndexKey, value)	
+
+	return emitEvent(ctx, &ContractEvent{
+		Name:       EventAssetCreated,
+		ObjectType: "asset",
+		ObjectID:   assetID,
+		PartIDs:    partIDs(parts),
+		NewOwner:   madeby,
+	})
 }
 
 // ReadPart retrieves an part from the ledger
 func (t *SmartContract) ReadPart(ctx contractapi.TransactionContextInterface, partID string) (*Part, error) {
-	partBytes, err := ctx.GetStub().GetState(partID)
+	partBytes, err := ctx.GetStub().GetState(partKey(partID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get part %s: %v", partID, err)
+		return nil, internalError("failed to get part %s: %v", partID, err)
 	}
 	if partBytes == nil {
-		return nil, fmt.Errorf("part %s does not exist", partID)
+		return nil, notFound("part %s does not exist", partID)
 	}
 
 	var part Part
 	err = json.Unmarshal(partBytes, &part)
 	if err != nil {
-		return nil, err
+		return nil, internalError("failed to unmarshal part %s: %v", partID, err)
+	}
+	if part.DocType != "part" {
+		return nil, notFound("%s is not a part", partID)
 	}
 
 	return &part, nil
 }
 // ReadAsset retrieves an asset from the ledger
 func (t *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, serialnumber string) (*Asset, error) {
-	assetBytes, err := ctx.GetStub().GetState(serialnumber)
+	assetBytes, err := ctx.GetStub().GetState(assetKey(serialnumber))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset %s: %v", serialnumber, err)
+		return nil, internalError("failed to get asset %s: %v", serialnumber, err)
 	}
 	if assetBytes == nil {
-		return nil, fmt.Errorf("asset %s does not exist", serialnumber)
+		return nil, notFound("asset %s does not exist", serialnumber)
 	}
 
 	var asset Asset
 	err = json.Unmarshal(assetBytes, &asset)
 	if err != nil {
-		return nil, err
+		return nil, internalError("failed to unmarshal asset %s: %v", serialnumber, err)
+	}
+	if asset.DocType != "asset" {
+		return nil, notFound("%s is not an asset", serialnumber)
 	}
+	normalizeAsset(&asset)
 
 	return &asset, nil
 }
 
-// UpdateAsset updates an existing asset in the world state with provided parameters.
-func (t *SmartContract) UpdateAsset(ctx contractapi.TransactionContextInterface, assetID string, madeby string, madein string, serialnumber string, securitychipID string, networkchipID string, cmoschipID string, videocodecchipID string) error {
-	exists, err := t.AssetExists(ctx, assetID)
+// UpdateAsset replaces the brand, location, serial number and bill of materials of an existing asset
+// in one step and records the change as a correction. The production date and product model are kept.
+// Prefer the patch operations UpdateAssetLocation, UpdateAssetSerialNumber and ReplaceAssetPart.
+func (t *SmartContract) UpdateAsset(ctx contractapi.TransactionContextInterface, assetID string, madeby string, madein string, serialnumber string, components []BOMItem) error {
+	oldAsset, err := t.beginAssetUpdate(ctx, assetID, ReasonCorrection, -1)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the asset %s does not exist", assetID)
-	}
-	var securitypart *Part
-	var networkpart *Part
-	var cmospart *Part
-	var videocodecpart *Part
-	// Get the Part instances from the ledger state
-	securitypart, err = t.GetPart(ctx, securitychipID)
+	asset := *oldAsset
+	asset.MadeBy = madeby
+	asset.MadeIn = madein
+	asset.SerialNumber = serialnumber
+	err = validate(&asset)
 	if err != nil {
 		return err
 	}
-	networkpart, err = t.GetPart(ctx, networkchipID)
+	err = t.replaceComponents(ctx, oldAsset, &asset, components, PartStatusRemoved, "")
 	if err != nil {
 		return err
 	}
-	cmospart, err = t.GetPart(ctx, cmoschipID)
+
+	return commitAssetUpdate(ctx, oldAsset, &asset, ReasonCorrection, EventAssetUpdated)
+}
+
+// DeletePart scraps a part. The part stays on the ledger with the reason and date of its
+// deletion, so that it can still be traced; installed or offered parts cannot be deleted.
+// Use PurgePart to remove a part registered by mistake.
+func (t *SmartContract) DeletePart(ctx contractapi.TransactionContextInterface, partID string, reason string) error {
+	part, err := t.ReadPart(ctx, partID)
 	if err != nil {
 		return err
 	}
-	videocodecpart, err = t.GetPart(ctx, videocodecchipID)
+	err = requireOrganization(ctx, part.Organization)
 	if err != nil {
 		return err
 	}
-	// Ensure all parts belong to 'Brand-Org'
-	parts := []*Part{securitypart, networkpart, cmospart, videocodecpart}
-	for _, part := range parts {
-		if part.Organization != "Brand-Org" {
-			return fmt.Errorf("part %s does not belong to Brand-Org, failed to update asset %s", part.PID, assetID)
-		}
+	err = validateField("reason", reason, "required,max=256")
+	if err != nil {
+		return err
 	}
-	// overwriting original asset with new asset
-	asset := &Asset{
-		DocType:        "asset",
-		ID:              assetID,
-		MadeBy:          madeby,
-		MadeIn:          madein,
-		SerialNumber:    serialnumber,
-		SecurityChip:    *securitypart,
-		NetworkChip:     *networkpart,
-		CMOSChip:        *cmospart,
-		VideoCodecChip:  *videocodecpart,
-		ProductionDate:  time.Now().Format("2006-01-02"),
-		Updated:  		 time.Now().Format("2006-01-02"),
+	switch {
+	case part.Status == PartStatusInstalled:
+		return conflict("part %s is installed in asset %s and cannot be deleted", partID, part.AssetID)
+	case part.OfferID != "":
+		return conflict("part %s is offered in transfer %s and cannot be deleted", partID, part.OfferID)
+	case part.Status == PartStatusScrapped:
+		return conflict("part %s is already scrapped", partID)
 	}
-	assetBytes, err := json.Marshal(asset)
+	now, err := getTxTimestamp(ctx)
 	if err != nil {
 		return err
 	}
-
-	err = ctx.GetStub().PutState(assetID, assetBytes)
+	part.Status = PartStatusScrapped
+	part.DecommissionReason = reason
+	part.DecommissionDate = now
+	partBytes, err := json.Marshal(part)
 	if err != nil {
 		return err
 	}
-	AssetIndexKey, err := ctx.GetStub().CreateCompositeKey(madeInSerialNumberIndex, []string{asset.MadeBy, asset.ID})
+	err = ctx.GetStub().PutState(partKey(partID), partBytes)
 	if err != nil {
-		return err
+		return internalError("failed to delete part %s: %v", partID, err)
 	}
-	value := []byte{0x00}
-	return ctx.GetStub().PutState(AssetIndexKey, value)	
+
+	return emitEvent(ctx, &ContractEvent{
+		Name:       EventPartDeleted,
+		ObjectType: "part",
+		ObjectID:   partID,
+		OldOwner:   part.Organization,
+		Reason:     reason,
+	})
 }
 
-// DeletePart removes an part key-value pair from the ledger
-func (t *SmartContract) DeletePart(ctx contractapi.TransactionContextInterface, partID string) error {
-	part, err := t.ReadPart(ctx, partID)
+// DeleteAsset decommissions an asset. The parts installed in the asset are marked as removed,
+// or as scrapped when scrapParts is true, and the asset stays on the ledger without components,
+// together with the reason and date of its decommissioning. Use PurgeAsset to remove an asset
+// created by mistake.
+func (t *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface, assetID string, scrapParts bool, reason string) error {
+	err := requireOrganization(ctx, BrandOrg)
 	if err != nil {
 		return err
 	}
-	err = ctx.GetStub().DelState(partID)
+	err = validateField("reason", reason, "required,max=256")
 	if err != nil {
-		return fmt.Errorf("failed to delete part %s: %v", partID, err)
+		return err
 	}
-
-	PartIndexKey, err := ctx.GetStub().CreateCompositeKey(manufacturerPartIndex, []string{part.Organization, part.PID})
+	asset, err := t.ReadAsset(ctx, assetID)
 	if err != nil {
 		return err
 	}
+	if asset.Status == AssetStatusDecommissioned {
+		return conflict("asset %s is already decommissioned", assetID)
+	}
+	status := PartStatusRemoved
+	if scrapParts {
+		status = PartStatusScrapped
+	}
+	released := partIDs(assetParts(asset))
+	for _, partID := range released {
+		err = releasePart(ctx, assetID, partID, status, "")
+		if err != nil {
+			return err
+		}
+	}
 
-	// Delete index entry
-	return ctx.GetStub().DelState(PartIndexKey)
-}
-
-// DeleteAsset removes an asset key-value pair from the ledger
-func (t *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface, assetID string) error {
-	asset, err := t.ReadAsset(ctx, assetID)
+	actor, err := getClientIdentity(ctx)
 	if err != nil {
 		return err
 	}
-	err = ctx.GetStub().DelState(assetID)
+	now, err := getTxTimestamp(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to delete asset %s: %v", assetID, err)
+		return err
 	}
-
-	AssetIndexKey, err := ctx.GetStub().CreateCompositeKey(madeInSerialNumberIndex, []string{asset.MadeBy, asset.ID})
+	asset.Status = AssetStatusDecommissioned
+	asset.DecommissionReason = reason
+	asset.DecommissionDate = now
+	asset.Components = []Component{}
+	asset.Revision++
+	asset.Updated = now
+	asset.UpdatedBy = actor
+	asset.UpdateReason = ReasonDecommission
+	assetBytes, err := json.Marshal(asset)
 	if err != nil {
 		return err
 	}
-
-	// Delete index entry
-	return ctx.GetStub().DelState(AssetIndexKey)
-}
-
-// TransferPart updates the Organization and TransferDate field of part with given id in world state, and returns the old Organization.
-func (t *SmartContract) TransferPart(ctx contractapi.TransactionContextInterface, partID string, newOrganization string) (string, error) {
-	part, err := t.ReadPart(ctx, partID)
+	err = ctx.GetStub().PutState(assetKey(assetID), assetBytes)
 	if err != nil {
-		return "", fmt.Errorf("failed to read part: %v", err)
+		return internalError("failed to decommission asset %s: %v", assetID, err)
 	}
 
-	oldOrganization := part.Organization
-	part.Organization = newOrganization
-
-	// Set the transfer date to the current system date
-	part.TransferDate = time.Now().Format("2006-01-02")
+	return emitEvent(ctx, &ContractEvent{
+		Name:       EventAssetDeleted,
+		ObjectType: "asset",
+		ObjectID:   assetID,
+		PartIDs:    released,
+		OldOwner:   asset.MadeBy,
+		Reason:     reason,
+	})
+}
 
-	partBytes, err := json.Marshal(part)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal part: %v", err)
-	}
+// TransferPart offers a single part to newOrganization and returns the ID of the transfer offer.
+// The part changes owner once newOrganization calls AcceptPartTransfer.
+func (t *SmartContract) TransferPart(ctx contractapi.TransactionContextInterface, partID string, newOrganization string) (string, error) {
+	return t.OfferPartTransfer(ctx, []string{partID}, newOrganization, 0)
+}
 
-	err = ctx.GetStub().PutState(partID, partBytes)
+// TransferPartsByOrganization offers all transferable parts of an organization to another organization
+// in a single transfer offer and returns its ID. Installed, scrapped and already offered parts are skipped.
+func (t *SmartContract) TransferPartsByOrganization(ctx contractapi.TransactionContextInterface, organization, newOrganization string) (string, error) {
+	err := requireOrganization(ctx, organization)
 	if err != nil {
-		return "", fmt.Errorf("failed to write part: %v", err)
+		return "", err
 	}
 
-	return oldOrganization, nil
-}
-// TransferPartsByOrganization transfers all parts from one organization to another
-func (t *SmartContract) TransferPartsByOrganization(ctx contractapi.TransactionContextInterface, organization, newOrganization string) error {
 	// Query the state by the old organization
 	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(manufacturerPartIndex, []string{organization})
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resultsIterator.Close()
 
+	var partIDs []string
 	// Iterate through the results
 	for resultsIterator.HasNext() {
-		// Get the current part
 		queryResponse, err := resultsIterator.Next()
 		if err != nil {
-			return err
+			return "", err
 		}
 
 		// Get the part ID from the composite key
 		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
 		if err != nil {
-			return err
+			return "", err
 		}
-		partID := compositeKeyParts[1]
-
-		// Get the part from the state
-		partBytes, err := ctx.GetStub().GetState(partID)
+		part, err := t.ReadPart(ctx, compositeKeyParts[1])
 		if err != nil {
-			return err
+			return "", err
 		}
+		if part.OfferID != "" || part.Status == PartStatusInstalled || part.Status == PartStatusScrapped {
+			continue
+		}
+		partIDs = append(partIDs, part.PID)
+	}
+	if len(partIDs) == 0 {
+		return "", notFound("organization %s has no parts that can be transferred", organization)
+	}
 
-		part := new(Part)
-		err = json.Unmarshal(partBytes, part)
-		if err != nil {
-			return err
+	return t.OfferPartTransfer(ctx, partIDs, newOrganization, 0)
+}
+
+// assetParts returns the parts embedded in an asset
+func assetParts(asset *Asset) []*Part {
+	return componentParts(asset.Components)
+}
+
+// componentParts returns pointers to the parts of the components, so that changes to
+// the parts are reflected in the components
+func componentParts(components []Component) []*Part {
+	parts := make([]*Part, 0, len(components))
+	for i := range components {
+		parts = append(parts, &components[i].Part)
+	}
+	return parts
+}
+
+// partIDs returns the IDs of the given parts
+func partIDs(parts []*Part) []string {
+	ids := make([]string, 0, len(parts))
+	for _, part := range parts {
+		ids = append(ids, part.PID)
+	}
+	return ids
+}
+
+// checkPartsInstallable returns an error if a part is listed twice, is scrapped, is part of a
+// pending transfer offer, or is already installed in an asset other than assetID.
+func checkPartsInstallable(assetID string, parts []*Part) error {
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		if seen[part.PID] {
+			return invalidArgument("components", "part %s is listed more than once for asset %s", part.PID, assetID)
 		}
+		seen[part.PID] = true
 
-		// Change the organization of the part
-		part.Organization = newOrganization
-		part.TransferDate = time.Now().Format("2006-01-02")
-		
-		// Write the part back to the state
-		partBytes, err = json.Marshal(part)
-		if err != nil {
-			return err
+		if part.OfferID != "" {
+			return conflict("part %s is part of pending transfer offer %s and cannot be installed", part.PID, part.OfferID)
 		}
-		err = ctx.GetStub().PutState(partID, partBytes)
-		if err != nil {
-			return err
+		switch part.Status {
+		case PartStatusScrapped, PartStatusReturned:
+			return conflict("part %s is %s and cannot be installed", part.PID, part.Status)
+		case PartStatusInstalled:
+			if part.AssetID != assetID {
+				return conflict("part %s is already installed in asset %s", part.PID, part.AssetID)
+			}
 		}
+	}
 
-		// Delete the old index
-		err = ctx.GetStub().DelState(queryResponse.Key)
-		if err != nil {
-			return err
-		}
+	return nil
+}
+
+// installParts marks the parts as installed in assetID and writes them back to the world state
+func installParts(ctx contractapi.TransactionContextInterface, assetID string, parts []*Part) error {
+	for _, part := range parts {
+		part.Status = PartStatusInstalled
+		part.AssetID = assetID
 
-		// Create a new index
-		PartIndexKey, err := ctx.GetStub().CreateCompositeKey(manufacturerPartIndex, []string{newOrganization, partID})
+		partBytes, err := json.Marshal(part)
 		if err != nil {
 			return err
 		}
-		value := []byte{0x00}
-		err = ctx.GetStub().PutState(PartIndexKey, value)
+		err = ctx.GetStub().PutState(partKey(part.PID), partBytes)
 		if err != nil {
-			return err
+			return internalError("failed to install part %s: %v", part.PID, err)
 		}
 	}
 
 	return nil
 }
 
+// releasePart takes a part out of assetID and sets its status to removed, scrapped or returned.
+// A non-empty repairOrderID records the repair that took the part out. Parts that no longer
+// exist or that are not installed in assetID are left untouched.
+func releasePart(ctx contractapi.TransactionContextInterface, assetID string, partID string, status string, repairOrderID string) error {
+	partBytes, err := ctx.GetStub().GetState(partKey(partID))
+	if err != nil {
+		return internalError("failed to read part %s: %v", partID, err)
+	}
+	if partBytes == nil {
+		return nil
+	}
+
+	var part Part
+	err = json.Unmarshal(partBytes, &part)
+	if err != nil {
+		return internalError("failed to unmarshal part %s: %v", partID, err)
+	}
+	if part.AssetID != assetID {
+		return nil
+	}
+
+	part.Status = status
+	part.AssetID = ""
+	if repairOrderID != "" {
+		part.RepairOrderID = repairOrderID
+	}
+	partBytes, err = json.Marshal(part)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(partKey(partID), partBytes)
+}
+
 // constructQueryResponseFromIteratorPart constructs a slice of parts from the resultsIterator
 func constructQueryResponseFromIteratorPart(resultsIterator shim.StateQueryIteratorInterface) ([]*Part, error) {
 	var parts []*Part
 	for resultsIterator.HasNext() {
 		queryResult, err := resultsIterator.Next()
 		if err != nil {
-			return nil, err
+			return nil, internalError("failed to read parts: %v", err)
 		}
 		var part Part
 		err = json.Unmarshal(queryResult.Value, &part)
 		if err != nil {
-			return nil, err
+			return nil, internalError("failed to unmarshal %s: %v", queryResult.Key, err)
 		}
 		parts = append(parts, &part)
 	}
@@ -483,13 +703,14 @@ func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorI
 	for resultsIterator.HasNext() {
 		queryResult, err := resultsIterator.Next()
 		if err != nil {
-			return nil, err
+			return nil, internalError("failed to read assets: %v", err)
 		}
 		var asset Asset
 		err = json.Unmarshal(queryResult.Value, &asset)
 		if err != nil {
-			return nil, err
+			return nil, internalError("failed to unmarshal %s: %v", queryResult.Key, err)
 		}
+		normalizeAsset(&asset)
 		assets = append(assets, &asset)
 	}
 
@@ -503,18 +724,33 @@ func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorI
 
 // GetAllParts returns all parts found in world state
 func (t *SmartContract) GetAllParts(ctx contractapi.TransactionContextInterface) ([]*Part, error) {
-	// range query with empty string for startKey and endKey does an
+	// range query over the whole part key prefix does an
 	// open-ended query of all parts in the chaincode namespace.
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	startKey, endKey := keyRange(partKeyPrefix, "", "")
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
 	if err != nil {
-		return nil, err
+		return nil, internalError("failed to read parts: %v", err)
 	}
 	defer resultsIterator.Close()
 
 	return constructQueryResponseFromIteratorPart(resultsIterator)
 }
 
-func (t *SmartContract) GetPartsByRange(ctx contractapi.TransactionContextInterface, startKey, endKey string) ([]*Part, error) {
+// GetAllAssets returns all assets found in world state
+func (t *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface) ([]*Asset, error) {
+	startKey, endKey := keyRange(assetKeyPrefix, "", "")
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, internalError("failed to read assets: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	return constructQueryResponseFromIterator(resultsIterator)
+}
+
+// GetPartsByRange returns the parts with IDs in [startID, endID), an empty ID leaves that side open
+func (t *SmartContract) GetPartsByRange(ctx contractapi.TransactionContextInterface, startID, endID string) ([]*Part, error) {
+	startKey, endKey := keyRange(partKeyPrefix, startID, endID)
 	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
 	if err != nil {
 		return nil, err
@@ -544,12 +780,17 @@ func (t *SmartContract) GetPartsByRange(ctx contractapi.TransactionContextInterf
 //   return constructQueryResponseFromIterator(resultsIterator)
 //}
 
+// QueryAssetsBySerialNumber returns the assets with serial numbers in [startSerialNumber, endSerialNumber]
 func (t *SmartContract) QueryAssetsBySerialNumber(ctx contractapi.TransactionContextInterface, startSerialNumber, endSerialNumber string) ([]*Asset, error) {
-	queryString := fmt.Sprintf(`{"selector":{"SerialNumber":{"$gte":"%s", "$lte":"%s"}}}`, startSerialNumber, endSerialNumber)
-	return getQueryResultForQueryString(ctx, queryString)
+	return searchAssets(ctx, AssetQuery{SerialNumberFrom: startSerialNumber, SerialNumberTo: endSerialNumber, SortBy: "SerialNumber"})
 }
 
+// QueryAssets runs a raw CouchDB query. Only admins may call it, other clients use SearchAssets.
 func (t *SmartContract) QueryAssets(ctx contractapi.TransactionContextInterface, queryString string) ([]*Asset, error) {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return getQueryResultForQueryString(ctx, queryString)
 }
 
@@ -565,8 +806,9 @@ func getQueryResultForQueryString(ctx contractapi.TransactionContextInterface, q
 	return constructQueryResponseFromIterator(resultsIterator)
 }
 
-func (t *SmartContract) GetAssetsByRangeWithPagination(ctx contractapi.TransactionContextInterface, startKey string, endKey string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
-
+// GetAssetsByRangeWithPagination returns a page of the assets with IDs in [startID, endID)
+func (t *SmartContract) GetAssetsByRangeWithPagination(ctx contractapi.TransactionContextInterface, startID string, endID string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	startKey, endKey := keyRange(assetKeyPrefix, startID, endID)
 	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination(startKey, endKey, int32(pageSize), bookmark)
 	if err != nil {
 		return nil, err
@@ -585,8 +827,13 @@ func (t *SmartContract) GetAssetsByRangeWithPagination(ctx contractapi.Transacti
 	}, nil
 }
 
+// QueryAssetsWithPagination runs a raw CouchDB query page by page. Only admins may call it,
+// other clients use SearchAssetsWithPagination.
 func (t *SmartContract) QueryAssetsWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
-
+	err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return getQueryResultForQueryStringWithPagination(ctx, queryString, int32(pageSize), bookmark)
 }
 
@@ -613,7 +860,7 @@ func getQueryResultForQueryStringWithPagination(ctx contractapi.TransactionConte
 func (t *SmartContract) GetAssetHistory(ctx contractapi.TransactionContextInterface, assetID string) ([]HistoryQueryResult, error) {
 	log.Printf("GetAssetHistory: ID %v", assetID)
 
-	resultsIterator, err := ctx.GetStub().GetHistoryForKey(assetID)
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(assetKey(assetID))
 	if err != nil {
 		return nil, err
 	}
@@ -632,9 +879,11 @@ func (t *SmartContract) GetAssetHistory(ctx contractapi.TransactionContextInterf
 			if err != nil {
 				return nil, err
 			}
+			normalizeAsset(&asset)
 		} else {
 			asset = Asset{
-				ID: assetID,
+				ID:         assetID,
+				Components: []Component{},
 			}
 		}
 
@@ -655,11 +904,37 @@ func (t *SmartContract) GetAssetHistory(ctx contractapi.TransactionContextInterf
 	return records, nil
 }
 
+// getTxTimestamp returns the transaction timestamp chosen by the submitting client,
+// formatted as an RFC3339 instant. It is identical on every endorsing peer, so all
+// date fields written to the ledger must use it instead of the peer's wall clock.
+func getTxTimestamp(ctx contractapi.TransactionContextInterface) (string, error) {
+	timestamp, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return timestamp.Format(time.RFC3339), nil
+}
+
+// getTxTime returns the transaction timestamp as a UTC time.Time
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, internalError("failed to get transaction timestamp: %v", err)
+	}
+	timestamp, err := ptypes.Timestamp(txTimestamp)
+	if err != nil {
+		return time.Time{}, internalError("failed to convert transaction timestamp: %v", err)
+	}
+
+	return timestamp.UTC(), nil
+}
+
 // PartExists returns true when part with given ID exists in world state
 func (t *SmartContract) PartExists(ctx contractapi.TransactionContextInterface, partID string) (bool, error) {
-	partBytes, err := ctx.GetStub().GetState(partID)
+	partBytes, err := ctx.GetStub().GetState(partKey(partID))
 	if err != nil {
-		return false, fmt.Errorf("failed to read part %s from world state. %v", partID, err)
+		return false, internalError("failed to read part %s from world state. %v", partID, err)
 	}
 
 	return partBytes != nil, nil
@@ -667,9 +942,9 @@ func (t *SmartContract) PartExists(ctx contractapi.TransactionContextInterface,
 
 // AssetExists returns true when asset with given ID exists in world state
 func (t *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface, assetID string) (bool, error) {
-	assetBytes, err := ctx.GetStub().GetState(assetID)
+	assetBytes, err := ctx.GetStub().GetState(assetKey(assetID))
 	if err != nil {
-		return false, fmt.Errorf("failed to read asset %s from world state. %v", assetID, err)
+		return false, internalError("failed to read asset %s from world state. %v", assetID, err)
 	}
 
 	return assetBytes != nil, nil
@@ -0,0 +1,626 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	securityMSP   = "securityMSP"
+	networkMSP    = "networkMSP"
+	cmosMSP       = "cmosMSP"
+	videocodecMSP = "videocodecMSP"
+	brandMSP      = "brandMSP"
+)
+
+// adminAttributes are the certificate attributes of an admin identity
+var adminAttributes = map[string]string{adminAttribute: "true"}
+
+// initLedger seeds the ledger with the InitLedger parts
+func initLedger(t *testing.T, l *testLedger) {
+	t.Helper()
+	err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.InitLedger(ctx)
+	})
+	if err != nil {
+		t.Fatalf("InitLedger failed: %v", err)
+	}
+}
+
+// moveToBrand transfers a part from the chip maker with mspID to Brand-Org
+func moveToBrand(t *testing.T, l *testLedger, mspID string, partID string) {
+	t.Helper()
+	var offerID string
+	err := l.submit(mspID, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		offerID, err = l.contract.TransferPart(ctx, partID, BrandOrg)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("TransferPart %s failed: %v", partID, err)
+	}
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.AcceptPartTransfer(ctx, offerID)
+	})
+	if err != nil {
+		t.Fatalf("AcceptPartTransfer %s failed: %v", offerID, err)
+	}
+}
+
+// moveSetToBrand transfers the four chips with the given number suffix (e.g. "0001") to Brand-Org
+func moveSetToBrand(t *testing.T, l *testLedger, suffix string) {
+	t.Helper()
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA"+suffix)
+	moveToBrand(t, l, networkMSP, "IVSLAB-N23FA"+suffix)
+	moveToBrand(t, l, cmosMSP, "IVSLAB-C23FA"+suffix)
+	moveToBrand(t, l, videocodecMSP, "IVSLAB-V23FA"+suffix)
+}
+
+// chipSet returns the bill of materials of the default product model for the four chips
+func chipSet(securityChipID, networkChipID, cmosChipID, videoCodecChipID string) []BOMItem {
+	return []BOMItem{
+		{Slot: SlotSecurityChip, PartID: securityChipID},
+		{Slot: SlotNetworkChip, PartID: networkChipID},
+		{Slot: SlotCMOSChip, PartID: cmosChipID},
+		{Slot: SlotVideoCodecChip, PartID: videoCodecChipID},
+	}
+}
+
+// createAsset creates an asset of the default product model from the four chips with the given number suffix
+func createAsset(l *testLedger, assetID, serialNumber, suffix string) error {
+	return l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CreateAsset(ctx, assetID, DefaultProductModelID, "Brand.Co", "Taiwan", serialNumber,
+			chipSet("IVSLAB-S23FA"+suffix, "IVSLAB-N23FA"+suffix, "IVSLAB-C23FA"+suffix, "IVSLAB-V23FA"+suffix))
+	})
+}
+
+// component returns the part an asset has installed in slot, failing the test if there is none
+func component(t *testing.T, asset *Asset, slot string) Part {
+	t.Helper()
+	for _, c := range asset.Components {
+		if c.Slot == slot {
+			return c.Part
+		}
+	}
+	t.Fatalf("asset %s has no component in slot %s", asset.ID, slot)
+	return Part{}
+}
+
+// readPart reads a part, failing the test if it cannot be read
+func readPart(t *testing.T, l *testLedger, partID string) *Part {
+	t.Helper()
+	var part *Part
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		part, err = l.contract.ReadPart(ctx, partID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ReadPart %s failed: %v", partID, err)
+	}
+	return part
+}
+
+// readAsset reads an asset, failing the test if it cannot be read
+func readAsset(t *testing.T, l *testLedger, assetID string) *Asset {
+	t.Helper()
+	var asset *Asset
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		asset, err = l.contract.ReadAsset(ctx, assetID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ReadAsset %s failed: %v", assetID, err)
+	}
+	return asset
+}
+
+// expectError fails the test unless err is non-nil and contains want
+func expectError(t *testing.T, err error, want string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected error containing %q, got nil", want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected error containing %q, got %q", want, err.Error())
+	}
+}
+
+// expectEvent fails the test unless the last committed event has the given name and object ID
+func expectEvent(t *testing.T, l *testLedger, name string, objectID string) *ContractEvent {
+	t.Helper()
+	last := l.stub.lastEvent()
+	if last == nil {
+		t.Fatalf("expected event %s, got none", name)
+	}
+	if last.Name != name {
+		t.Fatalf("expected event %s, got %s", name, last.Name)
+	}
+	var event ContractEvent
+	if err := json.Unmarshal(last.Payload, &event); err != nil {
+		t.Fatalf("failed to unmarshal event payload: %v", err)
+	}
+	if event.Version != EventVersion || event.Name != name || event.ObjectID != objectID || event.TxID != last.TxID {
+		t.Fatalf("unexpected %s event payload %s", name, last.Payload)
+	}
+	return &event
+}
+
+func TestInitLedger(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	part := readPart(t, l, "IVSLAB-S23FA0001")
+	if part.Organization != SecurityOrg || part.Status != PartStatusAvailable || part.DocType != "part" {
+		t.Fatalf("unexpected part %+v", part)
+	}
+	if part.ManufactureDate != "2023-05-15T08:01:00Z" {
+		t.Fatalf("expected ManufactureDate from the transaction timestamp, got %s", part.ManufactureDate)
+	}
+	event := expectEvent(t, l, EventLedgerInitialized, "")
+	if len(event.PartIDs) != 12 {
+		t.Fatalf("expected 12 parts in the event, got %d", len(event.PartIDs))
+	}
+
+	err := l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.InitLedger(ctx)
+	})
+	expectError(t, err, "FORBIDDEN")
+
+	// the bootstrap exception does not apply to a ledger already initialized
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.InitLedger(ctx)
+	})
+	expectErrorCode(t, err, ErrCodeAlreadyExists)
+}
+
+func TestCreatePart(t *testing.T) {
+	l := newTestLedger()
+	err := l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CreatePart(ctx, "IVSLAB-N23FA0004", "Network.Co", "Taiwan", "NetworkChip-v1", "NPN3R1C00AA4", NetworkOrg)
+	})
+	if err != nil {
+		t.Fatalf("CreatePart failed: %v", err)
+	}
+	event := expectEvent(t, l, EventPartCreated, "IVSLAB-N23FA0004")
+	if event.NewOwner != NetworkOrg {
+		t.Fatalf("expected new owner %s, got %s", NetworkOrg, event.NewOwner)
+	}
+
+	part := readPart(t, l, "IVSLAB-N23FA0004")
+	if part.PartNumber != "NPN3R1C00AA4" || part.Status != PartStatusAvailable {
+		t.Fatalf("unexpected part %+v", part)
+	}
+
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CreatePart(ctx, "IVSLAB-N23FA0004", "Network.Co", "Taiwan", "NetworkChip-v1", "NPN3R1C00AA4", NetworkOrg)
+	})
+	expectError(t, err, "already exists")
+}
+
+func TestGetPart(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		part, err := l.contract.GetPart(ctx, "IVSLAB-C23FA0002")
+		if err != nil {
+			return err
+		}
+		if part.Manufacturer != "CMOS.Co" {
+			t.Fatalf("unexpected part %+v", part)
+		}
+		_, err = l.contract.GetPart(ctx, "IVSLAB-C23FA9999")
+		expectError(t, err, "does not exist")
+		_, err = l.contract.ReadPart(ctx, "IVSLAB-C23FA9999")
+		expectError(t, err, "does not exist")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GetPart failed: %v", err)
+	}
+}
+
+func TestPartAndAssetExists(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		for id, want := range map[string]bool{"IVSLAB-S23FA0001": true, "IVSLAB-S23FA9999": false} {
+			exists, err := l.contract.PartExists(ctx, id)
+			if err != nil || exists != want {
+				t.Fatalf("PartExists(%s) = %v, %v; want %v", id, exists, err, want)
+			}
+		}
+		for id, want := range map[string]bool{"IVSLAB-PVC23FG0001": true, "IVSLAB-PVC23FG9999": false} {
+			exists, err := l.contract.AssetExists(ctx, id)
+			if err != nil || exists != want {
+				t.Fatalf("AssetExists(%s) = %v, %v; want %v", id, exists, err, want)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateAsset(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001")
+	expectError(t, err, "does not belong to Brand-Org")
+
+	moveSetToBrand(t, l, "0001")
+	err = createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001")
+	if err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	event := expectEvent(t, l, EventAssetCreated, "IVSLAB-PVC23FG0001")
+	if len(event.PartIDs) != 4 {
+		t.Fatalf("expected 4 parts in the event, got %v", event.PartIDs)
+	}
+
+	asset := readAsset(t, l, "IVSLAB-PVC23FG0001")
+	securityChip := component(t, asset, SlotSecurityChip)
+	if asset.SerialNumber != "IVSPN902300AACDC01" || asset.ModelID != DefaultProductModelID || securityChip.PID != "IVSLAB-S23FA0001" {
+		t.Fatalf("unexpected asset %+v", asset)
+	}
+	if securityChip.Status != PartStatusInstalled || securityChip.AssetID != "IVSLAB-PVC23FG0001" {
+		t.Fatalf("expected embedded part to be installed, got %+v", securityChip)
+	}
+	part := readPart(t, l, "IVSLAB-N23FA0001")
+	if part.Status != PartStatusInstalled || part.AssetID != "IVSLAB-PVC23FG0001" {
+		t.Fatalf("expected part to be installed, got %+v", part)
+	}
+
+	err = createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001")
+	expectError(t, err, "already exists")
+
+	// the same chips cannot be installed in a second camera
+	err = createAsset(l, "IVSLAB-PVC23FG0002", "IVSPN902300AACDC02", "0001")
+	expectError(t, err, "already installed in asset IVSLAB-PVC23FG0001")
+
+	moveSetToBrand(t, l, "0002")
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CreateAsset(ctx, "IVSLAB-PVC23FG0002", DefaultProductModelID, "Brand.Co", "Taiwan", "IVSPN902300AACDC02",
+			chipSet("IVSLAB-S23FA0002", "IVSLAB-S23FA0002", "IVSLAB-C23FA0002", "IVSLAB-V23FA0002"))
+	})
+	expectError(t, err, "listed more than once")
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CreateAsset(ctx, "IVSLAB-PVC23FG0002", DefaultProductModelID, "Brand.Co", "Taiwan", "IVSPN902300AACDC02",
+			chipSet("IVSLAB-S23FA9999", "IVSLAB-N23FA0002", "IVSLAB-C23FA0002", "IVSLAB-V23FA0002"))
+	})
+	expectError(t, err, "does not exist")
+}
+
+func TestUpdateAsset(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA0002")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FG0001", "Brand.Co", "Vietnam", "IVSPN902300AACDC01",
+			chipSet("IVSLAB-S23FA0002", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001"))
+	})
+	if err != nil {
+		t.Fatalf("UpdateAsset failed: %v", err)
+	}
+	expectEvent(t, l, EventAssetUpdated, "IVSLAB-PVC23FG0001")
+
+	asset := readAsset(t, l, "IVSLAB-PVC23FG0001")
+	if asset.MadeIn != "Vietnam" || component(t, asset, SlotSecurityChip).PID != "IVSLAB-S23FA0002" || asset.Updated == "" {
+		t.Fatalf("unexpected asset %+v", asset)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0001"); part.Status != PartStatusRemoved || part.AssetID != "" {
+		t.Fatalf("expected replaced part to be removed, got %+v", part)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0002"); part.Status != PartStatusInstalled {
+		t.Fatalf("expected new part to be installed, got %+v", part)
+	}
+	if part := readPart(t, l, "IVSLAB-N23FA0001"); part.Status != PartStatusInstalled || part.AssetID != "IVSLAB-PVC23FG0001" {
+		t.Fatalf("expected kept part to stay installed, got %+v", part)
+	}
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FG9999", "Brand.Co", "Taiwan", "IVSPN902300AACDC09",
+			chipSet("IVSLAB-S23FA0002", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001"))
+	})
+	expectError(t, err, "does not exist")
+}
+
+func TestDeleteAsset(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	moveSetToBrand(t, l, "0002")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	if err := createAsset(l, "IVSLAB-PVC23FG0002", "IVSPN902300AACDC02", "0002"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeleteAsset(ctx, "IVSLAB-PVC23FG0001", false, "end of life")
+	})
+	if err != nil {
+		t.Fatalf("DeleteAsset failed: %v", err)
+	}
+	if event := expectEvent(t, l, EventAssetDeleted, "IVSLAB-PVC23FG0001"); len(event.PartIDs) != 4 || event.Reason != "end of life" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if part := readPart(t, l, "IVSLAB-C23FA0001"); part.Status != PartStatusRemoved {
+		t.Fatalf("expected part to be removed, got %+v", part)
+	}
+	// the asset stays on the ledger as a tombstone without components
+	asset := readAsset(t, l, "IVSLAB-PVC23FG0001")
+	if asset.Status != AssetStatusDecommissioned || asset.DecommissionReason != "end of life" || asset.DecommissionDate == "" || len(asset.Components) != 0 {
+		t.Fatalf("expected a decommissioned asset, got %+v", asset)
+	}
+	if asset.Revision != 2 || asset.UpdateReason != ReasonDecommission {
+		t.Fatalf("expected the decommissioning to be recorded, got %+v", asset)
+	}
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeleteAsset(ctx, "IVSLAB-PVC23FG0002", true, "water damage")
+	})
+	if err != nil {
+		t.Fatalf("DeleteAsset failed: %v", err)
+	}
+	if part := readPart(t, l, "IVSLAB-C23FA0002"); part.Status != PartStatusScrapped {
+		t.Fatalf("expected part to be scrapped, got %+v", part)
+	}
+
+	// removed parts can be reused, scrapped ones cannot
+	if err := createAsset(l, "IVSLAB-PVC23FG0003", "IVSPN902300AACDC03", "0001"); err != nil {
+		t.Fatalf("CreateAsset with removed parts failed: %v", err)
+	}
+	err = createAsset(l, "IVSLAB-PVC23FG0004", "IVSPN902300AACDC04", "0002")
+	expectError(t, err, "scrapped")
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeleteAsset(ctx, "IVSLAB-PVC23FG0001", false, "end of life")
+	})
+	expectErrorCode(t, err, ErrCodeConflict)
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAssetLocation(ctx, "IVSLAB-PVC23FG0001", "Vietnam", ReasonRelocation, -1)
+	})
+	expectErrorCode(t, err, ErrCodeConflict)
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeleteAsset(ctx, "IVSLAB-PVC23FG0003", false, "")
+	})
+	if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "reason" {
+		t.Fatalf("unexpected error payload %v", payload)
+	}
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeleteAsset(ctx, "IVSLAB-PVC23FG9999", false, "end of life")
+	})
+	expectError(t, err, "does not exist")
+}
+
+func TestDeletePart(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	err := l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeletePart(ctx, "IVSLAB-S23FA0001", "failed burn-in test")
+	})
+	if err != nil {
+		t.Fatalf("DeletePart failed: %v", err)
+	}
+	expectEvent(t, l, EventPartDeleted, "IVSLAB-S23FA0001")
+
+	// the part is kept as scrapped, with the reason and date of its deletion
+	part := readPart(t, l, "IVSLAB-S23FA0001")
+	if part.Status != PartStatusScrapped || part.DecommissionReason != "failed burn-in test" || part.DecommissionDate == "" {
+		t.Fatalf("expected a scrapped part, got %+v", part)
+	}
+	err = l.evaluate(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		parts, err := l.contract.GetPartsByOrganization(ctx, SecurityOrg)
+		if err != nil {
+			return err
+		}
+		if len(parts) != 3 {
+			t.Fatalf("expected the scrapped part to stay listed, got %v", partIDs(parts))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeletePart(ctx, "IVSLAB-S23FA0001", "failed burn-in test")
+	})
+	expectErrorCode(t, err, ErrCodeConflict)
+	err = l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeletePart(ctx, "IVSLAB-S23FA9999", "failed burn-in test")
+	})
+	expectError(t, err, "does not exist")
+
+	// installed and offered parts cannot be deleted
+	moveSetToBrand(t, l, "0002")
+	if err := createAsset(l, "IVSLAB-PVC23FG0002", "IVSPN902300AACDC02", "0002"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeletePart(ctx, "IVSLAB-S23FA0002", "defective")
+	})
+	expectErrorCode(t, err, ErrCodeConflict)
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.TransferPart(ctx, "IVSLAB-N23FA0003", BrandOrg)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("TransferPart failed: %v", err)
+	}
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeletePart(ctx, "IVSLAB-N23FA0003", "defective")
+	})
+	expectErrorCode(t, err, ErrCodeConflict)
+}
+
+func TestPartQueries(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		parts, err := l.contract.GetAllParts(ctx)
+		if err != nil {
+			return err
+		}
+		if len(parts) != 12 {
+			t.Fatalf("expected 12 parts, got %d", len(parts))
+		}
+		for _, part := range parts {
+			if part.DocType != "part" {
+				t.Fatalf("expected only parts, got %+v", part)
+			}
+		}
+
+		assets, err := l.contract.GetAllAssets(ctx)
+		if err != nil {
+			return err
+		}
+		if len(assets) != 1 || assets[0].ID != "IVSLAB-PVC23FG0001" {
+			t.Fatalf("expected only the asset, got %+v", assets)
+		}
+
+		// IDs of one docType are not found as the other
+		_, err = l.contract.ReadAsset(ctx, "IVSLAB-S23FA0001")
+		expectError(t, err, "does not exist")
+		_, err = l.contract.ReadPart(ctx, "IVSLAB-PVC23FG0001")
+		expectError(t, err, "does not exist")
+
+		parts, err = l.contract.GetPartsByRange(ctx, "IVSLAB-C23FA0001", "IVSLAB-C23FA0003")
+		if err != nil {
+			return err
+		}
+		if len(parts) != 2 || parts[0].PID != "IVSLAB-C23FA0001" || parts[1].PID != "IVSLAB-C23FA0002" {
+			t.Fatalf("unexpected parts %+v", parts)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssetQueries(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	for i, suffix := range []string{"0001", "0002", "0003"} {
+		moveSetToBrand(t, l, suffix)
+		assetID := "IVSLAB-PVC23FG" + suffix
+		serialNumber := "IVSPN902300AACDC0" + string(rune('1'+i))
+		if err := createAsset(l, assetID, serialNumber, suffix); err != nil {
+			t.Fatalf("CreateAsset failed: %v", err)
+		}
+	}
+
+	err := l.evaluateWithAttributes(brandMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		assets, err := l.contract.QueryAssetsBySerialNumber(ctx, "IVSPN902300AACDC01", "IVSPN902300AACDC02")
+		if err != nil {
+			return err
+		}
+		if len(assets) != 2 {
+			t.Fatalf("expected 2 assets, got %d", len(assets))
+		}
+
+		assets, err = l.contract.QueryAssets(ctx, `{"selector":{"MadeBy":"Brand.Co"}}`)
+		if err != nil {
+			return err
+		}
+		if len(assets) != 3 {
+			t.Fatalf("expected 3 assets, got %d", len(assets))
+		}
+
+		page, err := l.contract.QueryAssetsWithPagination(ctx, `{"selector":{"MadeBy":"Brand.Co"}}`, 2, "")
+		if err != nil {
+			return err
+		}
+		if page.FetchedRecordsCount != 2 || page.Bookmark == "" {
+			t.Fatalf("unexpected first page %+v", page)
+		}
+		page, err = l.contract.QueryAssetsWithPagination(ctx, `{"selector":{"MadeBy":"Brand.Co"}}`, 2, page.Bookmark)
+		if err != nil {
+			return err
+		}
+		if page.FetchedRecordsCount != 1 || page.Bookmark != "" {
+			t.Fatalf("unexpected last page %+v", page)
+		}
+
+		page, err = l.contract.GetAssetsByRangeWithPagination(ctx, "IVSLAB-PVC23FG0001", "IVSLAB-PVC23FG9999", 2, "")
+		if err != nil {
+			return err
+		}
+		if page.FetchedRecordsCount != 2 || page.Records[0].ID != "IVSLAB-PVC23FG0001" {
+			t.Fatalf("unexpected first page %+v", page)
+		}
+		page, err = l.contract.GetAssetsByRangeWithPagination(ctx, "IVSLAB-PVC23FG0001", "IVSLAB-PVC23FG9999", 2, page.Bookmark)
+		if err != nil {
+			return err
+		}
+		if page.FetchedRecordsCount != 1 || page.Records[0].ID != "IVSLAB-PVC23FG0003" {
+			t.Fatalf("unexpected last page %+v", page)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetAssetHistory(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	err := l.submitWithAttributes(brandMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.PurgeAsset(ctx, "IVSLAB-PVC23FG0001")
+	})
+	if err != nil {
+		t.Fatalf("PurgeAsset failed: %v", err)
+	}
+
+	err = l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		records, err := l.contract.GetAssetHistory(ctx, "IVSLAB-PVC23FG0001")
+		if err != nil {
+			return err
+		}
+		if len(records) != 2 {
+			t.Fatalf("expected 2 history records, got %d", len(records))
+		}
+		if !records[0].IsDelete || records[0].Record.ID != "IVSLAB-PVC23FG0001" {
+			t.Fatalf("expected the delete first, got %+v", records[0])
+		}
+		if records[1].IsDelete || records[1].Record.SerialNumber != "IVSPN902300AACDC01" {
+			t.Fatalf("expected the creation last, got %+v", records[1])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
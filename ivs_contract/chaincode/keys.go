@@ -0,0 +1,162 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Parts and assets are stored under simple keys with a per-docType prefix, so that range
+// queries over one type never return the other. Composite keys cannot be used here because
+// Fabric does not allow them in GetStateByRange.
+const (
+	partKeyPrefix  = "part_"
+	assetKeyPrefix = "asset_"
+)
+
+// partKey returns the world state key of a part
+func partKey(partID string) string {
+	return partKeyPrefix + partID
+}
+
+// assetKey returns the world state key of an asset
+func assetKey(assetID string) string {
+	return assetKeyPrefix + assetID
+}
+
+// keyRange returns the start and end key of a range query over the IDs [startID, endID) of one
+// docType. An empty startID or endID leaves that side of the range open within the prefix.
+func keyRange(prefix string, startID string, endID string) (string, string) {
+	startKey := prefix + startID
+	endKey := prefix + endID
+	if endID == "" {
+		// the prefix with its last byte incremented sorts after every key with the prefix
+		endKey = prefix[:len(prefix)-1] + string(prefix[len(prefix)-1]+1)
+	}
+	return startKey, endKey
+}
+
+// MigrateLedgerKeys moves parts and assets stored under their raw ID, as written by earlier
+// versions of the chaincode, to their prefixed keys. Once every record is moved, it marks the
+// parts embedded in assets written before part states existed as installed in their asset.
+// At most limit records are changed per call; the number changed is returned, so callers repeat
+// the transaction until it returns 0.
+func (t *SmartContract) MigrateLedgerKeys(ctx contractapi.TransactionContextInterface, limit int) (int, error) {
+	err := requireOrganization(ctx, BrandOrg)
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 {
+		return 0, invalidArgument("limit", "limit must be positive, got %d", limit)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() && migrated < limit {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+		if strings.HasPrefix(queryResponse.Key, partKeyPrefix) || strings.HasPrefix(queryResponse.Key, assetKeyPrefix) {
+			continue
+		}
+
+		var doc struct {
+			DocType string `json:"docType"`
+		}
+		if json.Unmarshal(queryResponse.Value, &doc) != nil {
+			continue
+		}
+		var newKey string
+		switch doc.DocType {
+		case "part":
+			newKey = partKey(queryResponse.Key)
+		case "asset":
+			newKey = assetKey(queryResponse.Key)
+		default:
+			continue
+		}
+
+		err = ctx.GetStub().PutState(newKey, queryResponse.Value)
+		if err != nil {
+			return 0, internalError("failed to write %s: %v", newKey, err)
+		}
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return 0, internalError("failed to delete %s: %v", queryResponse.Key, err)
+		}
+		migrated++
+	}
+	// a transaction does not read its own writes, so parts are only backfilled once no key moves
+	if migrated == 0 {
+		migrated, err = backfillInstalledParts(ctx, limit)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if migrated == 0 {
+		return 0, nil
+	}
+
+	err = emitEvent(ctx, &ContractEvent{
+		Name:       EventLedgerKeysMigrated,
+		ObjectType: "ledger",
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return migrated, nil
+}
+
+// backfillInstalledParts marks the parts of active assets that have no status, because they were
+// written before part states existed, as installed in the asset that embeds them. A part embedded
+// in several assets is installed in the first one found. At most limit parts are changed.
+func backfillInstalledParts(ctx contractapi.TransactionContextInterface, limit int) (int, error) {
+	startKey, endKey := keyRange(assetKeyPrefix, "", "")
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	installed := make(map[string]bool)
+	for resultsIterator.HasNext() && len(installed) < limit {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+		var asset Asset
+		if json.Unmarshal(queryResponse.Value, &asset) != nil || asset.DocType != "asset" || asset.Status == AssetStatusDecommissioned {
+			continue
+		}
+		normalizeAsset(&asset)
+
+		for _, partID := range partIDs(assetParts(&asset)) {
+			if installed[partID] || len(installed) >= limit {
+				continue
+			}
+			partBytes, err := ctx.GetStub().GetState(partKey(partID))
+			if err != nil {
+				return 0, internalError("failed to read part %s: %v", partID, err)
+			}
+			var part Part
+			if partBytes == nil || json.Unmarshal(partBytes, &part) != nil || part.Status != "" {
+				continue
+			}
+			err = installParts(ctx, asset.ID, []*Part{&part})
+			if err != nil {
+				return 0, err
+			}
+			installed[partID] = true
+		}
+	}
+
+	return len(installed), nil
+}
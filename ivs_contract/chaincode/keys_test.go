@@ -0,0 +1,120 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestKeyRange(t *testing.T) {
+	tests := []struct {
+		startID, endID    string
+		startKey, wantEnd string
+	}{
+		{"", "", "part_", "part`"},
+		{"IVSLAB-C23FA0001", "", "part_IVSLAB-C23FA0001", "part`"},
+		{"IVSLAB-C23FA0001", "IVSLAB-C23FA0003", "part_IVSLAB-C23FA0001", "part_IVSLAB-C23FA0003"},
+	}
+	for _, tt := range tests {
+		startKey, endKey := keyRange(partKeyPrefix, tt.startID, tt.endID)
+		if startKey != tt.startKey || endKey != tt.wantEnd {
+			t.Errorf("keyRange(%q, %q) = %q, %q; want %q, %q", tt.startID, tt.endID, startKey, endKey, tt.startKey, tt.wantEnd)
+		}
+	}
+}
+
+func TestMigrateLedgerKeys(t *testing.T) {
+	l := newTestLedger()
+	// records written under their raw ID by earlier chaincode versions
+	l.stub.state["IVSLAB-S23FA0001"] = []byte(`{"docType":"part","PID":"IVSLAB-S23FA0001","Organization":"Security-Org"}`)
+	l.stub.state["IVSLAB-N23FA0001"] = []byte(`{"docType":"part","PID":"IVSLAB-N23FA0001","Organization":"Network-Org"}`)
+	l.stub.state["IVSLAB-PVC23FG0001"] = []byte(`{"docType":"asset","ID":"IVSLAB-PVC23FG0001","MadeBy":"Brand.Co"}`)
+	l.stub.state["unrelated"] = []byte(`not json`)
+
+	migrate := func(mspID string, limit int) (int, error) {
+		var migrated int
+		err := l.submit(mspID, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			migrated, err = l.contract.MigrateLedgerKeys(ctx, limit)
+			return err
+		})
+		return migrated, err
+	}
+
+	_, err := migrate(securityMSP, 10)
+	expectError(t, err, "FORBIDDEN")
+	_, err = migrate(brandMSP, 0)
+	expectError(t, err, "limit must be positive")
+
+	total := 0
+	for {
+		migrated, err := migrate(brandMSP, 2)
+		if err != nil {
+			t.Fatalf("MigrateLedgerKeys failed: %v", err)
+		}
+		if migrated == 0 {
+			break
+		}
+		total += migrated
+	}
+	if total != 3 {
+		t.Fatalf("expected 3 migrated records, got %d", total)
+	}
+	if _, ok := l.stub.state["IVSLAB-S23FA0001"]; ok {
+		t.Fatalf("expected the legacy key to be removed")
+	}
+	if _, ok := l.stub.state["unrelated"]; !ok {
+		t.Fatalf("expected unrelated keys to be left alone")
+	}
+
+	if part := readPart(t, l, "IVSLAB-N23FA0001"); part.Organization != NetworkOrg {
+		t.Fatalf("unexpected part %+v", part)
+	}
+	if asset := readAsset(t, l, "IVSLAB-PVC23FG0001"); asset.MadeBy != "Brand.Co" {
+		t.Fatalf("unexpected asset %+v", asset)
+	}
+}
+
+func TestMigrateLedgerKeysBackfillsInstalledParts(t *testing.T) {
+	l := newTestLedger()
+	// a four-chip asset and its parts, written before part states existed
+	l.stub.state["IVSLAB-S23FA0001"] = []byte(`{"docType":"part","PID":"IVSLAB-S23FA0001","Organization":"Brand-Org"}`)
+	l.stub.state["IVSLAB-N23FA0001"] = []byte(`{"docType":"part","PID":"IVSLAB-N23FA0001","Organization":"Brand-Org"}`)
+	l.stub.state["IVSLAB-C23FA0001"] = []byte(`{"docType":"part","PID":"IVSLAB-C23FA0001","Organization":"CMOS-Org"}`)
+	l.stub.state["IVSLAB-PVC23FG0001"] = []byte(`{"docType":"asset","ID":"IVSLAB-PVC23FG0001","MadeBy":"Brand.Co",` +
+		`"SecurityChip":{"PID":"IVSLAB-S23FA0001"},"NetworkChip":{"PID":"IVSLAB-N23FA0001"}}`)
+	// a second asset embedding the same security chip, and a part already tracked elsewhere
+	l.stub.state[assetKey("IVSLAB-PVC23FG0002")] = []byte(`{"docType":"asset","ID":"IVSLAB-PVC23FG0002","MadeBy":"Brand.Co",` +
+		`"SecurityChip":{"PID":"IVSLAB-S23FA0001"},"Components":[{"Slot":"CMOSChip","Part":{"PID":"IVSLAB-V23FA0001"}}]}`)
+	l.stub.state[partKey("IVSLAB-V23FA0001")] = []byte(`{"docType":"part","PID":"IVSLAB-V23FA0001","Status":"removed"}`)
+
+	for calls := 0; ; calls++ {
+		if calls > 10 {
+			t.Fatalf("MigrateLedgerKeys did not finish")
+		}
+		var migrated int
+		err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			migrated, err = l.contract.MigrateLedgerKeys(ctx, 1)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("MigrateLedgerKeys failed: %v", err)
+		}
+		if migrated == 0 {
+			break
+		}
+	}
+
+	for _, partID := range []string{"IVSLAB-S23FA0001", "IVSLAB-N23FA0001"} {
+		if part := readPart(t, l, partID); part.Status != PartStatusInstalled || part.AssetID != "IVSLAB-PVC23FG0001" {
+			t.Fatalf("expected %s to be installed in IVSLAB-PVC23FG0001, got %+v", partID, part)
+		}
+	}
+	if part := readPart(t, l, "IVSLAB-C23FA0001"); part.Status != "" || part.AssetID != "" {
+		t.Fatalf("expected a part outside of any asset to be left alone, got %+v", part)
+	}
+	if part := readPart(t, l, "IVSLAB-V23FA0001"); part.Status != PartStatusRemoved {
+		t.Fatalf("expected a tracked part to be left alone, got %+v", part)
+	}
+}
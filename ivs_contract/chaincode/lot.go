@@ -0,0 +1,234 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// lotPartIndex lists the parts of a production lot
+const lotPartIndex = "lotID~partID"
+
+// Lot 生產批次, a production lot of parts made by one chip maker
+type Lot struct {
+	DocType         string `json:"docType" metadata:",optional"`                                             // DocType is used to distinguish the various types of objects in state database
+	LotID           string `json:"LotID" validate:"required,max=64,pattern=code"`                            // 批次唯一ID
+	Manufacturer    string `json:"Manufacturer" validate:"required,max=64"`                                  // 製造商
+	Organization    string `json:"Organization" validate:"required,organization"`                            // 組織
+	FabLocation     string `json:"FabLocation" validate:"required,max=64"`                                   // 晶圓廠地點
+	Quantity        int    `json:"Quantity"`                                                                 // 批次數量
+	ProductionStart string `json:"ProductionStart" validate:"required"`                                      // 生產開始時間 (RFC3339)
+	ProductionEnd   string `json:"ProductionEnd" validate:"required"`                                        // 生產結束時間 (RFC3339)
+	CertificateHash string `json:"CertificateHash,omitempty" metadata:",optional" validate:"pattern=sha256"` // 品質證書之SHA-256雜湊
+	CreatedBy       string `json:"CreatedBy" metadata:",optional"`                                           // 登錄者
+	Created         string `json:"Created" metadata:",optional"`                                             // 建立日期
+}
+
+// CreateLot registers a production lot. Only the chip maker named by lot.Organization may call it.
+// Parts are assigned to the lot when they are registered with CreatePartsBatch.
+func (t *SmartContract) CreateLot(ctx contractapi.TransactionContextInterface, lot Lot) error {
+	lot.DocType = "lot"
+	err := validate(&lot)
+	if err != nil {
+		return err
+	}
+	if lot.Quantity < 1 {
+		return invalidArgument("Quantity", "Quantity must be positive, got %d", lot.Quantity)
+	}
+	start, err := time.Parse(time.RFC3339, lot.ProductionStart)
+	if err != nil {
+		return invalidArgument("ProductionStart", "ProductionStart %q is not an RFC3339 time", lot.ProductionStart)
+	}
+	end, err := time.Parse(time.RFC3339, lot.ProductionEnd)
+	if err != nil {
+		return invalidArgument("ProductionEnd", "ProductionEnd %q is not an RFC3339 time", lot.ProductionEnd)
+	}
+	if end.Before(start) {
+		return invalidArgument("ProductionEnd", "the production window of lot %s ends before it starts", lot.LotID)
+	}
+	err = requireChipMaker(ctx, lot.Organization)
+	if err != nil {
+		return err
+	}
+
+	lotBytes, err := ctx.GetStub().GetState(lotKey(lot.LotID))
+	if err != nil {
+		return internalError("failed to read lot %s: %v", lot.LotID, err)
+	}
+	if lotBytes != nil {
+		return alreadyExists("the lot %s already exists", lot.LotID)
+	}
+	lot.CreatedBy, err = getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	lot.Created, err = getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	lotBytes, err = json.Marshal(lot)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(lotKey(lot.LotID), lotBytes)
+	if err != nil {
+		return internalError("failed to put lot %s: %v", lot.LotID, err)
+	}
+
+	return emitEvent(ctx, &ContractEvent{
+		Name:       EventLotCreated,
+		ObjectType: "lot",
+		ObjectID:   lot.LotID,
+		NewOwner:   lot.Organization,
+	})
+}
+
+// ReadLot returns the production lot with the given ID
+func (t *SmartContract) ReadLot(ctx contractapi.TransactionContextInterface, lotID string) (*Lot, error) {
+	lotBytes, err := ctx.GetStub().GetState(lotKey(lotID))
+	if err != nil {
+		return nil, internalError("failed to read lot %s: %v", lotID, err)
+	}
+	if lotBytes == nil {
+		return nil, notFound("the lot %s does not exist", lotID)
+	}
+
+	var lot Lot
+	err = json.Unmarshal(lotBytes, &lot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lot, nil
+}
+
+// GetPartsByLot returns every part of a production lot, wherever it is now, ordered by part ID
+func (t *SmartContract) GetPartsByLot(ctx contractapi.TransactionContextInterface, lotID string) ([]*Part, error) {
+	_, err := t.ReadLot(ctx, lotID)
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(lotPartIndex, []string{lotID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	parts := []*Part{}
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 {
+			continue
+		}
+		partBytes, err := ctx.GetStub().GetState(partKey(compositeKeyParts[1]))
+		if err != nil {
+			return nil, internalError("failed to get part %s: %v", compositeKeyParts[1], err)
+		}
+		// skip index entries of purged parts
+		var part Part
+		if partBytes == nil || json.Unmarshal(partBytes, &part) != nil || part.LotID != lotID {
+			continue
+		}
+		parts = append(parts, &part)
+	}
+
+	return parts, nil
+}
+
+// GetAssetsByLot returns the assets that currently contain a part of a production lot, ordered by
+// asset ID, so that a defective lot can be traced to every affected camera
+func (t *SmartContract) GetAssetsByLot(ctx contractapi.TransactionContextInterface, lotID string) ([]*Asset, error) {
+	parts, err := t.GetPartsByLot(ctx, lotID)
+	if err != nil {
+		return nil, err
+	}
+
+	assets := []*Asset{}
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		if part.Status != PartStatusInstalled || part.AssetID == "" || seen[part.AssetID] {
+			continue
+		}
+		seen[part.AssetID] = true
+		asset, err := t.ReadAsset(ctx, part.AssetID)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].ID < assets[j].ID
+	})
+
+	return assets, nil
+}
+
+// lotUsage tracks how many parts of a lot exist and are being registered by a transaction
+type lotUsage struct {
+	lot   *Lot
+	count int
+}
+
+// checkPartLot returns an error unless the lot of a part exists, was made by the part's maker
+// and still has room for the part. usage caches the lots already checked by the transaction.
+func (t *SmartContract) checkPartLot(ctx contractapi.TransactionContextInterface, part *Part, usage map[string]*lotUsage) error {
+	if part.LotID == "" {
+		return nil
+	}
+	current, ok := usage[part.LotID]
+	if !ok {
+		lot, err := t.ReadLot(ctx, part.LotID)
+		if err != nil {
+			return err
+		}
+		count, err := countLotParts(ctx, lot.LotID)
+		if err != nil {
+			return err
+		}
+		current = &lotUsage{lot: lot, count: count}
+		usage[part.LotID] = current
+	}
+	if current.lot.Organization != part.Organization || current.lot.Manufacturer != part.Manufacturer {
+		return invalidArgument("LotID", "lot %s was made by %s of %s, not by %s of %s", part.LotID, current.lot.Manufacturer, current.lot.Organization, part.Manufacturer, part.Organization)
+	}
+	if current.count >= current.lot.Quantity {
+		return conflict("lot %s already holds its quantity of %d parts", part.LotID, current.lot.Quantity)
+	}
+	current.count++
+
+	return nil
+}
+
+// countLotParts returns the number of parts registered in a lot
+func countLotParts(ctx contractapi.TransactionContextInterface, lotID string) (int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(lotPartIndex, []string{lotID})
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	count := 0
+	for resultsIterator.HasNext() {
+		_, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// lotKey returns the world state key of a production lot
+func lotKey(lotID string) string {
+	return "lot_" + lotID
+}
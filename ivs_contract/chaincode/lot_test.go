@@ -0,0 +1,216 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// networkLot returns a lot of Network-Org holding quantity parts
+func networkLot(lotID string, quantity int) Lot {
+	return Lot{
+		LotID:           lotID,
+		Manufacturer:    "Network.Co",
+		Organization:    NetworkOrg,
+		FabLocation:     "Hsinchu",
+		Quantity:        quantity,
+		ProductionStart: "2023-05-01T00:00:00Z",
+		ProductionEnd:   "2023-05-07T00:00:00Z",
+		CertificateHash: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+}
+
+// lotPart returns the definition of a Network-Org part of a lot
+func lotPart(partID string, partNumber string, lotID string) PartDefinition {
+	part := networkPart(partID, partNumber)
+	part.LotID = lotID
+	return part
+}
+
+func TestCreateLot(t *testing.T) {
+	l := newTestLedger()
+	err := l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CreateLot(ctx, networkLot("LOT-N2305-A", 3))
+	})
+	if err != nil {
+		t.Fatalf("CreateLot failed: %v", err)
+	}
+	expectEvent(t, l, EventLotCreated, "LOT-N2305-A")
+	err = l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		lot, err := l.contract.ReadLot(ctx, "LOT-N2305-A")
+		if err != nil {
+			return err
+		}
+		if lot.DocType != "lot" || lot.Quantity != 3 || lot.Created == "" || lot.CreatedBy == "" {
+			t.Fatalf("unexpected lot %+v", lot)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		mspID string
+		lot   func() Lot
+		code  string
+	}{
+		{"duplicate", networkMSP, func() Lot { return networkLot("LOT-N2305-A", 3) }, ErrCodeAlreadyExists},
+		{"other chip maker", securityMSP, func() Lot { return networkLot("LOT-N2305-B", 3) }, ErrCodeForbidden},
+		{"no quantity", networkMSP, func() Lot { return networkLot("LOT-N2305-B", 0) }, ErrCodeInvalidArgument},
+		{"bad certificate hash", networkMSP, func() Lot {
+			lot := networkLot("LOT-N2305-B", 3)
+			lot.CertificateHash = "not a digest"
+			return lot
+		}, ErrCodeInvalidArgument},
+		{"window ends before it starts", networkMSP, func() Lot {
+			lot := networkLot("LOT-N2305-B", 3)
+			lot.ProductionEnd = "2023-04-30T00:00:00Z"
+			return lot
+		}, ErrCodeInvalidArgument},
+		{"window not a time", networkMSP, func() Lot {
+			lot := networkLot("LOT-N2305-B", 3)
+			lot.ProductionStart = "May 1st"
+			return lot
+		}, ErrCodeInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.submit(tt.mspID, func(ctx contractapi.TransactionContextInterface) error {
+				return l.contract.CreateLot(ctx, tt.lot())
+			})
+			expectErrorCode(t, err, tt.code)
+		})
+	}
+}
+
+func TestPartsOfLot(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	err := l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CreateLot(ctx, networkLot("LOT-N2305-A", 3))
+	})
+	if err != nil {
+		t.Fatalf("CreateLot failed: %v", err)
+	}
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.CreatePartsBatch(ctx, []PartDefinition{
+			lotPart("IVSLAB-N23FA0004", "NPN3R1C00AA4", "LOT-N2305-A"),
+			lotPart("IVSLAB-N23FA0005", "NPN3R1C00AA5", "LOT-N2305-A"),
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("CreatePartsBatch failed: %v", err)
+	}
+	if part := readPart(t, l, "IVSLAB-N23FA0004"); part.LotID != "LOT-N2305-A" {
+		t.Fatalf("expected the part to reference its lot, got %+v", part)
+	}
+
+	// the lot has room for one more part only
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.CreatePartsBatch(ctx, []PartDefinition{
+			lotPart("IVSLAB-N23FA0006", "NPN3R1C00AA6", "LOT-N2305-A"),
+			lotPart("IVSLAB-N23FA0007", "NPN3R1C00AA7", "LOT-N2305-A"),
+		})
+		return err
+	})
+	contractErr, results := batchError(t, err)
+	if contractErr.Code != ErrCodeConflict || results[0].Status != BatchItemValid || results[1].Code != ErrCodeConflict {
+		t.Fatalf("expected the second part to exceed the lot, got %+v %+v %+v", contractErr, results[0], results[1])
+	}
+	other := lotPart("IVSLAB-N23FA0006", "NPN3R1C00AA6", "LOT-N2305-A")
+	other.Manufacturer = "Other.Co"
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.CreatePartsBatch(ctx, []PartDefinition{other, lotPart("IVSLAB-N23FA0007", "NPN3R1C00AA7", "LOT-N2305-Z")})
+		return err
+	})
+	_, results = batchError(t, err)
+	if results[0].Code != ErrCodeInvalidArgument || results[0].Field != "LotID" || results[1].Code != ErrCodeNotFound {
+		t.Fatalf("unexpected results %+v %+v", results[0], results[1])
+	}
+
+	err = l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		parts, err := l.contract.GetPartsByLot(ctx, "LOT-N2305-A")
+		if err != nil {
+			return err
+		}
+		if ids := partIDs(parts); len(ids) != 2 || ids[0] != "IVSLAB-N23FA0004" || ids[1] != "IVSLAB-N23FA0005" {
+			t.Fatalf("unexpected parts of lot %v", ids)
+		}
+		_, err = l.contract.GetPartsByLot(ctx, "LOT-N2305-Z")
+		expectErrorCode(t, err, ErrCodeNotFound)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssetsByLot(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	err := l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CreateLot(ctx, networkLot("LOT-N2305-A", 3))
+	})
+	if err != nil {
+		t.Fatalf("CreateLot failed: %v", err)
+	}
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.CreatePartsBatch(ctx, []PartDefinition{
+			lotPart("IVSLAB-N23FA0004", "NPN3R1C00AA4", "LOT-N2305-A"),
+			lotPart("IVSLAB-N23FA0005", "NPN3R1C00AA5", "LOT-N2305-A"),
+			lotPart("IVSLAB-N23FA0006", "NPN3R1C00AA6", "LOT-N2305-A"),
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("CreatePartsBatch failed: %v", err)
+	}
+
+	// two cameras are built with network chips of the lot, a third one without
+	for i, suffix := range []string{"0001", "0002", "0003"} {
+		moveToBrand(t, l, securityMSP, "IVSLAB-S23FA"+suffix)
+		moveToBrand(t, l, cmosMSP, "IVSLAB-C23FA"+suffix)
+		moveToBrand(t, l, videocodecMSP, "IVSLAB-V23FA"+suffix)
+		networkChipID := []string{"IVSLAB-N23FA0005", "IVSLAB-N23FA0004", "IVSLAB-N23FA0003"}[i]
+		moveToBrand(t, l, networkMSP, networkChipID)
+		err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+			return l.contract.CreateAsset(ctx, "IVSLAB-PVC23FG"+suffix, DefaultProductModelID, "Brand.Co", "Taiwan", "IVSPN902300AACDC"+suffix[2:],
+				chipSet("IVSLAB-S23FA"+suffix, networkChipID, "IVSLAB-C23FA"+suffix, "IVSLAB-V23FA"+suffix))
+		})
+		if err != nil {
+			t.Fatalf("CreateAsset failed: %v", err)
+		}
+	}
+
+	assetsByLot := func() []string {
+		var ids []string
+		err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+			assets, err := l.contract.GetAssetsByLot(ctx, "LOT-N2305-A")
+			for _, asset := range assets {
+				ids = append(ids, asset.ID)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("GetAssetsByLot failed: %v", err)
+		}
+		return ids
+	}
+	if ids := assetsByLot(); len(ids) != 2 || ids[0] != "IVSLAB-PVC23FG0001" || ids[1] != "IVSLAB-PVC23FG0002" {
+		t.Fatalf("expected the cameras with chips of the lot, got %v", ids)
+	}
+
+	// a decommissioned camera no longer contains the chip
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.DeleteAsset(ctx, "IVSLAB-PVC23FG0001", false, "end of life")
+	})
+	if err != nil {
+		t.Fatalf("DeleteAsset failed: %v", err)
+	}
+	if ids := assetsByLot(); len(ids) != 1 || ids[0] != "IVSLAB-PVC23FG0002" {
+		t.Fatalf("expected the remaining camera, got %v", ids)
+	}
+}
@@ -0,0 +1,68 @@
+package chaincode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TestContractSchema checks the types that cross the contract boundary against the rules of the
+// contract metadata: a field left out of the JSON must be optional in the schema, and an object
+// without required fields makes the metadata invalid.
+func TestContractSchema(t *testing.T) {
+	contextType := reflect.TypeOf((*contractapi.TransactionContextInterface)(nil)).Elem()
+	seen := map[reflect.Type]bool{}
+	var check func(typ reflect.Type)
+	check = func(typ reflect.Type) {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			check(typ.Elem())
+			return
+		case reflect.Struct:
+		default:
+			return
+		}
+		if typ == reflect.TypeOf(time.Time{}) || seen[typ] {
+			return
+		}
+		seen[typ] = true
+		required := 0
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			optional := strings.Contains(field.Tag.Get("metadata"), ",optional")
+			if strings.Contains(field.Tag.Get("json"), ",omitempty") && !optional {
+				t.Errorf("%s.%s is omitted from the JSON when empty but required in the schema", typ.Name(), field.Name)
+			}
+			if !optional {
+				required++
+			}
+			check(field.Type)
+		}
+		if required == 0 {
+			t.Errorf("%s has no required field", typ.Name())
+		}
+	}
+
+	contract := reflect.TypeOf(&SmartContract{})
+	for i := 0; i < contract.NumMethod(); i++ {
+		method := contract.Method(i).Type
+		if method.NumIn() < 2 || method.In(1) != contextType {
+			continue
+		}
+		for j := 2; j < method.NumIn(); j++ {
+			check(method.In(j))
+		}
+		for j := 0; j < method.NumOut(); j++ {
+			check(method.Out(j))
+		}
+	}
+	if len(seen) == 0 {
+		t.Fatal("no contract types were checked")
+	}
+}
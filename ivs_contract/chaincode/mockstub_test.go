@@ -0,0 +1,624 @@
+package chaincode
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+const compositeKeyNamespace = "\x00"
+
+// mockStub is an in-memory ChaincodeStubInterface. Like a peer, it only shows committed
+// state to reads: writes made during a transaction become visible once commit is called.
+// Methods the chaincode does not use are left to the embedded nil interface and panic.
+type mockStub struct {
+	shim.ChaincodeStubInterface
+
+	state   map[string][]byte
+	history map[string][]*queryresult.KeyModification
+	events  []mockEvent
+
+	txCount int
+	txID    string
+	txTime  time.Time
+	writes  map[string][]byte
+	deletes map[string]bool
+	event   *mockEvent
+}
+
+// mockEvent is a chaincode event set by a committed transaction
+type mockEvent struct {
+	TxID    string
+	Name    string
+	Payload []byte
+}
+
+func newMockStub() *mockStub {
+	return &mockStub{
+		state:   make(map[string][]byte),
+		history: make(map[string][]*queryresult.KeyModification),
+		txTime:  time.Date(2023, 5, 15, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+// begin starts a new transaction with its own ID and a timestamp one minute after the previous one
+func (s *mockStub) begin() {
+	s.txCount++
+	s.txID = fmt.Sprintf("tx%d", s.txCount)
+	s.txTime = s.txTime.Add(time.Minute)
+	s.writes = make(map[string][]byte)
+	s.deletes = make(map[string]bool)
+	s.event = nil
+}
+
+// commit applies the writes of the current transaction and records them in the key history
+func (s *mockStub) commit() {
+	ts, _ := ptypes.TimestampProto(s.txTime)
+	for _, key := range s.sortedKeys(s.writes) {
+		s.state[key] = s.writes[key]
+		s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.txID, Value: s.writes[key], Timestamp: ts})
+	}
+	for key := range s.deletes {
+		delete(s.state, key)
+		s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.txID, Timestamp: ts, IsDelete: true})
+	}
+	if s.event != nil {
+		s.events = append(s.events, *s.event)
+	}
+	s.writes = nil
+	s.deletes = nil
+	s.event = nil
+}
+
+// rollback discards the writes and event of the current transaction
+func (s *mockStub) rollback() {
+	s.writes = nil
+	s.deletes = nil
+	s.event = nil
+}
+
+// lastEvent returns the event of the most recently committed transaction that set one
+func (s *mockStub) lastEvent() *mockEvent {
+	if len(s.events) == 0 {
+		return nil
+	}
+	return &s.events[len(s.events)-1]
+}
+
+func (s *mockStub) sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *mockStub) GetTxID() string {
+	return s.txID
+}
+
+func (s *mockStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return ptypes.TimestampProto(s.txTime)
+}
+
+func (s *mockStub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return fmt.Errorf("event name can not be empty string")
+	}
+	s.event = &mockEvent{TxID: s.txID, Name: name, Payload: payload}
+	return nil
+}
+
+func (s *mockStub) GetState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *mockStub) PutState(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	if s.writes == nil {
+		return fmt.Errorf("PutState called outside of a transaction")
+	}
+	delete(s.deletes, key)
+	s.writes[key] = value
+	return nil
+}
+
+func (s *mockStub) DelState(key string) error {
+	if s.writes == nil {
+		return fmt.Errorf("DelState called outside of a transaction")
+	}
+	delete(s.writes, key)
+	s.deletes[key] = true
+	return nil
+}
+
+func (s *mockStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	key := compositeKeyNamespace + objectType + compositeKeyNamespace
+	for _, attribute := range attributes {
+		if strings.Contains(attribute, compositeKeyNamespace) {
+			return "", fmt.Errorf("attribute %q contains the composite key separator", attribute)
+		}
+		key += attribute + compositeKeyNamespace
+	}
+	return key, nil
+}
+
+func (s *mockStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if !strings.HasPrefix(compositeKey, compositeKeyNamespace) {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+	components := strings.Split(strings.TrimSuffix(compositeKey[1:], compositeKeyNamespace), compositeKeyNamespace)
+	return components[0], components[1:], nil
+}
+
+// GetStateByRange returns the simple keys in [startKey, endKey), an empty endKey has no upper bound
+func (s *mockStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	return &mockStateIterator{results: s.rangeResults(startKey, endKey)}, nil
+}
+
+func (s *mockStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if bookmark != "" {
+		startKey = bookmark
+	}
+	return s.paginate(s.rangeResults(startKey, endKey), pageSize)
+}
+
+func (s *mockStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	results, err := s.compositeResults(objectType, keys, "")
+	if err != nil {
+		return nil, err
+	}
+	return &mockStateIterator{results: results}, nil
+}
+
+func (s *mockStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	results, err := s.compositeResults(objectType, keys, bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.paginate(results, pageSize)
+}
+
+// GetQueryResult emulates a CouchDB rich query with the selector and sort of the query string
+func (s *mockStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	results, err := s.richQueryResults(query)
+	if err != nil {
+		return nil, err
+	}
+	return &mockStateIterator{results: results}, nil
+}
+
+// GetQueryResultWithPagination uses the offset of the next record as bookmark
+func (s *mockStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	results, err := s.richQueryResults(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	offset := 0
+	if bookmark != "" {
+		offset, err = strconv.Atoi(bookmark)
+		if err != nil || offset < 0 {
+			return nil, nil, fmt.Errorf("invalid bookmark %q", bookmark)
+		}
+	}
+	if offset > len(results) {
+		offset = len(results)
+	}
+	results = results[offset:]
+
+	nextBookmark := ""
+	if pageSize > 0 && int(pageSize) < len(results) {
+		results = results[:pageSize]
+		nextBookmark = strconv.Itoa(offset + int(pageSize))
+	}
+	metadata := &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(results)), Bookmark: nextBookmark}
+	return &mockStateIterator{results: results}, metadata, nil
+}
+
+func (s *mockStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	// Fabric returns the most recent modification first
+	modifications := s.history[key]
+	results := make([]*queryresult.KeyModification, 0, len(modifications))
+	for i := len(modifications) - 1; i >= 0; i-- {
+		results = append(results, modifications[i])
+	}
+	return &mockHistoryIterator{results: results}, nil
+}
+
+func (s *mockStub) rangeResults(startKey, endKey string) []*queryresult.KV {
+	var results []*queryresult.KV
+	for _, key := range s.sortedKeys(s.state) {
+		if strings.HasPrefix(key, compositeKeyNamespace) || key < startKey || (endKey != "" && key >= endKey) {
+			continue
+		}
+		results = append(results, &queryresult.KV{Key: key, Value: s.state[key]})
+	}
+	return results
+}
+
+func (s *mockStub) compositeResults(objectType string, keys []string, bookmark string) ([]*queryresult.KV, error) {
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	var results []*queryresult.KV
+	for _, key := range s.sortedKeys(s.state) {
+		if !strings.HasPrefix(key, prefix) || key < bookmark {
+			continue
+		}
+		results = append(results, &queryresult.KV{Key: key, Value: s.state[key]})
+	}
+	return results, nil
+}
+
+// paginate cuts a key ordered result set to pageSize, using the first key of the next page as bookmark
+func (s *mockStub) paginate(results []*queryresult.KV, pageSize int32) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	bookmark := ""
+	if pageSize > 0 && int(pageSize) < len(results) {
+		bookmark = results[pageSize].Key
+		results = results[:pageSize]
+	}
+	metadata := &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(results)), Bookmark: bookmark}
+	return &mockStateIterator{results: results}, metadata, nil
+}
+
+func (s *mockStub) richQueryResults(query string) ([]*queryresult.KV, error) {
+	var request struct {
+		Selector map[string]interface{} `json:"selector"`
+		Sort     []map[string]string    `json:"sort"`
+	}
+	if err := json.Unmarshal([]byte(query), &request); err != nil {
+		return nil, fmt.Errorf("invalid query %q: %v", query, err)
+	}
+	if request.Selector == nil {
+		return nil, fmt.Errorf("query %q has no selector", query)
+	}
+
+	type match struct {
+		kv  *queryresult.KV
+		doc map[string]interface{}
+	}
+	var matches []match
+	for _, key := range s.sortedKeys(s.state) {
+		if strings.HasPrefix(key, compositeKeyNamespace) {
+			continue
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(s.state[key], &doc); err != nil {
+			continue
+		}
+		if matchSelector(doc, request.Selector) {
+			matches = append(matches, match{kv: &queryresult.KV{Key: key, Value: s.state[key]}, doc: doc})
+		}
+	}
+
+	// apply the sort keys from last to first so that the first key dominates
+	for i := len(request.Sort) - 1; i >= 0; i-- {
+		for field, direction := range request.Sort[i] {
+			sort.SliceStable(matches, func(a, b int) bool {
+				cmp := compareValues(lookupField(matches[a].doc, field), lookupField(matches[b].doc, field))
+				if direction == "desc" {
+					return cmp > 0
+				}
+				return cmp < 0
+			})
+		}
+	}
+
+	results := make([]*queryresult.KV, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, m.kv)
+	}
+	return results, nil
+}
+
+// matchSelector reports whether a JSON document satisfies a CouchDB Mango selector. It supports
+// field equality, $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin, $exists, $elemMatch, $and, $or and
+// $not on dotted field names, which covers the selectors the chaincode builds.
+func matchSelector(doc interface{}, selector map[string]interface{}) bool {
+	for field, condition := range selector {
+		clauses, _ := condition.([]interface{})
+		switch field {
+		case "$and":
+			for _, clause := range clauses {
+				clauseSelector, _ := clause.(map[string]interface{})
+				if !matchSelector(doc, clauseSelector) {
+					return false
+				}
+			}
+		case "$or":
+			matched := false
+			for _, clause := range clauses {
+				clauseSelector, _ := clause.(map[string]interface{})
+				if matchSelector(doc, clauseSelector) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		case "$not":
+			clauseSelector, _ := condition.(map[string]interface{})
+			if matchSelector(doc, clauseSelector) {
+				return false
+			}
+		default:
+			value, found := lookupPath(doc, field)
+			if !matchCondition(value, found, condition) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchCondition(value interface{}, found bool, condition interface{}) bool {
+	operators, ok := condition.(map[string]interface{})
+	if !ok || !isOperatorMap(operators) {
+		return found && reflect.DeepEqual(value, condition)
+	}
+	for operator, operand := range operators {
+		switch operator {
+		case "$eq":
+			if !found || !reflect.DeepEqual(value, operand) {
+				return false
+			}
+		case "$ne":
+			if found && reflect.DeepEqual(value, operand) {
+				return false
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			if operator == "$gt" && operand == nil {
+				// null sorts before every other value in CouchDB
+				if !found || value == nil {
+					return false
+				}
+				continue
+			}
+			if !found || !sameKind(value, operand) {
+				return false
+			}
+			cmp := compareValues(value, operand)
+			if (operator == "$gt" && cmp <= 0) || (operator == "$gte" && cmp < 0) ||
+				(operator == "$lt" && cmp >= 0) || (operator == "$lte" && cmp > 0) {
+				return false
+			}
+		case "$in", "$nin":
+			candidates, _ := operand.([]interface{})
+			in := false
+			for _, candidate := range candidates {
+				if found && reflect.DeepEqual(value, candidate) {
+					in = true
+				}
+			}
+			if in != (operator == "$in") {
+				return false
+			}
+		case "$exists":
+			if found != (operand == true) {
+				return false
+			}
+		case "$elemMatch":
+			elements, _ := value.([]interface{})
+			elementSelector, _ := operand.(map[string]interface{})
+			matched := false
+			for _, element := range elements {
+				if isOperatorMap(elementSelector) {
+					matched = matched || matchCondition(element, true, elementSelector)
+				} else {
+					matched = matched || matchSelector(element, elementSelector)
+				}
+			}
+			if !matched {
+				return false
+			}
+		default:
+			// an unknown operator never matches so that tests notice it
+			return false
+		}
+	}
+	return true
+}
+
+func isOperatorMap(m map[string]interface{}) bool {
+	for key := range m {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return len(m) > 0
+}
+
+// lookupPath resolves a dotted field name in a JSON document
+func lookupPath(doc interface{}, path string) (interface{}, bool) {
+	value := doc
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func lookupField(doc map[string]interface{}, path string) interface{} {
+	value, _ := lookupPath(doc, path)
+	return value
+}
+
+func sameKind(a, b interface{}) bool {
+	switch a.(type) {
+	case string:
+		_, ok := b.(string)
+		return ok
+	case float64:
+		_, ok := b.(float64)
+		return ok
+	}
+	return false
+}
+
+// compareValues orders strings and numbers, anything else sorts first
+func compareValues(a, b interface{}) int {
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1
+			case av > bv:
+				return 1
+			}
+			return 0
+		}
+	}
+	switch {
+	case a == nil && b != nil:
+		return -1
+	case a != nil && b == nil:
+		return 1
+	}
+	return 0
+}
+
+type mockStateIterator struct {
+	results []*queryresult.KV
+	next    int
+}
+
+func (it *mockStateIterator) HasNext() bool {
+	return it.next < len(it.results)
+}
+
+func (it *mockStateIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, fmt.Errorf("no more results")
+	}
+	it.next++
+	return it.results[it.next-1], nil
+}
+
+func (it *mockStateIterator) Close() error {
+	return nil
+}
+
+type mockHistoryIterator struct {
+	results []*queryresult.KeyModification
+	next    int
+}
+
+func (it *mockHistoryIterator) HasNext() bool {
+	return it.next < len(it.results)
+}
+
+func (it *mockHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	if !it.HasNext() {
+		return nil, fmt.Errorf("no more results")
+	}
+	it.next++
+	return it.results[it.next-1], nil
+}
+
+func (it *mockHistoryIterator) Close() error {
+	return nil
+}
+
+// mockClientIdentity is a cid.ClientIdentity for a given MSP ID and certificate attributes
+type mockClientIdentity struct {
+	mspID      string
+	attributes map[string]string
+}
+
+func (c *mockClientIdentity) GetID() (string, error) {
+	return "x509::CN=user1::" + c.mspID, nil
+}
+
+func (c *mockClientIdentity) GetMSPID() (string, error) {
+	return c.mspID, nil
+}
+
+func (c *mockClientIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	value, found := c.attributes[attrName]
+	return value, found, nil
+}
+
+func (c *mockClientIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	value, found := c.attributes[attrName]
+	if !found || value != attrValue {
+		return fmt.Errorf("attribute '%s' equals '%s', not '%s'", attrName, value, attrValue)
+	}
+	return nil
+}
+
+func (c *mockClientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+// testLedger runs transactions of a SmartContract against a mockStub
+type testLedger struct {
+	stub     *mockStub
+	contract *SmartContract
+}
+
+func newTestLedger() *testLedger {
+	return &testLedger{stub: newMockStub(), contract: new(SmartContract)}
+}
+
+// context returns a transaction context for a client of the given MSP
+func (l *testLedger) context(mspID string, attributes map[string]string) *contractapi.TransactionContext {
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(l.stub)
+	ctx.SetClientIdentity(&mockClientIdentity{mspID: mspID, attributes: attributes})
+	return ctx
+}
+
+// submit runs fn as a transaction of mspID and commits its writes when it succeeds
+func (l *testLedger) submit(mspID string, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	return l.submitWithAttributes(mspID, nil, fn)
+}
+
+func (l *testLedger) submitWithAttributes(mspID string, attributes map[string]string, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	l.stub.begin()
+	err := fn(l.context(mspID, attributes))
+	if err != nil {
+		l.stub.rollback()
+		return err
+	}
+	l.stub.commit()
+	return nil
+}
+
+// evaluate runs fn as a query of mspID, discarding any writes
+func (l *testLedger) evaluate(mspID string, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	return l.evaluateWithAttributes(mspID, nil, fn)
+}
+
+func (l *testLedger) evaluateWithAttributes(mspID string, attributes map[string]string, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	l.stub.begin()
+	defer l.stub.rollback()
+	return fn(l.context(mspID, attributes))
+}
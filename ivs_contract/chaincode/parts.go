@@ -0,0 +1,136 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxPageSize bounds the page size of paginated queries so that a single response stays well
+// below the gRPC message limit
+const maxPageSize = 1000
+
+// PaginatedPartQueryResult structure used for returning paginated part query results and metadata
+type PaginatedPartQueryResult struct {
+	Records             []*Part `json:"records"`
+	FetchedRecordsCount int32   `json:"fetchedRecordsCount"`
+	Bookmark            string  `json:"bookmark"`
+}
+
+// GetPartsByRangeWithPagination returns a page of the parts with IDs in [startID, endID)
+func (t *SmartContract) GetPartsByRangeWithPagination(ctx contractapi.TransactionContextInterface, startID string, endID string, pageSize int, bookmark string) (*PaginatedPartQueryResult, error) {
+	err := validatePageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	startKey, endKey := keyRange(partKeyPrefix, startID, endID)
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination(startKey, endKey, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	parts, err := constructQueryResponseFromIteratorPart(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedPartQueryResult{
+		Records:             parts,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// GetPartsByOrganizationWithPagination returns a page of the parts owned by organization,
+// read through the organization~partID index so that no rich query is needed
+func (t *SmartContract) GetPartsByOrganizationWithPagination(ctx contractapi.TransactionContextInterface, organization string, pageSize int, bookmark string) (*PaginatedPartQueryResult, error) {
+	err := validatePageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if !isOrganization(organization) {
+		return nil, invalidArgument("organization", "unknown organization %s", organization)
+	}
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(manufacturerPartIndex, []string{organization}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	parts, err := partsFromIndex(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedPartQueryResult{
+		Records:             parts,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// GetPartsByManufacturerWithPagination returns a page of the parts made by manufacturer
+func (t *SmartContract) GetPartsByManufacturerWithPagination(ctx contractapi.TransactionContextInterface, manufacturer string, pageSize int, bookmark string) (*PaginatedPartQueryResult, error) {
+	return t.getPartsByFieldWithPagination(ctx, "Manufacturer", manufacturer, pageSize, bookmark)
+}
+
+// GetPartsByPartNumberWithPagination returns a page of the parts with the given part number
+func (t *SmartContract) GetPartsByPartNumberWithPagination(ctx contractapi.TransactionContextInterface, partNumber string, pageSize int, bookmark string) (*PaginatedPartQueryResult, error) {
+	return t.getPartsByFieldWithPagination(ctx, "PartNumber", partNumber, pageSize, bookmark)
+}
+
+// getPartsByFieldWithPagination returns a page of the parts whose field equals value
+func (t *SmartContract) getPartsByFieldWithPagination(ctx contractapi.TransactionContextInterface, field string, value string, pageSize int, bookmark string) (*PaginatedPartQueryResult, error) {
+	err := validatePageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, invalidArgument(field, "%s must not be empty", field)
+	}
+	queryString, err := buildPartQuery(field, value)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	parts, err := constructQueryResponseFromIteratorPart(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedPartQueryResult{
+		Records:             parts,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// buildPartQuery returns the CouchDB query for the parts whose field equals value
+func buildPartQuery(field string, value string) (string, error) {
+	queryBytes, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType": "part",
+			field:     value,
+		},
+	})
+	if err != nil {
+		return "", internalError("failed to marshal query: %v", err)
+	}
+
+	return string(queryBytes), nil
+}
+
+// validatePageSize returns an error unless pageSize is in [1, maxPageSize]
+func validatePageSize(pageSize int) error {
+	if pageSize < 1 || pageSize > maxPageSize {
+		return invalidArgument("pageSize", "pageSize must be between 1 and %d, got %d", maxPageSize, pageSize)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// collectPartPages follows the bookmarks of a paginated part query and returns the part IDs of every page
+func collectPartPages(t *testing.T, l *testLedger, pageSize int, query func(ctx contractapi.TransactionContextInterface, bookmark string) (*PaginatedPartQueryResult, error)) [][]string {
+	t.Helper()
+	var pages [][]string
+	bookmark := ""
+	for {
+		var page *PaginatedPartQueryResult
+		err := l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			page, err = query(ctx, bookmark)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("paginated query failed: %v", err)
+		}
+		if int(page.FetchedRecordsCount) != len(page.Records) || len(page.Records) > pageSize {
+			t.Fatalf("unexpected page %+v", page)
+		}
+		pages = append(pages, partIDs(page.Records))
+		if page.Bookmark == "" {
+			return pages
+		}
+		bookmark = page.Bookmark
+		if len(pages) > 100 {
+			t.Fatalf("pagination does not terminate")
+		}
+	}
+}
+
+func TestPaginatedPartQueries(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveToBrand(t, l, networkMSP, "IVSLAB-N23FA0002")
+
+	tests := []struct {
+		name  string
+		query func(ctx contractapi.TransactionContextInterface, bookmark string) (*PaginatedPartQueryResult, error)
+		want  [][]string
+	}{
+		{"by range", func(ctx contractapi.TransactionContextInterface, bookmark string) (*PaginatedPartQueryResult, error) {
+			return l.contract.GetPartsByRangeWithPagination(ctx, "IVSLAB-N23FA0001", "IVSLAB-S23FA0000", 2, bookmark)
+		}, [][]string{{"IVSLAB-N23FA0001", "IVSLAB-N23FA0002"}, {"IVSLAB-N23FA0003"}}},
+		{"by organization", func(ctx contractapi.TransactionContextInterface, bookmark string) (*PaginatedPartQueryResult, error) {
+			return l.contract.GetPartsByOrganizationWithPagination(ctx, NetworkOrg, 1, bookmark)
+		}, [][]string{{"IVSLAB-N23FA0001"}, {"IVSLAB-N23FA0003"}}},
+		{"transferred to organization", func(ctx contractapi.TransactionContextInterface, bookmark string) (*PaginatedPartQueryResult, error) {
+			return l.contract.GetPartsByOrganizationWithPagination(ctx, BrandOrg, 10, bookmark)
+		}, [][]string{{"IVSLAB-N23FA0002"}}},
+		{"by manufacturer", func(ctx contractapi.TransactionContextInterface, bookmark string) (*PaginatedPartQueryResult, error) {
+			return l.contract.GetPartsByManufacturerWithPagination(ctx, "CMOS.Co", 2, bookmark)
+		}, [][]string{{"IVSLAB-C23FA0001", "IVSLAB-C23FA0002"}, {"IVSLAB-C23FA0003"}}},
+		{"by part number", func(ctx contractapi.TransactionContextInterface, bookmark string) (*PaginatedPartQueryResult, error) {
+			return l.contract.GetPartsByPartNumberWithPagination(ctx, "VPN3R1C00AA2", 2, bookmark)
+		}, [][]string{{"IVSLAB-V23FA0002"}}},
+		{"no match", func(ctx contractapi.TransactionContextInterface, bookmark string) (*PaginatedPartQueryResult, error) {
+			return l.contract.GetPartsByManufacturerWithPagination(ctx, "Lens.Co", 2, bookmark)
+		}, [][]string{{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := collectPartPages(t, l, 2, tt.query)
+			if len(pages) != len(tt.want) {
+				t.Fatalf("expected pages %v, got %v", tt.want, pages)
+			}
+			for i := range tt.want {
+				if len(pages[i]) != len(tt.want[i]) {
+					t.Fatalf("expected pages %v, got %v", tt.want, pages)
+				}
+				for j := range tt.want[i] {
+					if pages[i][j] != tt.want[i][j] {
+						t.Fatalf("expected pages %v, got %v", tt.want, pages)
+					}
+				}
+			}
+		})
+	}
+
+	err := l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.GetPartsByRangeWithPagination(ctx, "", "", 0, "")
+		return err
+	})
+	if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "pageSize" {
+		t.Fatalf("unexpected error payload %v", payload)
+	}
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.GetPartsByManufacturerWithPagination(ctx, "CMOS.Co", maxPageSize+1, "")
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeInvalidArgument)
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.GetPartsByOrganizationWithPagination(ctx, "Other-Org", 10, "")
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeInvalidArgument)
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.GetPartsByPartNumberWithPagination(ctx, "", 10, "")
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeInvalidArgument)
+}
@@ -0,0 +1,221 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Actions recorded in a custody timeline
+const (
+	ActionCreated        = "created"
+	ActionDecommissioned = "decommissioned"
+	ActionUpdated        = "updated"
+	ActionDeleted        = "deleted"
+	ActionOffered        = "offered"
+	ActionOfferClosed    = "offerClosed"
+	ActionTransferred    = "transferred"
+	ActionInstalled      = "installed"
+	ActionRemoved        = "removed"
+	ActionScrapped       = "scrapped"
+	ActionReturned       = "returned"
+	ActionReleased       = "released"
+)
+
+// PartHistoryQueryResult structure used for returning result of part history query
+type PartHistoryQueryResult struct {
+	Record    *Part     `json:"record"`
+	TxId      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+}
+
+// CustodyEvent is one change of an asset or of one of its parts in a provenance timeline
+type CustodyEvent struct {
+	TxId         string    `json:"txId"`         // 交易ID
+	Timestamp    time.Time `json:"timestamp"`    // 交易時間
+	ObjectType   string    `json:"objectType"`   // asset 或 part
+	ObjectID     string    `json:"objectID"`     // 產品ID或零件ID
+	Action       string    `json:"action"`       // 異動類型, 見 Action 常數
+	Organization string    `json:"organization"` // 執行異動之組織
+	Asset        *Asset    `json:"asset,omitempty" metadata:",optional"`
+	Part         *Part     `json:"part,omitempty" metadata:",optional"`
+}
+
+// GetPartHistory returns the chain of custody for a part since it was made, most recent change first.
+func (t *SmartContract) GetPartHistory(ctx contractapi.TransactionContextInterface, partID string) ([]PartHistoryQueryResult, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(partKey(partID))
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var records []PartHistoryQueryResult
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var part Part
+		if len(response.Value) > 0 {
+			err = json.Unmarshal(response.Value, &part)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			part = Part{
+				PID: partID,
+			}
+		}
+
+		timestamp, err := ptypes.Timestamp(response.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, PartHistoryQueryResult{
+			TxId:      response.TxId,
+			Timestamp: timestamp,
+			Record:    &part,
+			IsDelete:  response.IsDelete,
+		})
+	}
+
+	return records, nil
+}
+
+// GetAssetProvenance returns the custody timeline of an asset, oldest change first. It merges the
+// history of the asset with the full histories of every part the asset has ever contained.
+func (t *SmartContract) GetAssetProvenance(ctx contractapi.TransactionContextInterface, assetID string) ([]*CustodyEvent, error) {
+	assetHistory, err := t.GetAssetHistory(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if len(assetHistory) == 0 {
+		return nil, notFound("the asset %s does not exist", assetID)
+	}
+
+	// history is returned most recent first
+	var timeline []*CustodyEvent
+	var partIDs []string
+	seen := make(map[string]bool)
+	writer := ""
+	for i := len(assetHistory) - 1; i >= 0; i-- {
+		record := assetHistory[i]
+		action := ActionUpdated
+		if record.IsDelete {
+			action = ActionDeleted
+		} else if record.Record.Status == AssetStatusDecommissioned {
+			action = ActionDecommissioned
+		} else if i == len(assetHistory)-1 || assetHistory[i+1].IsDelete {
+			action = ActionCreated
+		}
+		// a deletion is attributed to the organization that wrote the previous version
+		if !record.IsDelete {
+			writer = assetOrganization(record.Record)
+		}
+		timeline = append(timeline, &CustodyEvent{
+			TxId:         record.TxId,
+			Timestamp:    record.Timestamp,
+			ObjectType:   "asset",
+			ObjectID:     assetID,
+			Action:       action,
+			Organization: writer,
+			Asset:        record.Record,
+		})
+
+		for _, component := range record.Record.Components {
+			if !seen[component.Part.PID] {
+				seen[component.Part.PID] = true
+				partIDs = append(partIDs, component.Part.PID)
+			}
+		}
+	}
+
+	for _, partID := range partIDs {
+		partHistory, err := t.GetPartHistory(ctx, partID)
+		if err != nil {
+			return nil, err
+		}
+		var previous *Part
+		for i := len(partHistory) - 1; i >= 0; i-- {
+			record := partHistory[i]
+			current := record.Record
+			if record.IsDelete {
+				current = nil
+			}
+			// a deleted part was last held by the organization that deleted it
+			organization := record.Record.Organization
+			if current == nil && previous != nil {
+				organization = previous.Organization
+			}
+			timeline = append(timeline, &CustodyEvent{
+				TxId:         record.TxId,
+				Timestamp:    record.Timestamp,
+				ObjectType:   "part",
+				ObjectID:     partID,
+				Action:       partAction(previous, current),
+				Organization: organization,
+				Part:         record.Record,
+			})
+			previous = current
+		}
+	}
+
+	// changes made by the same transaction stay together, the asset first
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Timestamp.Before(timeline[j].Timestamp)
+	})
+
+	return timeline, nil
+}
+
+// assetOrganization returns the organization that wrote a version of an asset, taken from
+// the identity recorded in UpdatedBy. Versions written before the identity was recorded
+// were created by the brand.
+func assetOrganization(asset *Asset) string {
+	if i := strings.Index(asset.UpdatedBy, "/"); i > 0 {
+		return asset.UpdatedBy[:i]
+	}
+	return BrandOrg
+}
+
+// partAction describes the change from the previous to the current version of a part.
+// A nil previous version is a creation, a nil current version a deletion.
+func partAction(previous *Part, current *Part) string {
+	switch {
+	case previous == nil && current == nil:
+		return ActionDeleted
+	case previous == nil:
+		return ActionCreated
+	case current == nil:
+		return ActionDeleted
+	case previous.Organization != current.Organization:
+		return ActionTransferred
+	case previous.OfferID == "" && current.OfferID != "":
+		return ActionOffered
+	case previous.OfferID != "" && current.OfferID == "":
+		return ActionOfferClosed
+	case previous.Status != current.Status:
+		switch current.Status {
+		case PartStatusInstalled:
+			return ActionInstalled
+		case PartStatusRemoved:
+			return ActionRemoved
+		case PartStatusScrapped:
+			return ActionScrapped
+		case PartStatusReturned:
+			return ActionReturned
+		default:
+			return ActionReleased
+		}
+	case previous.AssetID != current.AssetID:
+		return ActionInstalled
+	}
+	return ActionUpdated
+}
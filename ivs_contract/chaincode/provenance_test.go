@@ -0,0 +1,179 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestGetPartHistory(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA0001")
+
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		records, err := l.contract.GetPartHistory(ctx, "IVSLAB-S23FA0001")
+		if err != nil {
+			return err
+		}
+		if len(records) != 3 {
+			t.Fatalf("expected 3 history records, got %d", len(records))
+		}
+		if records[0].Record.Organization != BrandOrg || records[0].Record.OfferID != "" {
+			t.Fatalf("expected the accepted transfer first, got %+v", records[0].Record)
+		}
+		if records[1].Record.Organization != SecurityOrg || records[1].Record.OfferID == "" {
+			t.Fatalf("expected the offer second, got %+v", records[1].Record)
+		}
+		if records[2].Record.Organization != SecurityOrg || !records[2].Timestamp.Before(records[1].Timestamp) {
+			t.Fatalf("expected the creation last, got %+v", records[2])
+		}
+
+		records, err = l.contract.GetPartHistory(ctx, "IVSLAB-S23FA9999")
+		if err != nil {
+			return err
+		}
+		if len(records) != 0 {
+			t.Fatalf("expected no history for an unknown part, got %d records", len(records))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetAssetProvenance(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA0002")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FG0001", "Brand.Co", "Vietnam", "IVSPN902300AACDC01",
+			chipSet("IVSLAB-S23FA0002", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001"))
+	})
+	if err != nil {
+		t.Fatalf("UpdateAsset failed: %v", err)
+	}
+
+	var timeline []*CustodyEvent
+	err = l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		timeline, err = l.contract.GetAssetProvenance(ctx, "IVSLAB-PVC23FG0001")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("GetAssetProvenance failed: %v", err)
+	}
+
+	for i := 1; i < len(timeline); i++ {
+		if timeline[i].Timestamp.Before(timeline[i-1].Timestamp) {
+			t.Fatalf("timeline is not in time order at %d", i)
+		}
+	}
+
+	// the replaced chip keeps its full trail, the chip swapped in is part of the timeline too
+	type step struct {
+		action       string
+		organization string
+	}
+	trails := make(map[string][]step)
+	var assetActions []string
+	for _, event := range timeline {
+		if event.TxId == "" {
+			t.Fatalf("event without transaction ID %+v", event)
+		}
+		switch event.ObjectType {
+		case "asset":
+			assetActions = append(assetActions, event.Action)
+			if event.Organization != BrandOrg {
+				t.Fatalf("expected the asset to be written by %s, got %s", BrandOrg, event.Organization)
+			}
+		case "part":
+			trails[event.ObjectID] = append(trails[event.ObjectID], step{event.Action, event.Organization})
+		}
+	}
+	if len(assetActions) != 2 || assetActions[0] != ActionCreated || assetActions[1] != ActionUpdated {
+		t.Fatalf("unexpected asset actions %v", assetActions)
+	}
+	if len(trails) != 5 {
+		t.Fatalf("expected the trails of 5 parts, got %d", len(trails))
+	}
+	want := []step{
+		{ActionCreated, SecurityOrg},
+		{ActionOffered, SecurityOrg},
+		{ActionTransferred, BrandOrg},
+		{ActionInstalled, BrandOrg},
+		{ActionRemoved, BrandOrg},
+	}
+	trail := trails["IVSLAB-S23FA0001"]
+	if len(trail) != len(want) {
+		t.Fatalf("expected trail %v, got %v", want, trail)
+	}
+	for i := range want {
+		if trail[i] != want[i] {
+			t.Fatalf("expected trail %v, got %v", want, trail)
+		}
+	}
+	if last := trails["IVSLAB-S23FA0002"]; last[len(last)-1].action != ActionInstalled {
+		t.Fatalf("expected the new chip to end installed, got %v", last)
+	}
+
+	// the asset is created in the same transaction that installs its parts and comes first
+	for i, event := range timeline {
+		if event.ObjectType == "asset" && event.Action == ActionCreated {
+			if i+1 >= len(timeline) || timeline[i+1].TxId != event.TxId || timeline[i+1].Action != ActionInstalled {
+				t.Fatalf("expected the parts installed by %s to follow the asset", event.TxId)
+			}
+		}
+	}
+
+	err = l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.GetAssetProvenance(ctx, "IVSLAB-PVC23FG9999")
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeNotFound)
+}
+
+func TestAssetOrganization(t *testing.T) {
+	tests := []struct {
+		updatedBy string
+		want      string
+	}{
+		{"Brand-Org/x509::CN=user1,OU=client::CN=ca.brand.ivs.com", BrandOrg},
+		{"Network-Org/x509::CN=admin,OU=admin::CN=ca.network.ivs.com", NetworkOrg},
+		{"", BrandOrg},
+	}
+	for _, test := range tests {
+		if got := assetOrganization(&Asset{UpdatedBy: test.updatedBy}); got != test.want {
+			t.Errorf("assetOrganization(%q) = %s, want %s", test.updatedBy, got, test.want)
+		}
+	}
+}
+
+func TestPartAction(t *testing.T) {
+	available := &Part{Organization: BrandOrg, Status: PartStatusAvailable}
+	tests := []struct {
+		name     string
+		previous *Part
+		current  *Part
+		want     string
+	}{
+		{"created", nil, available, ActionCreated},
+		{"deleted", available, nil, ActionDeleted},
+		{"transferred", &Part{Organization: SecurityOrg, OfferID: "offer"}, available, ActionTransferred},
+		{"offered", available, &Part{Organization: BrandOrg, Status: PartStatusAvailable, OfferID: "offer"}, ActionOffered},
+		{"offer closed", &Part{Organization: BrandOrg, Status: PartStatusAvailable, OfferID: "offer"}, available, ActionOfferClosed},
+		{"installed", available, &Part{Organization: BrandOrg, Status: PartStatusInstalled, AssetID: "asset"}, ActionInstalled},
+		{"scrapped", &Part{Organization: BrandOrg, Status: PartStatusInstalled, AssetID: "asset"}, &Part{Organization: BrandOrg, Status: PartStatusScrapped}, ActionScrapped},
+		{"unchanged", available, available, ActionUpdated},
+	}
+	for _, tt := range tests {
+		if got := partAction(tt.previous, tt.current); got != tt.want {
+			t.Fatalf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Purging physically removes a record registered by mistake. Only its key history remains,
+// so regular deletions must use DeletePart and DeleteAsset instead.
+
+// PurgePart removes a part and its index entry from the world state. Only admins may purge,
+// and parts that are installed or offered for transfer cannot be purged.
+func (t *SmartContract) PurgePart(ctx contractapi.TransactionContextInterface, partID string) error {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	part, err := t.ReadPart(ctx, partID)
+	if err != nil {
+		return err
+	}
+	if part.Status == PartStatusInstalled {
+		return conflict("part %s is installed in asset %s and cannot be purged", partID, part.AssetID)
+	}
+	if part.OfferID != "" {
+		return conflict("part %s is offered in transfer %s and cannot be purged", partID, part.OfferID)
+	}
+
+	err = ctx.GetStub().DelState(partKey(partID))
+	if err != nil {
+		return internalError("failed to purge part %s: %v", partID, err)
+	}
+	partIndexKey, err := ctx.GetStub().CreateCompositeKey(manufacturerPartIndex, []string{part.Organization, part.PID})
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(partIndexKey)
+	if err != nil {
+		return internalError("failed to purge index entry of part %s: %v", partID, err)
+	}
+	if part.LotID != "" {
+		lotIndexKey, err := ctx.GetStub().CreateCompositeKey(lotPartIndex, []string{part.LotID, part.PID})
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelState(lotIndexKey)
+		if err != nil {
+			return internalError("failed to purge lot index entry of part %s: %v", partID, err)
+		}
+	}
+
+	return emitEvent(ctx, &ContractEvent{
+		Name:       EventPartPurged,
+		ObjectType: "part",
+		ObjectID:   partID,
+		OldOwner:   part.Organization,
+	})
+}
+
+// PurgeAsset removes an asset and its index entry from the world state. Only admins may purge.
+// The parts installed in the asset become available again, as if it had never been assembled.
+func (t *SmartContract) PurgeAsset(ctx contractapi.TransactionContextInterface, assetID string) error {
+	err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	asset, err := t.ReadAsset(ctx, assetID)
+	if err != nil {
+		return err
+	}
+	released := partIDs(assetParts(asset))
+	for _, partID := range released {
+		err = releasePart(ctx, assetID, partID, PartStatusAvailable, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	err = ctx.GetStub().DelState(assetKey(assetID))
+	if err != nil {
+		return internalError("failed to purge asset %s: %v", assetID, err)
+	}
+	err = delAssetIndex(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, &ContractEvent{
+		Name:       EventAssetPurged,
+		ObjectType: "asset",
+		ObjectID:   assetID,
+		PartIDs:    released,
+		OldOwner:   asset.MadeBy,
+	})
+}
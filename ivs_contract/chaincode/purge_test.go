@@ -0,0 +1,76 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestPurge(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.PurgeAsset(ctx, "IVSLAB-PVC23FG0001")
+	})
+	expectErrorCode(t, err, ErrCodeForbidden)
+	err = l.submitWithAttributes(brandMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.PurgePart(ctx, "IVSLAB-S23FA0001")
+	})
+	expectErrorCode(t, err, ErrCodeConflict)
+
+	err = l.submitWithAttributes(brandMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.PurgeAsset(ctx, "IVSLAB-PVC23FG0001")
+	})
+	if err != nil {
+		t.Fatalf("PurgeAsset failed: %v", err)
+	}
+	if event := expectEvent(t, l, EventAssetPurged, "IVSLAB-PVC23FG0001"); len(event.PartIDs) != 4 {
+		t.Fatalf("expected the released parts in the event, got %+v", event)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0001"); part.Status != PartStatusAvailable || part.AssetID != "" {
+		t.Fatalf("expected the part to be available again, got %+v", part)
+	}
+
+	err = l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.PurgePart(ctx, "IVSLAB-S23FA0002")
+	})
+	expectErrorCode(t, err, ErrCodeForbidden)
+	err = l.submitWithAttributes(securityMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.PurgePart(ctx, "IVSLAB-S23FA0002")
+	})
+	if err != nil {
+		t.Fatalf("PurgePart failed: %v", err)
+	}
+	expectEvent(t, l, EventPartPurged, "IVSLAB-S23FA0002")
+
+	err = l.evaluate(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		exists, err := l.contract.PartExists(ctx, "IVSLAB-S23FA0002")
+		if err != nil {
+			return err
+		}
+		if exists {
+			t.Fatalf("expected the part to be purged")
+		}
+		parts, err := l.contract.GetPartsByOrganization(ctx, SecurityOrg)
+		if err != nil {
+			return err
+		}
+		if ids := partIDs(parts); len(ids) != 1 || ids[0] != "IVSLAB-S23FA0003" {
+			t.Fatalf("expected the index entry to be purged, got %v", ids)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.submitWithAttributes(brandMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.PurgeAsset(ctx, "IVSLAB-PVC23FG0001")
+	})
+	expectErrorCode(t, err, ErrCodeNotFound)
+}
@@ -0,0 +1,183 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AssetQuery is a typed filter on assets. Empty fields do not restrict the result; ranges are inclusive.
+// Clients pass it as a JSON document: the contract schema cannot describe a parameter without required fields.
+type AssetQuery struct {
+	MadeBy           string `json:"MadeBy,omitempty"`           // 品牌商
+	MadeIn           string `json:"MadeIn,omitempty"`           // 組裝地點
+	SerialNumberFrom string `json:"SerialNumberFrom,omitempty"` // 產品序號起
+	SerialNumberTo   string `json:"SerialNumberTo,omitempty"`   // 產品序號迄
+	ProducedFrom     string `json:"ProducedFrom,omitempty"`     // 產品生產日期起 (RFC3339)
+	ProducedTo       string `json:"ProducedTo,omitempty"`       // 產品生產日期迄 (RFC3339)
+	PartID           string `json:"PartID,omitempty"`           // 包含之零件ID
+	PartManufacturer string `json:"PartManufacturer,omitempty"` // 包含之零件製造商
+	SortBy           string `json:"SortBy,omitempty"`           // 排序欄位, 見 assetSortFields
+	SortDescending   bool   `json:"SortDescending,omitempty"`   // 遞減排序
+}
+
+// assetSortFields are the asset fields a query may be sorted by. Each needs a CouchDB index.
+var assetSortFields = map[string]bool{
+	"ID":             true,
+	"MadeBy":         true,
+	"MadeIn":         true,
+	"SerialNumber":   true,
+	"ProductionDate": true,
+}
+
+// SearchAssets returns the assets matching queryJSON, a JSON encoded AssetQuery
+func (t *SmartContract) SearchAssets(ctx contractapi.TransactionContextInterface, queryJSON string) ([]*Asset, error) {
+	query, err := parseAssetQuery(queryJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return searchAssets(ctx, query)
+}
+
+// SearchAssetsWithPagination returns a page of the assets matching queryJSON, a JSON encoded AssetQuery
+func (t *SmartContract) SearchAssetsWithPagination(ctx contractapi.TransactionContextInterface, queryJSON string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	query, err := parseAssetQuery(queryJSON)
+	if err != nil {
+		return nil, err
+	}
+	queryString, err := buildAssetQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return getQueryResultForQueryStringWithPagination(ctx, queryString, int32(pageSize), bookmark)
+}
+
+// searchAssets returns the assets matching a typed query
+func searchAssets(ctx contractapi.TransactionContextInterface, query AssetQuery) ([]*Asset, error) {
+	queryString, err := buildAssetQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return getQueryResultForQueryString(ctx, queryString)
+}
+
+// parseAssetQuery decodes a JSON encoded AssetQuery. An empty string matches every asset;
+// unknown fields are rejected so that a misspelt filter does not silently match everything.
+func parseAssetQuery(queryJSON string) (AssetQuery, error) {
+	var query AssetQuery
+	if strings.TrimSpace(queryJSON) == "" {
+		return query, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(queryJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&query); err != nil {
+		return query, invalidArgument("query", "invalid asset query: %v", err)
+	}
+	return query, nil
+}
+
+// buildAssetQuery turns a typed query into a CouchDB query. The selector is built as a
+// Go value and marshalled, so caller-supplied values can never change its structure.
+func buildAssetQuery(query AssetQuery) (string, error) {
+	selector := map[string]interface{}{
+		"docType": "asset",
+	}
+	if query.MadeBy != "" {
+		selector["MadeBy"] = query.MadeBy
+	}
+	if query.MadeIn != "" {
+		selector["MadeIn"] = query.MadeIn
+	}
+	if condition := rangeCondition(query.SerialNumberFrom, query.SerialNumberTo); condition != nil {
+		selector["SerialNumber"] = condition
+	}
+
+	for _, date := range []struct {
+		field string
+		value string
+	}{
+		{"ProducedFrom", query.ProducedFrom},
+		{"ProducedTo", query.ProducedTo},
+	} {
+		if date.value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, date.value); err != nil {
+			return "", invalidArgument(date.field, "%s %q is not an RFC3339 timestamp", date.field, date.value)
+		}
+	}
+	if condition := rangeCondition(query.ProducedFrom, query.ProducedTo); condition != nil {
+		selector["ProductionDate"] = condition
+	}
+
+	// each part condition is matched against any component of the asset
+	var partConditions []interface{}
+	if query.PartID != "" {
+		partConditions = append(partConditions, partCondition("PID", query.PartID))
+	}
+	if query.PartManufacturer != "" {
+		partConditions = append(partConditions, partCondition("Manufacturer", query.PartManufacturer))
+	}
+	if len(partConditions) > 0 {
+		selector["$and"] = partConditions
+	}
+
+	request := map[string]interface{}{
+		"selector": selector,
+	}
+	if query.SortBy != "" {
+		if !assetSortFields[query.SortBy] {
+			return "", invalidArgument("SortBy", "assets cannot be sorted by %q", query.SortBy)
+		}
+		// CouchDB only sorts on fields the selector refers to
+		if _, ok := selector[query.SortBy]; !ok {
+			selector[query.SortBy] = map[string]interface{}{"$gt": nil}
+		}
+		direction := "asc"
+		if query.SortDescending {
+			direction = "desc"
+		}
+		request["sort"] = []map[string]string{{query.SortBy: direction}}
+	}
+
+	queryBytes, err := json.Marshal(request)
+	if err != nil {
+		return "", internalError("failed to marshal query: %v", err)
+	}
+
+	return string(queryBytes), nil
+}
+
+// partCondition returns the selector condition of an asset with a part whose field equals value.
+// Assets not yet migrated store their chips in the legacy chip fields instead of Components.
+func partCondition(field string, value string) map[string]interface{} {
+	conditions := []interface{}{
+		map[string]interface{}{
+			"Components": map[string]interface{}{"$elemMatch": map[string]interface{}{"Part." + field: value}},
+		},
+	}
+	for _, slot := range []string{SlotSecurityChip, SlotNetworkChip, SlotCMOSChip, SlotVideoCodecChip} {
+		conditions = append(conditions, map[string]interface{}{slot + "." + field: value})
+	}
+	return map[string]interface{}{"$or": conditions}
+}
+
+// rangeCondition returns the selector condition of an inclusive range, nil when both ends are open
+func rangeCondition(from string, to string) map[string]interface{} {
+	condition := map[string]interface{}{}
+	if from != "" {
+		condition["$gte"] = from
+	}
+	if to != "" {
+		condition["$lte"] = to
+	}
+	if len(condition) == 0 {
+		return nil
+	}
+	return condition
+}
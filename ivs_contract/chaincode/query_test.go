@@ -0,0 +1,230 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestSearchAssets(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	for i, suffix := range []string{"0001", "0002", "0003"} {
+		moveSetToBrand(t, l, suffix)
+		assetID := "IVSLAB-PVC23FG" + suffix
+		serialNumber := "IVSPN902300AACDC0" + string(rune('1'+i))
+		if err := createAsset(l, assetID, serialNumber, suffix); err != nil {
+			t.Fatalf("CreateAsset failed: %v", err)
+		}
+	}
+	asset := readAsset(t, l, "IVSLAB-PVC23FG0002")
+
+	tests := []struct {
+		name  string
+		query AssetQuery
+		want  []string
+	}{
+		{"all", AssetQuery{}, []string{"IVSLAB-PVC23FG0001", "IVSLAB-PVC23FG0002", "IVSLAB-PVC23FG0003"}},
+		{"made by", AssetQuery{MadeBy: "Brand.Co"}, []string{"IVSLAB-PVC23FG0001", "IVSLAB-PVC23FG0002", "IVSLAB-PVC23FG0003"}},
+		{"made in", AssetQuery{MadeIn: "Vietnam"}, nil},
+		{"serial range", AssetQuery{SerialNumberFrom: "IVSPN902300AACDC02", SerialNumberTo: "IVSPN902300AACDC03"}, []string{"IVSLAB-PVC23FG0002", "IVSLAB-PVC23FG0003"}},
+		{"produced from", AssetQuery{ProducedFrom: asset.ProductionDate}, []string{"IVSLAB-PVC23FG0002", "IVSLAB-PVC23FG0003"}},
+		{"produced to", AssetQuery{ProducedTo: asset.ProductionDate}, []string{"IVSLAB-PVC23FG0001", "IVSLAB-PVC23FG0002"}},
+		{"contains part", AssetQuery{PartID: "IVSLAB-C23FA0003"}, []string{"IVSLAB-PVC23FG0003"}},
+		{"part manufacturer", AssetQuery{PartManufacturer: "CMOS.Co"}, []string{"IVSLAB-PVC23FG0001", "IVSLAB-PVC23FG0002", "IVSLAB-PVC23FG0003"}},
+		{"part and manufacturer", AssetQuery{PartID: "IVSLAB-C23FA0003", PartManufacturer: "Network.Co"}, []string{"IVSLAB-PVC23FG0003"}},
+		{"sorted descending", AssetQuery{SortBy: "SerialNumber", SortDescending: true}, []string{"IVSLAB-PVC23FG0003", "IVSLAB-PVC23FG0002", "IVSLAB-PVC23FG0001"}},
+		{"quote in value", AssetQuery{SerialNumberTo: `IVSPN"}, "docType": {"$gt": null}, "x": {"$eq": "`}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var assets []*Asset
+			err := l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				assets, err = l.contract.SearchAssets(ctx, assetQueryJSON(t, tt.query))
+				return err
+			})
+			if err != nil {
+				t.Fatalf("SearchAssets failed: %v", err)
+			}
+			if len(assets) != len(tt.want) {
+				t.Fatalf("expected %v, got %d assets", tt.want, len(assets))
+			}
+			for i, assetID := range tt.want {
+				if assets[i].ID != assetID {
+					t.Fatalf("expected %v, got %s at %d", tt.want, assets[i].ID, i)
+				}
+			}
+		})
+	}
+
+	err := l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		page, err := l.contract.SearchAssetsWithPagination(ctx, `{"SortBy":"ID"}`, 2, "")
+		if err != nil {
+			return err
+		}
+		if page.FetchedRecordsCount != 2 || page.Records[0].ID != "IVSLAB-PVC23FG0001" || page.Bookmark == "" {
+			t.Fatalf("unexpected first page %+v", page)
+		}
+		page, err = l.contract.SearchAssetsWithPagination(ctx, `{"SortBy":"ID"}`, 2, page.Bookmark)
+		if err != nil {
+			return err
+		}
+		if page.FetchedRecordsCount != 1 || page.Records[0].ID != "IVSLAB-PVC23FG0003" {
+			t.Fatalf("unexpected last page %+v", page)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.SearchAssets(ctx, `{"SortBy":"Components"}`)
+		return err
+	})
+	if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "SortBy" {
+		t.Fatalf("unexpected error payload %v", payload)
+	}
+	err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.SearchAssets(ctx, `{"ProducedFrom":"2023-05-15"}`)
+		return err
+	})
+	if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "ProducedFrom" {
+		t.Fatalf("unexpected error payload %v", payload)
+	}
+	for _, queryJSON := range []string{`{"Manufacturer":"Brand.Co"}`, `{"MadeBy":`} {
+		err = l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := l.contract.SearchAssets(ctx, queryJSON)
+			return err
+		})
+		if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "query" {
+			t.Fatalf("unexpected error payload %v", payload)
+		}
+	}
+}
+
+func TestSearchAssetsMatchesLegacyChips(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	// an asset not yet migrated, with one field per chip
+	legacy := map[string]interface{}{
+		"docType":        "asset",
+		"ID":             "IVSLAB-PVC23FG0009",
+		"MadeBy":         "Brand.Co",
+		"MadeIn":         "Taiwan",
+		"SerialNumber":   "IVSPN902300AACDC09",
+		"SecurityChip":   Part{DocType: "part", PID: "IVSLAB-S23FA0009", Manufacturer: "Security.Co", Organization: BrandOrg},
+		"NetworkChip":    Part{DocType: "part", PID: "IVSLAB-N23FA0009", Manufacturer: "Network.Co", Organization: BrandOrg},
+		"CMOSChip":       Part{DocType: "part", PID: "IVSLAB-C23FA0009", Manufacturer: "Legacy CMOS.Co", Organization: BrandOrg},
+		"VideoCodecChip": Part{DocType: "part", PID: "IVSLAB-V23FA0009", Manufacturer: "VideoCodec.Co", Organization: BrandOrg},
+		"ProductionDate": "2023-05-01T00:00:00Z",
+	}
+	legacyBytes, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.stub.state[assetKey("IVSLAB-PVC23FG0009")] = legacyBytes
+
+	tests := []struct {
+		name  string
+		query AssetQuery
+		want  []string
+	}{
+		{"contains part", AssetQuery{PartID: "IVSLAB-C23FA0009"}, []string{"IVSLAB-PVC23FG0009"}},
+		{"part manufacturer", AssetQuery{PartManufacturer: "Legacy CMOS.Co"}, []string{"IVSLAB-PVC23FG0009"}},
+		{"current and legacy assets", AssetQuery{PartManufacturer: "Network.Co", SortBy: "ID"}, []string{"IVSLAB-PVC23FG0001", "IVSLAB-PVC23FG0009"}},
+		{"part of another chip", AssetQuery{PartID: "IVSLAB-S23FA0009", PartManufacturer: "Network.Co"}, []string{"IVSLAB-PVC23FG0009"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var assets []*Asset
+			err := l.evaluate(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				assets, err = l.contract.SearchAssets(ctx, assetQueryJSON(t, tt.query))
+				return err
+			})
+			if err != nil {
+				t.Fatalf("SearchAssets failed: %v", err)
+			}
+			if len(assets) != len(tt.want) {
+				t.Fatalf("expected %v, got %d assets", tt.want, len(assets))
+			}
+			for i, assetID := range tt.want {
+				if assets[i].ID != assetID {
+					t.Fatalf("expected %v, got %s at %d", tt.want, assets[i].ID, i)
+				}
+			}
+		})
+	}
+}
+
+// assetQueryJSON encodes a query the way clients pass it to SearchAssets
+func assetQueryJSON(t *testing.T, query AssetQuery) string {
+	t.Helper()
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(queryBytes)
+}
+
+func TestBuildAssetQuery(t *testing.T) {
+	queryString, err := buildAssetQuery(AssetQuery{MadeBy: `Brand.Co"},"$or":[{}],"x":{"$ne":"`, SortBy: "MadeIn"})
+	if err != nil {
+		t.Fatalf("buildAssetQuery failed: %v", err)
+	}
+
+	var request struct {
+		Selector map[string]interface{} `json:"selector"`
+		Sort     []map[string]string    `json:"sort"`
+	}
+	if err := json.Unmarshal([]byte(queryString), &request); err != nil {
+		t.Fatalf("query %s is not valid JSON: %v", queryString, err)
+	}
+	if len(request.Selector) != 3 || request.Selector["docType"] != "asset" {
+		t.Fatalf("unexpected selector %v", request.Selector)
+	}
+	if request.Selector["MadeBy"] != `Brand.Co"},"$or":[{}],"x":{"$ne":"` {
+		t.Fatalf("expected the value to stay a string, got %v", request.Selector["MadeBy"])
+	}
+	if len(request.Sort) != 1 || request.Sort[0]["MadeIn"] != "asc" {
+		t.Fatalf("unexpected sort %v", request.Sort)
+	}
+}
+
+func TestRawQueriesRequireAdmin(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.QueryAssets(ctx, `{"selector":{"docType":"asset"}}`)
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeForbidden)
+
+	err = l.evaluateWithAttributes(brandMSP, map[string]string{adminAttribute: "false"}, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.QueryAssetsWithPagination(ctx, `{"selector":{"docType":"asset"}}`, 10, "")
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeForbidden)
+
+	err = l.evaluateWithAttributes("otherMSP", adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.QueryAssets(ctx, `{"selector":{"docType":"asset"}}`)
+		return err
+	})
+	expectErrorCode(t, err, ErrCodeForbidden)
+
+	err = l.evaluateWithAttributes(networkMSP, adminAttributes, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.QueryAssets(ctx, `{"selector":{"docType":"asset"}}`)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("QueryAssets by an admin failed: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// assetRepairIndex lists the repair orders of an asset
+const assetRepairIndex = "assetID~repairOrderID"
+
+// RepairOrder records the swap of one part of a deployed asset, e.g. after an RMA
+type RepairOrder struct {
+	DocType                string `json:"docType" metadata:",optional"`                            // DocType is used to distinguish the various types of objects in state database
+	OrderID                string `json:"OrderID" validate:"required,max=64,pattern=code"`         // 維修單號
+	AssetID                string `json:"AssetID" validate:"required,pattern=assetID"`             // 維修之產品ID
+	RemovedPartID          string `json:"RemovedPartID" validate:"required,pattern=partID"`        // 拆下之零件ID
+	ReplacementPartID      string `json:"ReplacementPartID" validate:"required,pattern=partID"`    // 換上之零件ID
+	Disposition            string `json:"Disposition" validate:"required"`                         // 拆下零件之處置 (returned/scrapped)
+	TechnicianOrganization string `json:"TechnicianOrganization" validate:"required,organization"` // 維修技師所屬組織
+	Reason                 string `json:"Reason" validate:"required,max=256"`                      // 維修原因
+	Slot                   string `json:"Slot" metadata:",optional"`                               // 維修之物料清單位置
+	RecordedBy             string `json:"RecordedBy" metadata:",optional"`                         // 登錄者
+	RepairDate             string `json:"RepairDate" metadata:",optional"`                         // 維修日期
+	TxID                   string `json:"TxID" metadata:",optional"`                               // 維修交易ID
+}
+
+// repairDispositions are the states a removed part can be put in by a repair
+var repairDispositions = map[string]bool{
+	PartStatusReturned: true,
+	PartStatusScrapped: true,
+}
+
+// RepairAsset replaces the part order.RemovedPartID of an asset with order.ReplacementPartID. The
+// replacement must be owned by Brand-Org, fit the slot of the removed part and not be installed
+// elsewhere. The removed part is marked as returned or scrapped, and the order is recorded on the
+// asset, on both parts and as a repair order of its own. A negative expectedRevision skips the
+// revision check of the asset.
+func (t *SmartContract) RepairAsset(ctx contractapi.TransactionContextInterface, order RepairOrder, expectedRevision int) error {
+	oldAsset, err := t.beginAssetUpdate(ctx, order.AssetID, ReasonRepair, expectedRevision)
+	if err != nil {
+		return err
+	}
+	order.DocType = "repair"
+	err = validate(&order)
+	if err != nil {
+		return err
+	}
+	if !repairDispositions[order.Disposition] {
+		return invalidArgument("Disposition", "removed parts can be returned or scrapped, not %q", order.Disposition)
+	}
+	if order.ReplacementPartID == order.RemovedPartID {
+		return invalidArgument("ReplacementPartID", "part %s cannot replace itself", order.ReplacementPartID)
+	}
+	orderBytes, err := ctx.GetStub().GetState(repairKey(order.OrderID))
+	if err != nil {
+		return internalError("failed to read repair order %s: %v", order.OrderID, err)
+	}
+	if orderBytes != nil {
+		return alreadyExists("the repair order %s already exists", order.OrderID)
+	}
+
+	items, slot, err := replacePartItems(oldAsset, order.RemovedPartID, order.ReplacementPartID)
+	if err != nil {
+		return err
+	}
+	asset := *oldAsset
+	err = t.replaceComponents(ctx, oldAsset, &asset, items, order.Disposition, order.OrderID)
+	if err != nil {
+		return err
+	}
+	asset.RepairOrderID = order.OrderID
+
+	order.Slot = slot
+	order.RecordedBy, err = getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	order.RepairDate, err = getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	order.TxID = ctx.GetStub().GetTxID()
+	orderBytes, err = json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(repairKey(order.OrderID), orderBytes)
+	if err != nil {
+		return internalError("failed to put repair order %s: %v", order.OrderID, err)
+	}
+	indexKey, err := ctx.GetStub().CreateCompositeKey(assetRepairIndex, []string{order.AssetID, order.OrderID})
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return err
+	}
+
+	return commitAssetUpdate(ctx, oldAsset, &asset, ReasonRepair, EventAssetRepaired)
+}
+
+// ReadRepairOrder returns the repair order with the given ID
+func (t *SmartContract) ReadRepairOrder(ctx contractapi.TransactionContextInterface, orderID string) (*RepairOrder, error) {
+	orderBytes, err := ctx.GetStub().GetState(repairKey(orderID))
+	if err != nil {
+		return nil, internalError("failed to read repair order %s: %v", orderID, err)
+	}
+	if orderBytes == nil {
+		return nil, notFound("the repair order %s does not exist", orderID)
+	}
+
+	var order RepairOrder
+	err = json.Unmarshal(orderBytes, &order)
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// GetAssetRepairOrders returns the repair orders of an asset, ordered by order ID
+func (t *SmartContract) GetAssetRepairOrders(ctx contractapi.TransactionContextInterface, assetID string) ([]*RepairOrder, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(assetRepairIndex, []string{assetID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	orders := []*RepairOrder{}
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 {
+			continue
+		}
+		order, err := t.ReadRepairOrder(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+// repairKey returns the world state key of a repair order
+func repairKey(orderID string) string {
+	return "repair_" + orderID
+}
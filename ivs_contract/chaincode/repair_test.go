@@ -0,0 +1,154 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// repairOrder returns a repair order that swaps the security chip of IVSLAB-PVC23FG0001
+func repairOrder(orderID string, removedPartID string, replacementPartID string, disposition string) RepairOrder {
+	return RepairOrder{
+		OrderID:                orderID,
+		AssetID:                "IVSLAB-PVC23FG0001",
+		RemovedPartID:          removedPartID,
+		ReplacementPartID:      replacementPartID,
+		Disposition:            disposition,
+		TechnicianOrganization: BrandOrg,
+		Reason:                 "secure boot fails after firmware update",
+	}
+}
+
+// repairAsset submits a repair order as Brand-Org
+func repairAsset(l *testLedger, order RepairOrder, expectedRevision int) error {
+	return l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.RepairAsset(ctx, order, expectedRevision)
+	})
+}
+
+func TestRepairAsset(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA0002")
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA0003")
+	moveToBrand(t, l, networkMSP, "IVSLAB-N23FA0002")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+
+	err := repairAsset(l, repairOrder("RMA-0001", "IVSLAB-S23FA0001", "IVSLAB-S23FA0002", PartStatusReturned), 1)
+	if err != nil {
+		t.Fatalf("RepairAsset failed: %v", err)
+	}
+	if event := expectEvent(t, l, EventAssetRepaired, "IVSLAB-PVC23FG0001"); event.Reason != ReasonRepair {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	asset := readAsset(t, l, "IVSLAB-PVC23FG0001")
+	if component(t, asset, SlotSecurityChip).PID != "IVSLAB-S23FA0002" || asset.RepairOrderID != "RMA-0001" || asset.UpdateReason != ReasonRepair || asset.Revision != 2 {
+		t.Fatalf("unexpected asset %+v", asset)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0001"); part.Status != PartStatusReturned || part.AssetID != "" || part.RepairOrderID != "RMA-0001" {
+		t.Fatalf("expected the removed part to be returned, got %+v", part)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0002"); part.Status != PartStatusInstalled || part.RepairOrderID != "RMA-0001" {
+		t.Fatalf("expected the replacement to be installed, got %+v", part)
+	}
+	if part := readPart(t, l, "IVSLAB-N23FA0001"); part.RepairOrderID != "" {
+		t.Fatalf("expected untouched parts to keep no repair order, got %+v", part)
+	}
+
+	err = l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		order, err := l.contract.ReadRepairOrder(ctx, "RMA-0001")
+		if err != nil {
+			return err
+		}
+		if order.Slot != SlotSecurityChip || order.RepairDate != asset.Updated || order.TxID == "" || order.RecordedBy == "" || order.DocType != "repair" {
+			t.Fatalf("unexpected repair order %+v", order)
+		}
+
+		// the repair shows up in the history of the asset and of both parts
+		timeline, err := l.contract.GetAssetProvenance(ctx, "IVSLAB-PVC23FG0001")
+		if err != nil {
+			return err
+		}
+		actions := make(map[string]string)
+		for _, event := range timeline {
+			if event.TxId == order.TxID {
+				actions[event.ObjectID] = event.Action
+			}
+		}
+		if actions["IVSLAB-PVC23FG0001"] != ActionUpdated || actions["IVSLAB-S23FA0001"] != ActionReturned || actions["IVSLAB-S23FA0002"] != ActionInstalled {
+			t.Fatalf("unexpected repair actions %v", actions)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a returned part cannot go back into a camera
+	err = repairAsset(l, repairOrder("RMA-0002", "IVSLAB-S23FA0002", "IVSLAB-S23FA0001", PartStatusScrapped), -1)
+	expectErrorCode(t, err, ErrCodeConflict)
+	// the replacement must fit the slot of the removed part
+	err = repairAsset(l, repairOrder("RMA-0002", "IVSLAB-S23FA0002", "IVSLAB-N23FA0002", PartStatusScrapped), -1)
+	expectError(t, err, "accepts")
+	// the replacement must exist
+	err = repairAsset(l, repairOrder("RMA-0002", "IVSLAB-S23FA0002", "IVSLAB-S23FA0004", PartStatusScrapped), -1)
+	expectErrorCode(t, err, ErrCodeNotFound)
+	err = repairAsset(l, repairOrder("RMA-0002", "IVSLAB-S23FA0001", "IVSLAB-S23FA0003", PartStatusScrapped), -1)
+	if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "oldPartID" {
+		t.Fatalf("unexpected error payload %v", payload)
+	}
+	// a part replaced by itself would be recorded as scrapped while it stays installed
+	err = repairAsset(l, repairOrder("RMA-0002", "IVSLAB-S23FA0002", "IVSLAB-S23FA0002", PartStatusScrapped), -1)
+	if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "ReplacementPartID" {
+		t.Fatalf("unexpected error payload %v", payload)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0002"); part.Status != PartStatusInstalled || part.RepairOrderID != "RMA-0001" {
+		t.Fatalf("expected the part to stay installed, got %+v", part)
+	}
+	err = repairAsset(l, repairOrder("RMA-0002", "IVSLAB-S23FA0002", "IVSLAB-S23FA0003", PartStatusAvailable), -1)
+	if payload := expectErrorCode(t, err, ErrCodeInvalidArgument); payload["field"] != "Disposition" {
+		t.Fatalf("unexpected error payload %v", payload)
+	}
+	err = repairAsset(l, repairOrder("RMA-0001", "IVSLAB-S23FA0002", "IVSLAB-S23FA0003", PartStatusScrapped), -1)
+	expectErrorCode(t, err, ErrCodeAlreadyExists)
+	err = repairAsset(l, repairOrder("RMA-0002", "IVSLAB-S23FA0002", "IVSLAB-S23FA0003", PartStatusScrapped), 1)
+	expectErrorCode(t, err, ErrCodeConflict)
+	err = l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.RepairAsset(ctx, repairOrder("RMA-0002", "IVSLAB-S23FA0002", "IVSLAB-S23FA0003", PartStatusScrapped), -1)
+	})
+	expectErrorCode(t, err, ErrCodeForbidden)
+
+	err = repairAsset(l, repairOrder("RMA-0002", "IVSLAB-S23FA0002", "IVSLAB-S23FA0003", PartStatusScrapped), 2)
+	if err != nil {
+		t.Fatalf("RepairAsset failed: %v", err)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0002"); part.Status != PartStatusScrapped || part.RepairOrderID != "RMA-0002" {
+		t.Fatalf("expected the removed part to be scrapped, got %+v", part)
+	}
+	err = l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		orders, err := l.contract.GetAssetRepairOrders(ctx, "IVSLAB-PVC23FG0001")
+		if err != nil {
+			return err
+		}
+		if len(orders) != 2 || orders[0].OrderID != "RMA-0001" || orders[1].RemovedPartID != "IVSLAB-S23FA0002" {
+			t.Fatalf("unexpected repair orders %+v", orders)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// returned parts can still be sent back to their maker
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.TransferPart(ctx, "IVSLAB-S23FA0001", SecurityOrg)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("TransferPart of a returned part failed: %v", err)
+	}
+}
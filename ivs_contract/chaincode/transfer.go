@@ -0,0 +1,419 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const transferOfferObjectType = "transferOffer"
+const organizationOfferIndex = "organization~offerID"
+
+// Transfer offer states. Only pending offers can be accepted, rejected, cancelled or expired.
+const (
+	OfferStatusPending   = "pending"
+	OfferStatusAccepted  = "accepted"
+	OfferStatusRejected  = "rejected"
+	OfferStatusCancelled = "cancelled"
+	OfferStatusExpired   = "expired"
+)
+
+// TransferOffer 零件移轉要約, created by the owner of the parts and resolved by the recipient.
+type TransferOffer struct {
+	DocType          string   `json:"docType"`                                 // DocType is used to distinguish the various types of objects in state database
+	OfferID          string   `json:"OfferID"`                                 // 要約ID (建立要約之交易ID)
+	PartIDs          []string `json:"PartIDs"`                                 // 移轉零件ID
+	FromOrganization string   `json:"FromOrganization"`                        // 移出組織
+	ToOrganization   string   `json:"ToOrganization"`                          // 移入組織
+	Status           string   `json:"Status"`                                  // 要約狀態
+	Created          string   `json:"Created"`                                 // 建立日期
+	Expires          string   `json:"Expires,omitempty" metadata:",optional"`  // 到期日期
+	Resolved         string   `json:"Resolved,omitempty" metadata:",optional"` // 處理日期
+}
+
+// OfferPartTransfer creates a transfer offer for a batch of parts owned by the calling organization.
+// Returned parts can only be offered to their maker. The parts stay with the current owner until
+// the recipient accepts the offer. ttlSeconds limits how
+// long the offer can be accepted, 0 means it does not expire. The offer ID is returned.
+func (t *SmartContract) OfferPartTransfer(ctx contractapi.TransactionContextInterface, partIDs []string, toOrganization string, ttlSeconds int) (string, error) {
+	if len(partIDs) == 0 {
+		return "", invalidArgument("partIDs", "no parts given for transfer")
+	}
+	if ttlSeconds < 0 {
+		return "", invalidArgument("ttlSeconds", "ttlSeconds must not be negative, got %d", ttlSeconds)
+	}
+	if !isOrganization(toOrganization) {
+		return "", invalidArgument("toOrganization", "unknown organization %s", toOrganization)
+	}
+	fromOrganization, err := getClientOrganization(ctx)
+	if err != nil {
+		return "", err
+	}
+	if fromOrganization == toOrganization {
+		return "", conflict("parts are already owned by %s", toOrganization)
+	}
+
+	offerID := ctx.GetStub().GetTxID()
+	seen := make(map[string]bool)
+	for _, partID := range partIDs {
+		if seen[partID] {
+			return "", invalidArgument("partIDs", "part %s is listed more than once", partID)
+		}
+		seen[partID] = true
+
+		part, err := t.ReadPart(ctx, partID)
+		if err != nil {
+			return "", err
+		}
+		// Only the current owner may transfer a part
+		err = requireOrganization(ctx, part.Organization)
+		if err != nil {
+			return "", err
+		}
+		if part.OfferID != "" {
+			return "", conflict("part %s is already part of pending transfer offer %s", partID, part.OfferID)
+		}
+		if part.Status == PartStatusInstalled || part.Status == PartStatusScrapped {
+			return "", conflict("part %s is %s and cannot be transferred", partID, part.Status)
+		}
+		if maker := partMaker(partID); part.Status == PartStatusReturned && toOrganization != maker {
+			return "", invalidArgument("toOrganization", "part %s was returned by a repair and can only be transferred back to its maker %s", partID, maker)
+		}
+
+		part.OfferID = offerID
+		partBytes, err := json.Marshal(part)
+		if err != nil {
+			return "", err
+		}
+		err = ctx.GetStub().PutState(partKey(partID), partBytes)
+		if err != nil {
+			return "", internalError("failed to write part %s: %v", partID, err)
+		}
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	offer := &TransferOffer{
+		DocType:          transferOfferObjectType,
+		OfferID:          offerID,
+		PartIDs:          partIDs,
+		FromOrganization: fromOrganization,
+		ToOrganization:   toOrganization,
+		Status:           OfferStatusPending,
+		Created:          now.Format(time.RFC3339),
+	}
+	if ttlSeconds > 0 {
+		offer.Expires = now.Add(time.Duration(ttlSeconds) * time.Second).Format(time.RFC3339)
+	}
+	err = putTransferOffer(ctx, offer)
+	if err != nil {
+		return "", err
+	}
+
+	// Index the pending offer under both organizations
+	for _, organization := range []string{fromOrganization, toOrganization} {
+		offerIndexKey, err := ctx.GetStub().CreateCompositeKey(organizationOfferIndex, []string{organization, offerID})
+		if err != nil {
+			return "", err
+		}
+		value := []byte{0x00}
+		err = ctx.GetStub().PutState(offerIndexKey, value)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = emitEvent(ctx, &ContractEvent{
+		Name:       EventPartTransferOffered,
+		ObjectType: transferOfferObjectType,
+		ObjectID:   offerID,
+		PartIDs:    partIDs,
+		OldOwner:   fromOrganization,
+		NewOwner:   toOrganization,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return offerID, nil
+}
+
+// AcceptPartTransfer accepts a pending transfer offer. Only the recipient organization may call it;
+// the Organization and TransferDate of every part in the offer change at this point.
+func (t *SmartContract) AcceptPartTransfer(ctx contractapi.TransactionContextInterface, offerID string) error {
+	offer, err := t.ReadTransferOffer(ctx, offerID)
+	if err != nil {
+		return err
+	}
+	err = requireOrganization(ctx, offer.ToOrganization)
+	if err != nil {
+		return err
+	}
+	if offer.Status != OfferStatusPending {
+		return conflict("transfer offer %s is %s", offerID, offer.Status)
+	}
+	expired, err := offerExpired(ctx, offer)
+	if err != nil {
+		return err
+	}
+	if expired {
+		return conflict("transfer offer %s expired at %s", offerID, offer.Expires)
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	for _, partID := range offer.PartIDs {
+		part, err := t.ReadPart(ctx, partID)
+		if err != nil {
+			return err
+		}
+		if part.OfferID != offerID || part.Organization != offer.FromOrganization {
+			return conflict("part %s changed since transfer offer %s was made", partID, offerID)
+		}
+		if part.Status == PartStatusInstalled || part.Status == PartStatusScrapped {
+			return conflict("part %s is %s and cannot change owner", partID, part.Status)
+		}
+
+		// the maker takes back a part returned by a repair and may reuse it
+		if part.Status == PartStatusReturned && offer.ToOrganization == partMaker(partID) {
+			part.Status = PartStatusAvailable
+		}
+		part.Organization = offer.ToOrganization
+		part.TransferDate = now
+		part.OfferID = ""
+		partBytes, err := json.Marshal(part)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PutState(partKey(partID), partBytes)
+		if err != nil {
+			return internalError("failed to write part %s: %v", partID, err)
+		}
+
+		// Move the organization index entry to the new owner
+		oldIndexKey, err := ctx.GetStub().CreateCompositeKey(manufacturerPartIndex, []string{offer.FromOrganization, partID})
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelState(oldIndexKey)
+		if err != nil {
+			return err
+		}
+		PartIndexKey, err := ctx.GetStub().CreateCompositeKey(manufacturerPartIndex, []string{offer.ToOrganization, partID})
+		if err != nil {
+			return err
+		}
+		value := []byte{0x00}
+		err = ctx.GetStub().PutState(PartIndexKey, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return closeTransferOffer(ctx, offer, OfferStatusAccepted, now)
+}
+
+// RejectPartTransfer rejects a pending transfer offer. Only the recipient organization may call it.
+func (t *SmartContract) RejectPartTransfer(ctx contractapi.TransactionContextInterface, offerID string) error {
+	offer, err := t.ReadTransferOffer(ctx, offerID)
+	if err != nil {
+		return err
+	}
+	err = requireOrganization(ctx, offer.ToOrganization)
+	if err != nil {
+		return err
+	}
+
+	return t.releaseTransferOffer(ctx, offer, OfferStatusRejected)
+}
+
+// CancelPartTransfer withdraws a pending transfer offer. Only the offering organization may call it.
+func (t *SmartContract) CancelPartTransfer(ctx contractapi.TransactionContextInterface, offerID string) error {
+	offer, err := t.ReadTransferOffer(ctx, offerID)
+	if err != nil {
+		return err
+	}
+	err = requireOrganization(ctx, offer.FromOrganization)
+	if err != nil {
+		return err
+	}
+
+	return t.releaseTransferOffer(ctx, offer, OfferStatusCancelled)
+}
+
+// ExpirePartTransfer closes a pending transfer offer whose expiry has passed and releases its parts.
+// Either organization involved in the offer may call it.
+func (t *SmartContract) ExpirePartTransfer(ctx contractapi.TransactionContextInterface, offerID string) error {
+	offer, err := t.ReadTransferOffer(ctx, offerID)
+	if err != nil {
+		return err
+	}
+	err = requireOrganization(ctx, offer.FromOrganization, offer.ToOrganization)
+	if err != nil {
+		return err
+	}
+	expired, err := offerExpired(ctx, offer)
+	if err != nil {
+		return err
+	}
+	if !expired {
+		return conflict("transfer offer %s has not expired", offerID)
+	}
+
+	return t.releaseTransferOffer(ctx, offer, OfferStatusExpired)
+}
+
+// ReadTransferOffer retrieves a transfer offer from the ledger
+func (t *SmartContract) ReadTransferOffer(ctx contractapi.TransactionContextInterface, offerID string) (*TransferOffer, error) {
+	offerKey, err := ctx.GetStub().CreateCompositeKey(transferOfferObjectType, []string{offerID})
+	if err != nil {
+		return nil, err
+	}
+	offerBytes, err := ctx.GetStub().GetState(offerKey)
+	if err != nil {
+		return nil, internalError("failed to get transfer offer %s: %v", offerID, err)
+	}
+	if offerBytes == nil {
+		return nil, notFound("transfer offer %s does not exist", offerID)
+	}
+
+	var offer TransferOffer
+	err = json.Unmarshal(offerBytes, &offer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &offer, nil
+}
+
+// GetPendingTransferOffers returns the pending transfer offers made by or to an organization
+func (t *SmartContract) GetPendingTransferOffers(ctx contractapi.TransactionContextInterface, organization string) ([]*TransferOffer, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(organizationOfferIndex, []string{organization})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	offers := []*TransferOffer{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		offer, err := t.ReadTransferOffer(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, offer)
+	}
+
+	return offers, nil
+}
+
+// releaseTransferOffer closes a pending offer without moving its parts and unlocks them
+func (t *SmartContract) releaseTransferOffer(ctx contractapi.TransactionContextInterface, offer *TransferOffer, status string) error {
+	if offer.Status != OfferStatusPending {
+		return conflict("transfer offer %s is %s", offer.OfferID, offer.Status)
+	}
+
+	for _, partID := range offer.PartIDs {
+		part, err := t.ReadPart(ctx, partID)
+		if err != nil {
+			return err
+		}
+		if part.OfferID != offer.OfferID {
+			continue
+		}
+		part.OfferID = ""
+		partBytes, err := json.Marshal(part)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PutState(partKey(partID), partBytes)
+		if err != nil {
+			return internalError("failed to write part %s: %v", partID, err)
+		}
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return closeTransferOffer(ctx, offer, status, now)
+}
+
+// closeTransferOffer stores the final state of an offer, removes its pending index entries and emits its event
+func closeTransferOffer(ctx contractapi.TransactionContextInterface, offer *TransferOffer, status string, resolved string) error {
+	offer.Status = status
+	offer.Resolved = resolved
+	err := putTransferOffer(ctx, offer)
+	if err != nil {
+		return err
+	}
+
+	for _, organization := range []string{offer.FromOrganization, offer.ToOrganization} {
+		offerIndexKey, err := ctx.GetStub().CreateCompositeKey(organizationOfferIndex, []string{organization, offer.OfferID})
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelState(offerIndexKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Ownership only changes when the offer is accepted
+	event := &ContractEvent{
+		Name:       offerEvents[status],
+		ObjectType: transferOfferObjectType,
+		ObjectID:   offer.OfferID,
+		PartIDs:    offer.PartIDs,
+		OldOwner:   offer.FromOrganization,
+	}
+	if status == OfferStatusAccepted {
+		event.NewOwner = offer.ToOrganization
+	}
+
+	return emitEvent(ctx, event)
+}
+
+// putTransferOffer writes a transfer offer to the world state
+func putTransferOffer(ctx contractapi.TransactionContextInterface, offer *TransferOffer) error {
+	offerKey, err := ctx.GetStub().CreateCompositeKey(transferOfferObjectType, []string{offer.OfferID})
+	if err != nil {
+		return err
+	}
+	offerBytes, err := json.Marshal(offer)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(offerKey, offerBytes)
+}
+
+// offerExpired returns true when the offer has an expiry that lies before the transaction timestamp
+func offerExpired(ctx contractapi.TransactionContextInterface, offer *TransferOffer) (bool, error) {
+	if offer.Expires == "" {
+		return false, nil
+	}
+	expires, err := time.Parse(time.RFC3339, offer.Expires)
+	if err != nil {
+		return false, internalError("invalid expiry on transfer offer %s: %v", offer.OfferID, err)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return !now.Before(expires), nil
+}
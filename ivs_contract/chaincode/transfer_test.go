@@ -0,0 +1,344 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// offerParts offers parts owned by mspID to toOrganization and returns the offer ID
+func offerParts(t *testing.T, l *testLedger, mspID string, partIDs []string, toOrganization string, ttlSeconds int) string {
+	t.Helper()
+	var offerID string
+	err := l.submit(mspID, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		offerID, err = l.contract.OfferPartTransfer(ctx, partIDs, toOrganization, ttlSeconds)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("OfferPartTransfer failed: %v", err)
+	}
+	return offerID
+}
+
+// readOffer reads a transfer offer, failing the test if it cannot be read
+func readOffer(t *testing.T, l *testLedger, offerID string) *TransferOffer {
+	t.Helper()
+	var offer *TransferOffer
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		offer, err = l.contract.ReadTransferOffer(ctx, offerID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ReadTransferOffer %s failed: %v", offerID, err)
+	}
+	return offer
+}
+
+// pendingOffers returns the pending offers of an organization
+func pendingOffers(t *testing.T, l *testLedger, organization string) []*TransferOffer {
+	t.Helper()
+	var offers []*TransferOffer
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		offers, err = l.contract.GetPendingTransferOffers(ctx, organization)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("GetPendingTransferOffers %s failed: %v", organization, err)
+	}
+	return offers
+}
+
+func TestTransferPart(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	var offerID string
+	err := l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		offerID, err = l.contract.TransferPart(ctx, "IVSLAB-S23FA0001", BrandOrg)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("TransferPart failed: %v", err)
+	}
+	event := expectEvent(t, l, EventPartTransferOffered, offerID)
+	if event.OldOwner != SecurityOrg || event.NewOwner != BrandOrg {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	// ownership only changes on acceptance
+	part := readPart(t, l, "IVSLAB-S23FA0001")
+	if part.Organization != SecurityOrg || part.TransferDate != "" || part.OfferID != offerID {
+		t.Fatalf("expected part to stay with %s until accepted, got %+v", SecurityOrg, part)
+	}
+	if offers := pendingOffers(t, l, BrandOrg); len(offers) != 1 || offers[0].OfferID != offerID {
+		t.Fatalf("expected offer %s pending for %s, got %+v", offerID, BrandOrg, offers)
+	}
+	if offers := pendingOffers(t, l, SecurityOrg); len(offers) != 1 {
+		t.Fatalf("expected offer %s pending for %s, got %+v", offerID, SecurityOrg, offers)
+	}
+
+	// a part can only be in one pending offer
+	err = l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.TransferPart(ctx, "IVSLAB-S23FA0001", NetworkOrg)
+		return err
+	})
+	expectError(t, err, "already part of pending transfer offer")
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.AcceptPartTransfer(ctx, offerID)
+	})
+	if err != nil {
+		t.Fatalf("AcceptPartTransfer failed: %v", err)
+	}
+	expectEvent(t, l, EventPartTransferAccepted, offerID)
+
+	part = readPart(t, l, "IVSLAB-S23FA0001")
+	if part.Organization != BrandOrg || part.OfferID != "" || part.TransferDate == "" {
+		t.Fatalf("expected part to belong to %s, got %+v", BrandOrg, part)
+	}
+	if offer := readOffer(t, l, offerID); offer.Status != OfferStatusAccepted || offer.Resolved == "" {
+		t.Fatalf("unexpected offer %+v", offer)
+	}
+	if offers := pendingOffers(t, l, BrandOrg); len(offers) != 0 {
+		t.Fatalf("expected no pending offers, got %+v", offers)
+	}
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.AcceptPartTransfer(ctx, offerID)
+	})
+	expectError(t, err, "is accepted")
+}
+
+func TestTransferPartsByOrganization(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	var offerID string
+	err := l.submit(cmosMSP, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		offerID, err = l.contract.TransferPartsByOrganization(ctx, CMOSOrg, BrandOrg)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("TransferPartsByOrganization failed: %v", err)
+	}
+	if offer := readOffer(t, l, offerID); len(offer.PartIDs) != 3 {
+		t.Fatalf("expected an offer for 3 parts, got %+v", offer)
+	}
+
+	// all parts are locked in the pending offer
+	err = l.submit(cmosMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.TransferPartsByOrganization(ctx, CMOSOrg, BrandOrg)
+		return err
+	})
+	expectError(t, err, "no parts that can be transferred")
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.AcceptPartTransfer(ctx, offerID)
+	})
+	if err != nil {
+		t.Fatalf("AcceptPartTransfer failed: %v", err)
+	}
+	for _, partID := range []string{"IVSLAB-C23FA0001", "IVSLAB-C23FA0002", "IVSLAB-C23FA0003"} {
+		if part := readPart(t, l, partID); part.Organization != BrandOrg {
+			t.Fatalf("expected %s to belong to %s, got %+v", partID, BrandOrg, part)
+		}
+	}
+
+	// the organization index follows the new owner
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.TransferPartsByOrganization(ctx, BrandOrg, CMOSOrg)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("TransferPartsByOrganization back failed: %v", err)
+	}
+}
+
+func TestRejectAndCancelPartTransfer(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	offerID := offerParts(t, l, networkMSP, []string{"IVSLAB-N23FA0001", "IVSLAB-N23FA0002"}, BrandOrg, 0)
+	err := l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.RejectPartTransfer(ctx, offerID)
+	})
+	expectError(t, err, "FORBIDDEN")
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.RejectPartTransfer(ctx, offerID)
+	})
+	if err != nil {
+		t.Fatalf("RejectPartTransfer failed: %v", err)
+	}
+	expectEvent(t, l, EventPartTransferRejected, offerID)
+	if part := readPart(t, l, "IVSLAB-N23FA0002"); part.Organization != NetworkOrg || part.OfferID != "" {
+		t.Fatalf("expected part to be released, got %+v", part)
+	}
+
+	offerID = offerParts(t, l, networkMSP, []string{"IVSLAB-N23FA0001"}, BrandOrg, 0)
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CancelPartTransfer(ctx, offerID)
+	})
+	expectError(t, err, "FORBIDDEN")
+
+	err = l.submit(networkMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.CancelPartTransfer(ctx, offerID)
+	})
+	if err != nil {
+		t.Fatalf("CancelPartTransfer failed: %v", err)
+	}
+	expectEvent(t, l, EventPartTransferCancelled, offerID)
+	if offer := readOffer(t, l, offerID); offer.Status != OfferStatusCancelled {
+		t.Fatalf("unexpected offer %+v", offer)
+	}
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.AcceptPartTransfer(ctx, offerID)
+	})
+	expectError(t, err, "is cancelled")
+}
+
+func TestExpirePartTransfer(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	// every mock transaction is one minute after the previous one
+	offerID := offerParts(t, l, videocodecMSP, []string{"IVSLAB-V23FA0001"}, BrandOrg, 90)
+	err := l.submit(videocodecMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.ExpirePartTransfer(ctx, offerID)
+	})
+	expectError(t, err, "has not expired")
+
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.AcceptPartTransfer(ctx, offerID)
+	})
+	expectError(t, err, "expired at")
+
+	err = l.submit(videocodecMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.ExpirePartTransfer(ctx, offerID)
+	})
+	if err != nil {
+		t.Fatalf("ExpirePartTransfer failed: %v", err)
+	}
+	expectEvent(t, l, EventPartTransferExpired, offerID)
+	if part := readPart(t, l, "IVSLAB-V23FA0001"); part.OfferID != "" {
+		t.Fatalf("expected part to be released, got %+v", part)
+	}
+}
+
+func TestOfferPartTransferErrors(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	tests := []struct {
+		name    string
+		mspID   string
+		partIDs []string
+		to      string
+		ttl     int
+		want    string
+	}{
+		{"no parts", securityMSP, nil, BrandOrg, 0, "no parts given"},
+		{"unknown organization", securityMSP, []string{"IVSLAB-S23FA0001"}, "Unknown-Org", 0, "unknown organization"},
+		{"same organization", securityMSP, []string{"IVSLAB-S23FA0001"}, SecurityOrg, 0, "already owned"},
+		{"negative ttl", securityMSP, []string{"IVSLAB-S23FA0001"}, BrandOrg, -1, "must not be negative"},
+		{"duplicate part", securityMSP, []string{"IVSLAB-S23FA0001", "IVSLAB-S23FA0001"}, BrandOrg, 0, "more than once"},
+		{"missing part", securityMSP, []string{"IVSLAB-S23FA9999"}, BrandOrg, 0, "does not exist"},
+		{"not the owner", networkMSP, []string{"IVSLAB-S23FA0001"}, BrandOrg, 0, "FORBIDDEN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.submit(tt.mspID, func(ctx contractapi.TransactionContextInterface) error {
+				_, err := l.contract.OfferPartTransfer(ctx, tt.partIDs, tt.to, tt.ttl)
+				return err
+			})
+			expectError(t, err, tt.want)
+		})
+	}
+
+	err := l.evaluate(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.ReadTransferOffer(ctx, "tx9999")
+		return err
+	})
+	expectError(t, err, "does not exist")
+}
+
+func TestOfferedPartCannotBeInstalled(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+
+	offerID := offerParts(t, l, brandMSP, []string{"IVSLAB-S23FA0001"}, SecurityOrg, 0)
+	err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001")
+	expectError(t, err, "pending transfer offer")
+
+	// a part installed while the offer is pending, e.g. by the migration of a legacy asset,
+	// must not change owner when the offer is accepted
+	part := readPart(t, l, "IVSLAB-S23FA0001")
+	part.Status = PartStatusInstalled
+	part.AssetID = "IVSLAB-PVC23FG0009"
+	partBytes, err := json.Marshal(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.stub.state[partKey(part.PID)] = partBytes
+
+	err = l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.AcceptPartTransfer(ctx, offerID)
+	})
+	expectErrorCode(t, err, ErrCodeConflict)
+	if part := readPart(t, l, "IVSLAB-S23FA0001"); part.Organization != BrandOrg {
+		t.Fatalf("expected the installed part to stay with %s, got %+v", BrandOrg, part)
+	}
+}
+
+func TestReturnedPartGoesBackToItsMaker(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA0002")
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	if err := repairAsset(l, repairOrder("RMA-0001", "IVSLAB-S23FA0001", "IVSLAB-S23FA0002", PartStatusReturned), 1); err != nil {
+		t.Fatalf("RepairAsset failed: %v", err)
+	}
+
+	err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := l.contract.OfferPartTransfer(ctx, []string{"IVSLAB-S23FA0001"}, NetworkOrg, 0)
+		return err
+	})
+	payload := expectErrorCode(t, err, ErrCodeInvalidArgument)
+	if payload["field"] != "toOrganization" {
+		t.Fatalf("expected field toOrganization, got %v", payload)
+	}
+
+	offerID := offerParts(t, l, brandMSP, []string{"IVSLAB-S23FA0001"}, SecurityOrg, 0)
+	err = l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.AcceptPartTransfer(ctx, offerID)
+	})
+	if err != nil {
+		t.Fatalf("AcceptPartTransfer failed: %v", err)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0001"); part.Organization != SecurityOrg || part.Status != PartStatusAvailable {
+		t.Fatalf("expected the returned part to be available again at %s, got %+v", SecurityOrg, part)
+	}
+
+	// the maker can ship the part again and the brand can install it
+	moveToBrand(t, l, securityMSP, "IVSLAB-S23FA0001")
+	err = l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.ReplaceAssetPart(ctx, "IVSLAB-PVC23FG0001", "IVSLAB-S23FA0002", "IVSLAB-S23FA0001", ReasonPartReplacement, -1)
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAssetPart with the refurbished part failed: %v", err)
+	}
+	if part := readPart(t, l, "IVSLAB-S23FA0001"); part.Status != PartStatusInstalled || part.AssetID != "IVSLAB-PVC23FG0001" {
+		t.Fatalf("expected the refurbished part to be installed, got %+v", part)
+	}
+}
@@ -0,0 +1,118 @@
+package chaincode
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// validationPatterns are the formats that can be referenced by a `pattern=` validation rule
+var validationPatterns = map[string]*regexp.Regexp{
+	// IVSLAB-<category><year><plant><sequence>, e.g. IVSLAB-S23FA0001 for a security chip
+	"partID": regexp.MustCompile(`^IVSLAB-[SNCV]\d{2}[A-Z]{2}\d{4}$`),
+	// IVSLAB-<product line><year><plant><sequence>, e.g. IVSLAB-PVC23FG0001
+	"assetID": regexp.MustCompile(`^IVSLAB-[A-Z]{3}\d{2}[A-Z]{2}\d{4}$`),
+	// IVSPN followed by 13 digits or capital letters, e.g. IVSPN902300AACDC01
+	"serialNumber": regexp.MustCompile(`^IVSPN[0-9A-Z]{13}$`),
+	// identifiers chosen by organizations, e.g. part numbers and product model IDs
+	"code": regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]*$`),
+	// hex-encoded SHA-256 digest, e.g. of a quality certificate
+	"sha256": regexp.MustCompile(`^[0-9a-f]{64}$`),
+}
+
+// partCategories maps the category letter of a part ID to the chip maker whose parts it identifies
+var partCategories = map[byte]string{
+	'S': SecurityOrg,
+	'N': NetworkOrg,
+	'C': CMOSOrg,
+	'V': VideoCodecOrg,
+}
+
+// partMaker returns the chip maker named by the category of a part ID, or "" for a malformed ID
+func partMaker(partID string) string {
+	const categoryIndex = len("IVSLAB-")
+	if len(partID) <= categoryIndex {
+		return ""
+	}
+	return partCategories[partID[categoryIndex]]
+}
+
+// checkPartCategory returns an error unless the ID of a new part has the category of its organization
+func checkPartCategory(part *Part) error {
+	if maker := partMaker(part.PID); maker != part.Organization {
+		return invalidArgument("PID", "part ID %s identifies a part of %s, not of %s", part.PID, maker, part.Organization)
+	}
+	return nil
+}
+
+// validate checks the string fields of a struct against the rules in their `validate` tag and
+// returns an INVALID_ARGUMENT error naming the first field that breaks a rule. Rules are
+// separated by commas:
+//
+//	required      the field must not be empty
+//	max=N         the field must not be longer than N bytes
+//	pattern=NAME  a non-empty field must match validationPatterns[NAME]
+//	organization  a non-empty field must be one of the network's organizations
+func validate(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || field.Type.Kind() != reflect.String {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		err := validateField(name, value.Field(i).String(), tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateField checks a single value against the rules of a `validate` tag
+func validateField(name string, fieldValue string, tag string) error {
+	for _, rule := range strings.Split(tag, ",") {
+		key, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			key, arg = rule[:i], rule[i+1:]
+		}
+
+		switch key {
+		case "required":
+			if strings.TrimSpace(fieldValue) == "" {
+				return invalidArgument(name, "%s must not be empty", name)
+			}
+		case "max":
+			max, err := strconv.Atoi(arg)
+			if err != nil {
+				return internalError("invalid validation rule %q for %s", rule, name)
+			}
+			if len(fieldValue) > max {
+				return invalidArgument(name, "%s must be at most %d characters long", name, max)
+			}
+		case "pattern":
+			pattern, ok := validationPatterns[arg]
+			if !ok {
+				return internalError("unknown validation pattern %q for %s", arg, name)
+			}
+			if fieldValue != "" && !pattern.MatchString(fieldValue) {
+				return invalidArgument(name, "%s %q does not match the %s format", name, fieldValue, arg)
+			}
+		case "organization":
+			if fieldValue != "" && !isOrganization(fieldValue) {
+				return invalidArgument(name, "%s %q is not a known organization", name, fieldValue)
+			}
+		default:
+			return internalError("unknown validation rule %q for %s", rule, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestValidateCreatePart(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+
+	tests := []struct {
+		name         string
+		partID       string
+		manufacturer string
+		partNumber   string
+		organization string
+		field        string
+	}{
+		{"empty ID", "", "Security.Co", "SPN3R1C00AA9", SecurityOrg, "PID"},
+		{"malformed ID", "S23FA0009", "Security.Co", "SPN3R1C00AA9", SecurityOrg, "PID"},
+		{"lower case ID", "IVSLAB-s23fa0009", "Security.Co", "SPN3R1C00AA9", SecurityOrg, "PID"},
+		{"empty manufacturer", "IVSLAB-S23FA0009", "", "SPN3R1C00AA9", SecurityOrg, "Manufacturer"},
+		{"blank manufacturer", "IVSLAB-S23FA0009", "   ", "SPN3R1C00AA9", SecurityOrg, "Manufacturer"},
+		{"long manufacturer", "IVSLAB-S23FA0009", strings.Repeat("M", 65), "SPN3R1C00AA9", SecurityOrg, "Manufacturer"},
+		{"malformed part number", "IVSLAB-S23FA0009", "Security.Co", "SPN 3R1C", SecurityOrg, "PartNumber"},
+		{"unknown organization", "IVSLAB-S23FA0009", "Security.Co", "SPN3R1C00AA9", "Other-Org", "Organization"},
+		{"unknown category", "IVSLAB-L23FA0009", "Security.Co", "SPN3R1C00AA9", SecurityOrg, "PID"},
+		{"category of another maker", "IVSLAB-N23FA0009", "Security.Co", "SPN3R1C00AA9", SecurityOrg, "PID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.submit(securityMSP, func(ctx contractapi.TransactionContextInterface) error {
+				return l.contract.CreatePart(ctx, tt.partID, tt.manufacturer, "Taiwan", "SecurityChip-v1", tt.partNumber, tt.organization)
+			})
+			payload := expectErrorCode(t, err, ErrCodeInvalidArgument)
+			if payload["field"] != tt.field {
+				t.Fatalf("expected field %s, got %v", tt.field, payload)
+			}
+		})
+	}
+}
+
+func TestPartMaker(t *testing.T) {
+	tests := []struct {
+		partID string
+		want   string
+	}{
+		{"IVSLAB-S23FA0001", SecurityOrg},
+		{"IVSLAB-N23FA0001", NetworkOrg},
+		{"IVSLAB-C23FA0001", CMOSOrg},
+		{"IVSLAB-V23FA0001", VideoCodecOrg},
+		{"IVSLAB-L23FA0001", ""},
+		{"IVSLAB-", ""},
+	}
+	for _, tt := range tests {
+		if got := partMaker(tt.partID); got != tt.want {
+			t.Errorf("partMaker(%q) = %q, want %q", tt.partID, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCreateAsset(t *testing.T) {
+	l := newTestLedger()
+	initLedger(t, l)
+	moveSetToBrand(t, l, "0001")
+
+	tests := []struct {
+		name         string
+		assetID      string
+		madeIn       string
+		serialNumber string
+		field        string
+	}{
+		{"malformed ID", "PVC23FG0001", "Taiwan", "IVSPN902300AACDC01", "ID"},
+		{"empty made in", "IVSLAB-PVC23FG0001", "", "IVSPN902300AACDC01", "MadeIn"},
+		{"empty serial number", "IVSLAB-PVC23FG0001", "Taiwan", "", "SerialNumber"},
+		{"short serial number", "IVSLAB-PVC23FG0001", "Taiwan", "IVSPN9023", "SerialNumber"},
+		{"malformed serial number", "IVSLAB-PVC23FG0001", "Taiwan", "XXSPN902300AACDC01", "SerialNumber"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+				return l.contract.CreateAsset(ctx, tt.assetID, DefaultProductModelID, "Brand.Co", tt.madeIn, tt.serialNumber,
+					chipSet("IVSLAB-S23FA0001", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001"))
+			})
+			payload := expectErrorCode(t, err, ErrCodeInvalidArgument)
+			if payload["field"] != tt.field {
+				t.Fatalf("expected field %s, got %v", tt.field, payload)
+			}
+		})
+	}
+
+	if err := createAsset(l, "IVSLAB-PVC23FG0001", "IVSPN902300AACDC01", "0001"); err != nil {
+		t.Fatalf("CreateAsset failed: %v", err)
+	}
+	err := l.submit(brandMSP, func(ctx contractapi.TransactionContextInterface) error {
+		return l.contract.UpdateAsset(ctx, "IVSLAB-PVC23FG0001", "Brand.Co", "Taiwan", "IVSPN-0001",
+			chipSet("IVSLAB-S23FA0001", "IVSLAB-N23FA0001", "IVSLAB-C23FA0001", "IVSLAB-V23FA0001"))
+	})
+	expectErrorCode(t, err, ErrCodeInvalidArgument)
+}